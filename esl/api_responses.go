@@ -0,0 +1,393 @@
+package esl
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SofiaStatusEntry - One row of "sofia status".
+type SofiaStatusEntry struct {
+	Name string
+	// Type - profile, gateway or alias
+	Type string
+	Data string
+	// State - for example RUNNING (0) for a profile or REGED for a gateway
+	State string
+}
+
+// SofiaProfileStatus - The answer of "sofia status profile <profile>".
+type SofiaProfileStatus struct {
+	Name           string
+	Dialplan       string
+	Context        string
+	SipIp          string
+	RtpIp          string
+	Url            string
+	CallsIn        int
+	FailedCallsIn  int
+	CallsOut       int
+	FailedCallsOut int
+	Registrations  int
+	// Fields - every row, keyed by its name as printed, for example BIND-URL
+	Fields map[string]string
+}
+
+// SofiaGatewayStatus - The answer of "sofia status gateway <gateway>".
+type SofiaGatewayStatus struct {
+	Name     string
+	Profile  string
+	Scheme   string
+	Realm    string
+	Username string
+	Proxy    string
+	Context  string
+	// State - the registration state, for example REGED, NOREG or FAIL_WAIT
+	State string
+	// Status - UP or DOWN, from the OPTIONS pings
+	Status         string
+	Expires        int
+	Ping           time.Time
+	Uptime         time.Duration
+	CallsIn        int
+	CallsOut       int
+	FailedCallsIn  int
+	FailedCallsOut int
+	// Fields - every row, keyed by its name as printed, for example PingState
+	Fields map[string]string
+}
+
+// IsUp - Convenience method.
+//   - @return true if the gateway is registered or does not register, and its pings are not failing, see GatewayIsUp
+func (g *SofiaGatewayStatus) IsUp() bool {
+	return GatewayIsUp(g.State, g.Status)
+}
+
+// Status - The answer of the status api command.
+type Status struct {
+	Uptime            time.Duration
+	Version           string
+	Ready             bool
+	SessionsSinceUp   int
+	Sessions          int
+	SessionsPeak      int
+	SessionsPeak5Min  int
+	SessionsPerSecond int
+	MaxSessionsPerSec int
+	PerSecondPeak     int
+	PerSecondPeak5Min int
+	MaxSessions       int
+	IdleCpu           float64
+	MinIdleCpu        float64
+}
+
+// Version - The answer of the version api command.
+type Version struct {
+	// Full - the whole answer, for example FreeSWITCH Version 1.10.7-release~64bit (-release 64bit)
+	Full    string
+	Major   int
+	Minor   int
+	Micro   int
+	Release string
+}
+
+// ShowCallsRow - A row of "show calls as json", the B leg is nil when the call is not bridged.
+type ShowCallsRow struct {
+	Uuid string
+	A    *Channel
+	B    *Channel
+}
+
+var (
+	statusUptimePattern   = regexp.MustCompile(`(\d+) (year|day|hour|minute|second|millisecond|microsecond)s?`)
+	statusVersionPattern  = regexp.MustCompile(`^FreeSWITCH \((.+)\) is (\S+)`)
+	statusSessionsPattern = regexp.MustCompile(`^(\d+) session\(s\) - peak (\d+), last 5min (\d+)`)
+	statusRatePattern     = regexp.MustCompile(`^(\d+) session\(s\) per Sec out of max (\d+), peak (\d+), last 5min (\d+)`)
+	statusIdleCpuPattern  = regexp.MustCompile(`^min idle cpu ([\d.]+)/([\d.]+)`)
+	versionPattern        = regexp.MustCompile(`Version (\d+)\.(\d+)\.(\d+)(\S*)`)
+	uptimeUnits           = map[string]time.Duration{
+		"year":        365 * 24 * time.Hour,
+		"day":         24 * time.Hour,
+		"hour":        time.Hour,
+		"minute":      time.Minute,
+		"second":      time.Second,
+		"millisecond": time.Millisecond,
+		"microsecond": time.Microsecond,
+	}
+)
+
+// Status - status
+func (client *Client) Status() (*Status, error) {
+	body, err := client.SendApi("status", "")
+	if err != nil {
+		return nil, err
+	}
+	return ParseStatus(body), nil
+}
+
+// ParseStatus - Parse the answer of the status api command, the lines which are not understood are skipped.
+func ParseStatus(body string) *Status {
+	status := &Status{}
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "UP "):
+			for _, match := range statusUptimePattern.FindAllStringSubmatch(line, -1) {
+				status.Uptime += time.Duration(atoi(match[1])) * uptimeUnits[match[2]]
+			}
+		case statusVersionPattern.MatchString(line):
+			match := statusVersionPattern.FindStringSubmatch(line)
+			status.Version = strings.TrimPrefix(match[1], "Version ")
+			status.Ready = match[2] == "ready"
+		case strings.HasSuffix(line, "session(s) since startup"):
+			status.SessionsSinceUp = atoi(strings.Fields(line)[0])
+		case statusSessionsPattern.MatchString(line):
+			match := statusSessionsPattern.FindStringSubmatch(line)
+			status.Sessions, status.SessionsPeak, status.SessionsPeak5Min = atoi(match[1]), atoi(match[2]), atoi(match[3])
+		case statusRatePattern.MatchString(line):
+			match := statusRatePattern.FindStringSubmatch(line)
+			status.SessionsPerSecond, status.MaxSessionsPerSec = atoi(match[1]), atoi(match[2])
+			status.PerSecondPeak, status.PerSecondPeak5Min = atoi(match[3]), atoi(match[4])
+		case strings.HasSuffix(line, "session(s) max"):
+			status.MaxSessions = atoi(strings.Fields(line)[0])
+		case statusIdleCpuPattern.MatchString(line):
+			match := statusIdleCpuPattern.FindStringSubmatch(line)
+			status.MinIdleCpu, _ = strconv.ParseFloat(match[1], 64)
+			status.IdleCpu, _ = strconv.ParseFloat(match[2], 64)
+		}
+	}
+	return status
+}
+
+// Version - version
+func (client *Client) Version() (*Version, error) {
+	body, err := client.SendApi("version", "")
+	if err != nil {
+		return nil, err
+	}
+	return ParseVersion(body), nil
+}
+
+// ParseVersion - Parse the answer of the version api command, only Full is set when no version number is found.
+func ParseVersion(body string) *Version {
+	version := &Version{Full: strings.TrimSpace(body)}
+	if match := versionPattern.FindStringSubmatch(body); match != nil {
+		version.Major, version.Minor, version.Micro = atoi(match[1]), atoi(match[2]), atoi(match[3])
+		version.Release = strings.TrimPrefix(match[4], "-")
+	}
+	return version
+}
+
+// ShowChannels - show channels as json
+func (client *Client) ShowChannels() ([]*Channel, error) {
+	rows, err := client.showJson("channels")
+	if err != nil {
+		return nil, err
+	}
+	channels := make([]*Channel, 0, len(rows))
+	for _, row := range rows {
+		channels = append(channels, channelFromRow(row))
+	}
+	return channels, nil
+}
+
+// ShowCalls - show calls as json
+func (client *Client) ShowCalls() ([]*ShowCallsRow, error) {
+	rows, err := client.showJson("calls")
+	if err != nil {
+		return nil, err
+	}
+	calls := make([]*ShowCallsRow, 0, len(rows))
+	for _, row := range rows {
+		call := &ShowCallsRow{Uuid: row["call_uuid"], A: channelFromRow(row)}
+		if row["b_uuid"] != "" {
+			bRow := make(map[string]string)
+			for name, value := range row {
+				if strings.HasPrefix(name, "b_") {
+					bRow[strings.TrimPrefix(name, "b_")] = value
+				}
+			}
+			call.B = channelFromRow(bRow)
+			call.A.OtherLegUuid = call.B.Uuid
+			call.B.OtherLegUuid = call.A.Uuid
+		}
+		if call.Uuid == "" {
+			call.Uuid = call.A.Uuid
+		}
+		calls = append(calls, call)
+	}
+	return calls, nil
+}
+
+// ShowRegistrations - show registrations as json
+func (client *Client) ShowRegistrations() ([]*Registration, error) {
+	rows, err := client.showJson("registrations")
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	registrations := make([]*Registration, 0, len(rows))
+	for _, row := range rows {
+		r := &Registration{CallId: row["token"]}
+		r.applyRow(row, now)
+		registrations = append(registrations, r)
+	}
+	return registrations, nil
+}
+
+// SofiaStatus - sofia status
+func (client *Client) SofiaStatus() ([]SofiaStatusEntry, error) {
+	body, err := client.SendApi("sofia", "status")
+	if err != nil {
+		return nil, err
+	}
+	return ParseSofiaStatus(body), nil
+}
+
+// ParseSofiaStatus - Parse the table of "sofia status", between its ==== lines.
+func ParseSofiaStatus(body string) []SofiaStatusEntry {
+	var entries []SofiaStatusEntry
+	for _, line := range sofiaTableLines(body) {
+		columns := strings.Split(line, "\t")
+		if len(columns) < 4 {
+			continue
+		}
+		entries = append(entries, SofiaStatusEntry{
+			Name:  strings.TrimSpace(columns[0]),
+			Type:  strings.TrimSpace(columns[1]),
+			Data:  strings.TrimSpace(columns[2]),
+			State: strings.TrimSpace(strings.Join(columns[3:], "\t")),
+		})
+	}
+	return entries
+}
+
+// SofiaProfileStatus - sofia status profile <profile>
+func (client *Client) SofiaProfileStatus(profile string) (*SofiaProfileStatus, error) {
+	arg := "status profile " + profile
+	body, err := client.SendApi("sofia", arg)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(body, "Invalid Profile") {
+		return nil, &ApiError{Command: "sofia", Arg: arg, Reply: body}
+	}
+	fields := parseSofiaFields(body)
+	return &SofiaProfileStatus{
+		Name:           fields["Name"],
+		Dialplan:       fields["Dialplan"],
+		Context:        fields["Context"],
+		SipIp:          fields["SIP-IP"],
+		RtpIp:          fields["RTP-IP"],
+		Url:            fields["URL"],
+		CallsIn:        atoi(fields["CALLS-IN"]),
+		FailedCallsIn:  atoi(fields["FAILED-CALLS-IN"]),
+		CallsOut:       atoi(fields["CALLS-OUT"]),
+		FailedCallsOut: atoi(fields["FAILED-CALLS-OUT"]),
+		Registrations:  atoi(fields["REGISTRATIONS"]),
+		Fields:         fields,
+	}, nil
+}
+
+// SofiaGatewayStatus - sofia status gateway <gateway>, the gateway may be prefixed with its profile as in
+// profile::gateway.
+func (client *Client) SofiaGatewayStatus(gateway string) (*SofiaGatewayStatus, error) {
+	arg := "status gateway " + gateway
+	body, err := client.SendApi("sofia", arg)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(body, "Invalid Gateway") {
+		return nil, &ApiError{Command: "sofia", Arg: arg, Reply: body}
+	}
+	return parseSofiaGatewayStatus(parseSofiaFields(body)), nil
+}
+
+func parseSofiaGatewayStatus(fields map[string]string) *SofiaGatewayStatus {
+	status := &SofiaGatewayStatus{
+		Name:           fields["Name"],
+		Profile:        fields["Profile"],
+		Scheme:         fields["Scheme"],
+		Realm:          fields["Realm"],
+		Username:       fields["Username"],
+		Proxy:          fields["Proxy"],
+		Context:        fields["Context"],
+		State:          fields["State"],
+		Status:         fields["Status"],
+		Expires:        atoi(fields["Expires"]),
+		CallsIn:        atoi(fields["CallsIN"]),
+		CallsOut:       atoi(fields["CallsOUT"]),
+		FailedCallsIn:  atoi(fields["FailedCallsIN"]),
+		FailedCallsOut: atoi(fields["FailedCallsOUT"]),
+		Fields:         fields,
+	}
+	if ping, err := strconv.ParseInt(fields["Ping"], 10, 64); err == nil && ping > 0 {
+		status.Ping = time.Unix(ping, 0)
+	}
+	if uptime, err := strconv.ParseInt(strings.TrimSuffix(fields["Uptime"], "s"), 10, 64); err == nil {
+		status.Uptime = time.Duration(uptime) * time.Second
+	}
+	return status
+}
+
+// DumpChannel - uuid_dump <uuid>, parsed as a Channel.
+//   - @return a *ChannelNotFoundError when the channel does not exist
+func (client *Client) DumpChannel(uuid string) (*Channel, error) {
+	headers, err := client.UuidDump(uuid)
+	if err != nil {
+		return nil, err
+	}
+	channel := &Channel{Uuid: uuid, Headers: make(map[string]string), Variables: make(map[string]string)}
+	channel.apply(headers)
+	setIfPresent(&channel.Uuid, headers["Unique-ID"])
+	return channel, nil
+}
+
+// showJson - show <what> as json
+func (client *Client) showJson(what string) ([]map[string]string, error) {
+	body, err := client.SendApi("show", what+" as json")
+	if err != nil {
+		return nil, err
+	}
+	return parseShowJson(body)
+}
+
+func channelFromRow(row map[string]string) *Channel {
+	channel := &Channel{Uuid: row["uuid"], Headers: make(map[string]string), Variables: make(map[string]string)}
+	channel.applyRow(row)
+	return channel
+}
+
+// sofiaTableLines - The lines between the first two ==== lines of a sofia status answer.
+func sofiaTableLines(body string) []string {
+	var lines []string
+	separators := 0
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(line, "=====") {
+			separators++
+			if separators == 2 {
+				break
+			}
+			continue
+		}
+		if separators == 1 && strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// parseSofiaFields - Parse the name<tab>value rows of "sofia status profile" and "sofia status gateway".
+func parseSofiaFields(body string) map[string]string {
+	fields := make(map[string]string)
+	for _, line := range sofiaTableLines(body) {
+		pair := strings.SplitN(line, "\t", 2)
+		if len(pair) != 2 {
+			continue
+		}
+		fields[strings.TrimSpace(pair[0])] = strings.TrimSpace(pair[1])
+	}
+	return fields
+}
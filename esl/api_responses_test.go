@@ -0,0 +1,117 @@
+package esl_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zhouhailin/freeswitch-esl-go/esl"
+)
+
+const statusBody = `UP 0 years, 1 day, 2 hours, 3 minutes, 4 seconds, 5 milliseconds, 6 microseconds
+FreeSWITCH (Version 1.10.7-release 64bit) is ready
+120 session(s) since startup
+3 session(s) - peak 10, last 5min 4
+1 session(s) per Sec out of max 30, peak 5, last 5min 2
+1000 session(s) max
+min idle cpu 0.00/97.50
+Current Stack Size/Max 240K/8192K
+`
+
+const sofiaStatusBody = `                     Name	   Type	                                       Data	State
+=================================================================================================
+               internal	profile	           sip:mod_sofia@10.0.0.1:5060	RUNNING (0)
+          external::gw1	gateway	                  sip:gw1@203.0.113.1	REGED
+=================================================================================================
+1 profile 0 aliases
+`
+
+const sofiaGatewayBody = `=================================================================================================
+Name    	gw1
+Profile 	external
+Scheme  	Digest
+Realm   	203.0.113.1
+Username	alice
+State   	REGED
+Status  	UP
+Ping    	1700000000
+Uptime  	3600s
+CallsIN 	2
+CallsOUT	3
+FailedCallsIN	0
+FailedCallsOUT	1
+=================================================================================================
+`
+
+func TestParseStatus(t *testing.T) {
+	status := esl.ParseStatus(statusBody)
+	uptime := 26*time.Hour + 3*time.Minute + 4*time.Second + 5*time.Millisecond + 6*time.Microsecond
+	if status.Uptime != uptime {
+		t.Errorf("uptime %v, want %v", status.Uptime, uptime)
+	}
+	if status.Version != "1.10.7-release 64bit" || !status.Ready {
+		t.Errorf("version %q ready %v", status.Version, status.Ready)
+	}
+	if status.SessionsSinceUp != 120 || status.Sessions != 3 || status.SessionsPeak != 10 || status.SessionsPeak5Min != 4 {
+		t.Errorf("sessions %+v", status)
+	}
+	if status.SessionsPerSecond != 1 || status.MaxSessionsPerSec != 30 || status.PerSecondPeak != 5 ||
+		status.PerSecondPeak5Min != 2 || status.MaxSessions != 1000 {
+		t.Errorf("rates %+v", status)
+	}
+	if status.MinIdleCpu != 0 || status.IdleCpu != 97.5 {
+		t.Errorf("cpu %v/%v", status.MinIdleCpu, status.IdleCpu)
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	version := esl.ParseVersion("FreeSWITCH Version 1.10.7-release~64bit (-release 64bit)\n")
+	if version.Major != 1 || version.Minor != 10 || version.Micro != 7 || version.Release != "release~64bit" {
+		t.Errorf("version %+v", version)
+	}
+	if version := esl.ParseVersion("garbage"); version.Full != "garbage" || version.Major != 0 {
+		t.Errorf("version %+v", version)
+	}
+}
+
+func TestParseSofiaStatus(t *testing.T) {
+	entries := esl.ParseSofiaStatus(sofiaStatusBody)
+	if len(entries) != 2 {
+		t.Fatalf("entries %+v", entries)
+	}
+	if entries[0].Name != "internal" || entries[0].Type != "profile" || entries[0].State != "RUNNING (0)" {
+		t.Errorf("profile %+v", entries[0])
+	}
+	if entries[1].Name != "external::gw1" || entries[1].Type != "gateway" || entries[1].Data != "sip:gw1@203.0.113.1" {
+		t.Errorf("gateway %+v", entries[1])
+	}
+}
+
+func TestSofiaGatewayStatus(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	server.HandleApi("sofia", func(arg string) string {
+		if arg == "status gateway gw1" {
+			return sofiaGatewayBody
+		}
+		return "Invalid Gateway!\n"
+	})
+	connect(t, server, client)
+
+	gateway, err := client.SofiaGatewayStatus("gw1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gateway.Name != "gw1" || gateway.Profile != "external" || gateway.State != "REGED" || !gateway.IsUp() {
+		t.Errorf("gateway %+v", gateway)
+	}
+	if gateway.Uptime != time.Hour || !gateway.Ping.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("uptime %v ping %v", gateway.Uptime, gateway.Ping)
+	}
+	if gateway.CallsIn != 2 || gateway.CallsOut != 3 || gateway.FailedCallsOut != 1 {
+		t.Errorf("calls %+v", gateway)
+	}
+	if _, err := client.SofiaGatewayStatus("gw2"); err == nil {
+		t.Error("an unknown gateway is an error")
+	} else if _, ok := err.(*esl.ApiError); !ok {
+		t.Errorf("error %T %v", err, err)
+	}
+}
@@ -0,0 +1,84 @@
+package esl
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// JOB_EVENTS - The events SendAsyncApiCommandAndWait subscribes to.
+const JOB_EVENTS = "BACKGROUND_JOB"
+
+// SendAsyncApiCommandAndWait - Submit a FreeSWITCH API command in background mode and block until its BACKGROUND_JOB
+// event is received or ctx is done.
+//   - The Job-UUID is chosen by the client and sent with the bgapi command, so the result cannot be missed. The
+//   - client subscribes to JOB_EVENTS, FreeSWITCH only delivers BACKGROUND_JOB to the subscribed connections.
+//   - @return the BACKGROUND_JOB event, its body holds the command output
+func (client *Client) SendAsyncApiCommandAndWait(ctx context.Context, command, arg string) (*EslEvent, error) {
+	err := client.CheckConnected()
+	if err != nil {
+		return nil, err
+	}
+	if err = subscribe(client, JOB_EVENTS, "Job"); err != nil {
+		return nil, err
+	}
+	jobUuid := newUuid()
+	waiter := make(chan *EslEvent, 1)
+	client.jobMtx.Lock()
+	if client.jobWaiters == nil {
+		client.jobWaiters = make(map[string]chan *EslEvent)
+	}
+	client.jobWaiters[jobUuid] = waiter
+	client.jobMtx.Unlock()
+	defer func() {
+		client.jobMtx.Lock()
+		delete(client.jobWaiters, jobUuid)
+		client.jobMtx.Unlock()
+	}()
+
+	var sb strings.Builder
+	sb.WriteString("bgapi ")
+	sb.WriteString(command)
+	if arg != "" {
+		sb.WriteString(" ")
+		sb.WriteString(arg)
+	}
+	response, err := client.sendCommand(&Command{Lines: []string{sb.String(), "Job-UUID: " + jobUuid}, Context: ctx})
+	if err != nil {
+		return nil, err
+	}
+	if replyText := response.GetHeaderValue(REPLY_TEXT); !strings.HasPrefix(replyText, OK) {
+		return nil, errors.New("bgapi " + command + " rejected: " + replyText)
+	}
+	select {
+	case event := <-waiter:
+		return event, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// jobCompleted - Hand a BACKGROUND_JOB event to the command waiting for it, if any.
+func (client *Client) jobCompleted(event *EslEvent) {
+	jobUuid := (*event.GetEventHeaders())["Job-UUID"]
+	client.jobMtx.Lock()
+	waiter := client.jobWaiters[jobUuid]
+	client.jobMtx.Unlock()
+	if waiter != nil {
+		select {
+		case waiter <- event:
+		default:
+		}
+	}
+}
+
+// newUuid - A random version 4 UUID, as FreeSWITCH generates them.
+func newUuid() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
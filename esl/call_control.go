@@ -0,0 +1,293 @@
+package esl
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// TRANSFER_LEG_A - uuid_transfer the channel itself.
+	TRANSFER_LEG_A = ""
+	// TRANSFER_LEG_B - uuid_transfer the channel bridged to it.
+	TRANSFER_LEG_B = "-bleg"
+	// TRANSFER_LEG_BOTH - uuid_transfer both channels.
+	TRANSFER_LEG_BOTH = "-both"
+
+	BROADCAST_LEG_A    = "aleg"
+	BROADCAST_LEG_B    = "bleg"
+	BROADCAST_LEG_BOTH = "both"
+
+	// UNDEFINED_VARIABLE - What uuid_getvar answers for a variable which is not set.
+	UNDEFINED_VARIABLE = "_undef_"
+)
+
+// ApiError - An api command answered -ERR.
+type ApiError struct {
+	Command string
+	Arg     string
+	Reply   string
+}
+
+func (e *ApiError) Error() string {
+	return e.Command + " " + e.Arg + ": " + e.Reply
+}
+
+// ChannelNotFoundError - A uuid_* command named a channel which does not exist.
+type ChannelNotFoundError struct {
+	Command string
+	Uuid    string
+	Reply   string
+}
+
+func (e *ChannelNotFoundError) Error() string {
+	return e.Command + ": no such channel " + e.Uuid
+}
+
+// UuidKill - uuid_kill <uuid> [cause], the cause defaults to NORMAL_CLEARING.
+func (client *Client) UuidKill(uuid, cause string) error {
+	_, err := client.uuidApi("uuid_kill", uuid, cause)
+	return err
+}
+
+// UuidTransfer - uuid_transfer <uuid> [-bleg|-both] <destination> [dialplan] [context]
+//   - @param leg TRANSFER_LEG_A, TRANSFER_LEG_B or TRANSFER_LEG_BOTH
+//   - @param dialplan may be empty for XML, inline transfers use "inline"
+func (client *Client) UuidTransfer(uuid, leg, destination, dialplan, dialplanContext string) error {
+	args := quoteArg(destination)
+	if leg != "" {
+		args = leg + " " + args
+	}
+	if dialplan != "" || dialplanContext != "" {
+		if dialplan == "" {
+			dialplan = "XML"
+		}
+		args += " " + dialplan
+	}
+	if dialplanContext != "" {
+		args += " " + dialplanContext
+	}
+	_, err := client.uuidApi("uuid_transfer", uuid, args)
+	return err
+}
+
+// UuidTransferToDialString - Transfer the channel to an inline dialplan bridging it to the dial string.
+func (client *Client) UuidTransferToDialString(uuid, leg string, dialString *DialString) error {
+	return client.UuidTransfer(uuid, leg, dialString.InlineBridge(), "inline", "")
+}
+
+// UuidBridge - uuid_bridge <uuid> <other uuid>
+func (client *Client) UuidBridge(uuid, otherUuid string) error {
+	_, err := client.uuidApi("uuid_bridge", uuid, otherUuid)
+	return err
+}
+
+// UuidHold - uuid_hold <uuid>
+func (client *Client) UuidHold(uuid string) error {
+	_, err := client.SendApi("uuid_hold", uuid)
+	return client.uuidError("uuid_hold", uuid, err)
+}
+
+// UuidUnhold - uuid_hold off <uuid>
+func (client *Client) UuidUnhold(uuid string) error {
+	_, err := client.SendApi("uuid_hold", "off "+uuid)
+	return client.uuidError("uuid_hold", uuid, err)
+}
+
+// UuidToggleHold - uuid_hold toggle <uuid>
+func (client *Client) UuidToggleHold(uuid string) error {
+	_, err := client.SendApi("uuid_hold", "toggle "+uuid)
+	return client.uuidError("uuid_hold", uuid, err)
+}
+
+// UuidBreak - uuid_break <uuid> [all], stop the current media, all flushes the queued ones too.
+func (client *Client) UuidBreak(uuid string, all bool) error {
+	args := ""
+	if all {
+		args = "all"
+	}
+	_, err := client.uuidApi("uuid_break", uuid, args)
+	return err
+}
+
+// UuidBroadcast - uuid_broadcast <uuid> <path> [aleg|bleg|both]
+//   - @param path a file or app::args, for example playback::/tmp/a.wav
+//   - @param leg BROADCAST_LEG_A, BROADCAST_LEG_B or BROADCAST_LEG_BOTH, empty for the default aleg
+func (client *Client) UuidBroadcast(uuid, path, leg string) error {
+	args := quoteArg(path)
+	if leg != "" {
+		args += " " + leg
+	}
+	_, err := client.uuidApi("uuid_broadcast", uuid, args)
+	return err
+}
+
+// UuidSetVar - uuid_setvar <uuid> <name> [value], an empty value unsets the variable.
+func (client *Client) UuidSetVar(uuid, name, value string) error {
+	args := name
+	if value != "" {
+		args += " " + quoteArg(value)
+	}
+	_, err := client.uuidApi("uuid_setvar", uuid, args)
+	return err
+}
+
+// UuidGetVar - uuid_getvar <uuid> <name>
+//   - @return the value, false when the variable is not set
+func (client *Client) UuidGetVar(uuid, name string) (string, bool, error) {
+	body, err := client.uuidApi("uuid_getvar", uuid, name)
+	if err != nil {
+		return "", false, err
+	}
+	if body == UNDEFINED_VARIABLE {
+		return "", false, nil
+	}
+	return body, true, nil
+}
+
+// UuidSetVarMulti - uuid_setvar_multi <uuid> <name>=<value>;<name>=<value>, the semicolons of the values are escaped.
+func (client *Client) UuidSetVarMulti(uuid string, variables map[string]string) error {
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+"="+strings.Replace(variables[name], ";", "\\;", -1))
+	}
+	_, err := client.uuidApi("uuid_setvar_multi", uuid, quoteArg(strings.Join(pairs, ";")))
+	return err
+}
+
+// UuidRecord - uuid_record <uuid> start <path> [limit seconds], 0 means no limit.
+func (client *Client) UuidRecord(uuid, path string, limitSeconds int) error {
+	args := "start " + quoteArg(path)
+	if limitSeconds > 0 {
+		args += " " + strconv.Itoa(limitSeconds)
+	}
+	_, err := client.uuidApi("uuid_record", uuid, args)
+	return err
+}
+
+// UuidStopRecord - uuid_record <uuid> stop <path>, the path "all" stops every recording of the channel.
+func (client *Client) UuidStopRecord(uuid, path string) error {
+	_, err := client.uuidApi("uuid_record", uuid, "stop "+quoteArg(path))
+	return err
+}
+
+// UuidPark - uuid_park <uuid>
+func (client *Client) UuidPark(uuid string) error {
+	_, err := client.uuidApi("uuid_park", uuid, "")
+	return err
+}
+
+// UuidAnswer - uuid_answer <uuid>
+func (client *Client) UuidAnswer(uuid string) error {
+	_, err := client.uuidApi("uuid_answer", uuid, "")
+	return err
+}
+
+// UuidExists - uuid_exists <uuid>
+func (client *Client) UuidExists(uuid string) (bool, error) {
+	body, err := client.uuidApi("uuid_exists", uuid, "")
+	if err != nil {
+		return false, err
+	}
+	return body == "true", nil
+}
+
+// UuidDump - uuid_dump <uuid>
+//   - @return the channel headers and variables, variables are named variable_<name>
+func (client *Client) UuidDump(uuid string) (map[string]string, error) {
+	body, err := client.uuidApi("uuid_dump", uuid, "")
+	if err != nil {
+		return nil, err
+	}
+	return parseUuidDump(body), nil
+}
+
+// SendApi - Send an api command.
+//   - @return the trimmed response body, an *ApiError when it starts with -ERR
+func (client *Client) SendApi(command, arg string) (string, error) {
+	message, err := client.SendSyncApiCommand(command, arg)
+	if err != nil {
+		return "", err
+	}
+	body := strings.TrimSpace(message.getBody())
+	if strings.HasPrefix(body, "-ERR") || strings.HasPrefix(body, "-USAGE") {
+		return "", &ApiError{Command: command, Arg: arg, Reply: body}
+	}
+	return body, nil
+}
+
+func (client *Client) uuidApi(command, uuid, args string) (string, error) {
+	arg := uuid
+	if args != "" {
+		arg += " " + args
+	}
+	body, err := client.SendApi(command, arg)
+	return body, client.uuidError(command, uuid, err)
+}
+
+// uuidError - Turn the *ApiError of a missing channel into a *ChannelNotFoundError.
+func (client *Client) uuidError(command, uuid string, err error) error {
+	apiError, ok := err.(*ApiError)
+	if !ok {
+		return err
+	}
+	reply := strings.ToLower(apiError.Reply)
+	if strings.Contains(reply, "no such channel") || strings.Contains(reply, "cannot locate session") ||
+		strings.Contains(reply, "invalid uuid") {
+		return &ChannelNotFoundError{Command: command, Uuid: uuid, Reply: apiError.Reply}
+	}
+	return err
+}
+
+// parseUuidDump - "Name: value" lines, the values are url encoded.
+func parseUuidDump(body string) map[string]string {
+	headers := make(map[string]string)
+	for _, line := range strings.Split(body, LINE_TERMINATOR) {
+		index := strings.Index(line, ": ")
+		if index <= 0 {
+			continue
+		}
+		value := strings.TrimSpace(line[index+2:])
+		if decoded, err := url.QueryUnescape(value); err == nil {
+			value = decoded
+		}
+		headers[line[:index]] = value
+	}
+	return headers
+}
+
+// Hangup - Hang up the channel, the cause defaults to NORMAL_CLEARING.
+func (h *CallHandle) Hangup(cause string) error {
+	return h.client.UuidKill(h.Uuid, cause)
+}
+
+// Transfer - Transfer the channel to an extension of the dialplan.
+func (h *CallHandle) Transfer(destination, dialplan, dialplanContext string) error {
+	return h.client.UuidTransfer(h.Uuid, TRANSFER_LEG_A, destination, dialplan, dialplanContext)
+}
+
+// Bridge - Bridge the channel with another one.
+func (h *CallHandle) Bridge(otherUuid string) error {
+	return h.client.UuidBridge(h.Uuid, otherUuid)
+}
+
+// SetVariable - Set a variable of the channel.
+func (h *CallHandle) SetVariable(name, value string) error {
+	return h.client.UuidSetVar(h.Uuid, name, value)
+}
+
+// GetVariable - Get a variable of the channel, false when it is not set.
+func (h *CallHandle) GetVariable(name string) (string, bool, error) {
+	return h.client.UuidGetVar(h.Uuid, name)
+}
+
+// Exists - Whether the channel still exists.
+func (h *CallHandle) Exists() (bool, error) {
+	return h.client.UuidExists(h.Uuid)
+}
@@ -0,0 +1,87 @@
+package esl_test
+
+import (
+	"testing"
+
+	"github.com/zhouhailin/freeswitch-esl-go/esl"
+)
+
+func TestUuidCommands(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	for _, command := range []string{"uuid_kill", "uuid_transfer", "uuid_bridge", "uuid_break", "uuid_park",
+		"uuid_answer", "uuid_setvar"} {
+		server.SetApiResponse(command, "+OK\n")
+	}
+	connect(t, server, client)
+	calls := []struct {
+		call func() error
+		line string
+	}{
+		{func() error { return client.UuidKill("u1", "USER_BUSY") }, "api uuid_kill u1 USER_BUSY"},
+		{func() error { return client.UuidTransfer("u1", esl.TRANSFER_LEG_B, "1000", "", "") },
+			"api uuid_transfer u1 -bleg 1000"},
+		{func() error { return client.UuidTransfer("u1", esl.TRANSFER_LEG_A, "1000", "", "public") },
+			"api uuid_transfer u1 1000 XML public"},
+		{func() error { return client.UuidBridge("u1", "u2") }, "api uuid_bridge u1 u2"},
+		{func() error { return client.UuidBreak("u1", true) }, "api uuid_break u1 all"},
+		{func() error { return client.UuidPark("u1") }, "api uuid_park u1"},
+		{func() error { return client.UuidAnswer("u1") }, "api uuid_answer u1"},
+		{func() error { return client.UuidSetVar("u1", "foo", "bar") }, "api uuid_setvar u1 foo bar"},
+		{func() error { return client.UuidSetVar("u1", "greeting", "it's me") },
+			`api uuid_setvar u1 greeting 'it\'s me'`},
+		{func() error { return client.UuidTransfer("u1", "", "O'Brien", "", "") }, `api uuid_transfer u1 'O\'Brien'`},
+	}
+	for _, c := range calls {
+		if err := c.call(); err != nil {
+			t.Fatalf("%s: %v", c.line, err)
+		}
+		commands := server.Commands()
+		if got := commands[len(commands)-1].Line; got != c.line {
+			t.Fatalf("sent %q, want %q", got, c.line)
+		}
+	}
+}
+
+func TestUuidGetVar(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	server.HandleApi("uuid_getvar", func(args string) string {
+		if args == "u1 foo" {
+			return "bar"
+		}
+		return esl.UNDEFINED_VARIABLE
+	})
+	connect(t, server, client)
+	if value, ok, err := client.UuidGetVar("u1", "foo"); err != nil || !ok || value != "bar" {
+		t.Fatalf("got %q %v %v", value, ok, err)
+	}
+	if _, ok, err := client.UuidGetVar("u1", "missing"); err != nil || ok {
+		t.Fatalf("missing variable: %v %v", ok, err)
+	}
+}
+
+func TestUuidErrors(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	server.SetApiResponse("uuid_kill", "-ERR No such channel!\n")
+	server.SetApiResponse("uuid_park", "-ERR something else\n")
+	connect(t, server, client)
+	err := client.UuidKill("gone", "")
+	if notFound, ok := err.(*esl.ChannelNotFoundError); !ok || notFound.Uuid != "gone" {
+		t.Fatalf("expected a ChannelNotFoundError, got %v", err)
+	}
+	if _, ok := client.UuidPark("u1").(*esl.ApiError); !ok {
+		t.Fatal("expected an ApiError")
+	}
+}
+
+func TestUuidDump(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	server.SetApiResponse("uuid_dump", "Unique-ID: u1\nChannel-State: CS_EXECUTE\nvariable_sip_from_user: 1000%40x\n")
+	connect(t, server, client)
+	headers, err := client.UuidDump("u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if headers["Channel-State"] != "CS_EXECUTE" || headers["variable_sip_from_user"] != "1000@x" {
+		t.Fatalf("unexpected headers %v", headers)
+	}
+}
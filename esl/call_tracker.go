@@ -0,0 +1,459 @@
+package esl
+
+import (
+	"github.com/bytedance/gopkg/util/logger"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CALL_TRACKER_EVENTS - the events a CallTracker subscribes to
+const CALL_TRACKER_EVENTS = "CHANNEL_CREATE CHANNEL_ANSWER CHANNEL_BRIDGE CHANNEL_UNBRIDGE CHANNEL_STATE CHANNEL_CALLSTATE " +
+	"CHANNEL_HANGUP_COMPLETE CHANNEL_DESTROY"
+
+// CallChangeType - What happened to a tracked call.
+type CallChangeType int
+
+const (
+	CALL_STARTED CallChangeType = iota
+	CALL_ANSWERED
+	CALL_BRIDGED
+	CALL_TRANSFERRED
+	CALL_MERGED
+	CALL_ENDED
+)
+
+// CallChange - A change of a tracked call, Event is nil when the change comes from a resynchronization.
+//   - A CALL_MERGED change carries the call that was absorbed by another call, see Call.MergedInto.
+type CallChange struct {
+	Type  CallChangeType
+	Call  *Call
+	Event *EslEvent
+}
+
+// CallLeg - One channel of a call.
+type CallLeg struct {
+	Uuid              string
+	Name              string
+	Direction         string
+	CallerIdName      string
+	CallerIdNumber    string
+	DestinationNumber string
+	HangupCause       string
+	// BridgedTo - the uuid of the leg this leg is, or was last, bridged to
+	BridgedTo      string
+	CreatedAt      time.Time
+	AnsweredAt     time.Time
+	BridgedAt      time.Time
+	HangupAt       time.Time
+	transferSource string
+}
+
+// IsEnded - Convenience method.
+//   - @return true if the leg has hung up
+func (l *CallLeg) IsEnded() bool {
+	return !l.HangupAt.IsZero()
+}
+
+// CallTransfer - A transfer that happened during a call.
+type CallTransfer struct {
+	// Attended - true for an attended transfer, false for a blind transfer
+	Attended bool
+	// Uuid - the transferred leg
+	Uuid string
+	// FromUuid - the leg Uuid was bridged to before an attended transfer
+	FromUuid string
+	// ToUuid - the leg Uuid is bridged to after an attended transfer
+	ToUuid string
+	// Destination - the extension/context/dialplan of a blind transfer
+	Destination string
+	At          time.Time
+}
+
+// Call - Legs joined by originate and bridge, identified by the uuid of the first leg.
+type Call struct {
+	Id          string
+	Legs        []*CallLeg
+	Transfers   []*CallTransfer
+	HangupCause string
+	MergedInto  string
+	StartedAt   time.Time
+	AnsweredAt  time.Time
+	BridgedAt   time.Time
+	EndedAt     time.Time
+}
+
+// CallSummary - The figures of a call, usually read from a CALL_ENDED change.
+type CallSummary struct {
+	Id                string
+	CallerIdName      string
+	CallerIdNumber    string
+	DestinationNumber string
+	HangupCause       string
+	Legs              int
+	Transfers         int
+	StartedAt         time.Time
+	AnsweredAt        time.Time
+	EndedAt           time.Time
+	// Duration - from start to end
+	Duration time.Duration
+	// BillDuration - from answer to end, 0 when the call was not answered
+	BillDuration time.Duration
+	// WaitDuration - from start to answer, or to end when the call was not answered
+	WaitDuration time.Duration
+}
+
+// Leg - Lookup a leg of the call.
+func (c *Call) Leg(uuid string) *CallLeg {
+	for _, leg := range c.Legs {
+		if leg.Uuid == uuid {
+			return leg
+		}
+	}
+	return nil
+}
+
+// Summary - The figures of the call, durations are measured up to now while the call is not ended.
+func (c *Call) Summary() *CallSummary {
+	end := c.EndedAt
+	if end.IsZero() {
+		end = time.Now()
+	}
+	summary := &CallSummary{
+		Id:          c.Id,
+		HangupCause: c.HangupCause,
+		Legs:        len(c.Legs),
+		Transfers:   len(c.Transfers),
+		StartedAt:   c.StartedAt,
+		AnsweredAt:  c.AnsweredAt,
+		EndedAt:     c.EndedAt,
+		Duration:    end.Sub(c.StartedAt),
+	}
+	if len(c.Legs) > 0 {
+		summary.CallerIdName = c.Legs[0].CallerIdName
+		summary.CallerIdNumber = c.Legs[0].CallerIdNumber
+		summary.DestinationNumber = c.Legs[0].DestinationNumber
+	}
+	if c.AnsweredAt.IsZero() {
+		summary.WaitDuration = summary.Duration
+	} else {
+		summary.BillDuration = end.Sub(c.AnsweredAt)
+		summary.WaitDuration = c.AnsweredAt.Sub(c.StartedAt)
+	}
+	return summary
+}
+
+func (c *Call) clone() *Call {
+	clone := *c
+	clone.Legs = make([]*CallLeg, len(c.Legs))
+	for i, leg := range c.Legs {
+		l := *leg
+		clone.Legs[i] = &l
+	}
+	clone.Transfers = make([]*CallTransfer, len(c.Transfers))
+	for i, transfer := range c.Transfers {
+		t := *transfer
+		clone.Transfers[i] = &t
+	}
+	return &clone
+}
+
+// CallTracker - Groups the channels of a Client into calls from its channel events.
+//   - A leg joins the call of its Other-Leg-Unique-ID when it is created, and the calls of two legs are merged when
+//   - they are bridged. Attended transfers are detected when a leg is bridged to another partner, blind transfers
+//   - from the transfer_source variable.
+type CallTracker struct {
+	listenerBase
+	client    *Client
+	mtx       sync.RWMutex
+	calls     map[string]*Call
+	legs      map[string]*Call
+	callbacks []func(change *CallChange)
+}
+
+// NewCallTracker - Constructor, registers the tracker as event and connection listener of client.
+func NewCallTracker(client *Client) *CallTracker {
+	t := &CallTracker{
+		client: client,
+		calls:  make(map[string]*Call),
+		legs:   make(map[string]*Call),
+	}
+	client.AddEventListener(t)
+	client.AddConnectionListener(t)
+	return t
+}
+
+// Get - Lookup a call by its id.
+//   - @return a copy of the call, false if it is not in progress
+func (t *CallTracker) Get(id string) (*Call, bool) {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	call, ok := t.calls[id]
+	if !ok {
+		return nil, false
+	}
+	return call.clone(), true
+}
+
+// GetByLeg - Lookup a call by the uuid of any of its legs.
+func (t *CallTracker) GetByLeg(uuid string) (*Call, bool) {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	call, ok := t.legs[uuid]
+	if !ok {
+		return nil, false
+	}
+	return call.clone(), true
+}
+
+// List - A copy of every call in progress.
+func (t *CallTracker) List() []*Call {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	calls := make([]*Call, 0, len(t.calls))
+	for _, call := range t.calls {
+		calls = append(calls, call.clone())
+	}
+	return calls
+}
+
+// Count - The number of calls in progress.
+func (t *CallTracker) Count() int {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	return len(t.calls)
+}
+
+// OnChange - Register a callback, it is called from the event dispatch goroutine in the order of the changes.
+func (t *CallTracker) OnChange(callback func(change *CallChange)) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.callbacks = append(t.callbacks, callback)
+}
+
+// Sync - Subscribe to the channel events and end the calls whose legs are gone from "show channels as json".
+func (t *CallTracker) Sync() error {
+	if err := subscribe(t.client, CALL_TRACKER_EVENTS, "Call"); err != nil {
+		return err
+	}
+	message, err := t.client.SendSyncApiCommand("show", "channels as json")
+	if err != nil {
+		return err
+	}
+	rows, err := parseShowJson(message.getBody())
+	if err != nil {
+		return err
+	}
+	live := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		live[row["uuid"]] = true
+	}
+	var changes []*CallChange
+	now := time.Now()
+	t.mtx.Lock()
+	for uuid, call := range t.legs {
+		if leg := call.Leg(uuid); !live[uuid] && !leg.IsEnded() {
+			leg.HangupAt = now
+			if change := t.hangup(call, nil, now); change != nil {
+				changes = append(changes, change)
+			}
+		}
+	}
+	callbacks := t.callbacks
+	t.mtx.Unlock()
+	runCallCallbacks(callbacks, changes)
+	return nil
+}
+
+// EventReceived - Implements IEslEventListener.
+func (t *CallTracker) EventReceived(event *EslEvent) error {
+	name := event.GetEventName()
+	headers := *event.GetEventHeaders()
+	uuid := headers["Unique-ID"]
+	if uuid == "" || !strings.HasPrefix(name, "CHANNEL_") {
+		return nil
+	}
+	at := eventTime(event)
+	var changes []*CallChange
+	t.mtx.Lock()
+	call, ok := t.legs[uuid]
+	if name == "CHANNEL_HANGUP_COMPLETE" || name == "CHANNEL_DESTROY" {
+		if ok {
+			leg := call.Leg(uuid)
+			if !leg.IsEnded() {
+				leg.HangupAt = at
+				setIfPresent(&leg.HangupCause, headers["Hangup-Cause"])
+				if call.HangupCause == "" {
+					call.HangupCause = leg.HangupCause
+				}
+				if change := t.hangup(call, event, at); change != nil {
+					changes = append(changes, change)
+				}
+			}
+		}
+		callbacks := t.callbacks
+		t.mtx.Unlock()
+		runCallCallbacks(callbacks, changes)
+		return nil
+	}
+	if !ok {
+		if name != "CHANNEL_CREATE" && channelEnded(headers) {
+			// a late event of a leg whose call already ended, for example CHANNEL_STATE CS_DESTROY
+			t.mtx.Unlock()
+			return nil
+		}
+		call, changes = t.join(uuid, headers["Other-Leg-Unique-ID"], event, at)
+	}
+	leg := call.Leg(uuid)
+	leg.apply(headers)
+	switch name {
+	case "CHANNEL_ANSWER":
+		if leg.AnsweredAt.IsZero() {
+			leg.AnsweredAt = at
+		}
+		if call.AnsweredAt.IsZero() {
+			call.AnsweredAt = at
+			changes = append(changes, &CallChange{Type: CALL_ANSWERED, Call: call.clone(), Event: event})
+		}
+	case "CHANNEL_BRIDGE":
+		changes = append(changes, t.bridge(headers, event, at)...)
+	}
+	if source := headers["variable_transfer_source"]; source != "" && source != leg.transferSource {
+		leg.transferSource = source
+		// epoch:uuid:bl_xfer:extension/context/dialplan
+		if parts := strings.SplitN(source, ":", 4); len(parts) == 4 && parts[2] == "bl_xfer" {
+			call.Transfers = append(call.Transfers, &CallTransfer{Uuid: uuid, Destination: parts[3], At: at})
+			changes = append(changes, &CallChange{Type: CALL_TRANSFERRED, Call: call.clone(), Event: event})
+		}
+	}
+	callbacks := t.callbacks
+	t.mtx.Unlock()
+	runCallCallbacks(callbacks, changes)
+	return nil
+}
+
+// join - Add a new leg to the call of its other leg, or start a new call. Must be called with the lock held.
+func (t *CallTracker) join(uuid, otherUuid string, event *EslEvent, at time.Time) (*Call, []*CallChange) {
+	leg := &CallLeg{Uuid: uuid, CreatedAt: at}
+	if call, ok := t.legs[otherUuid]; ok {
+		call.Legs = append(call.Legs, leg)
+		t.legs[uuid] = call
+		return call, nil
+	}
+	call := &Call{Id: uuid, Legs: []*CallLeg{leg}, StartedAt: at}
+	t.calls[uuid] = call
+	t.legs[uuid] = call
+	return call, []*CallChange{{Type: CALL_STARTED, Call: call.clone(), Event: event}}
+}
+
+// bridge - Record a bridge, merging the calls of the two legs. Must be called with the lock held.
+func (t *CallTracker) bridge(headers map[string]string, event *EslEvent, at time.Time) []*CallChange {
+	aUuid, bUuid := headers["Bridge-A-Unique-ID"], headers["Bridge-B-Unique-ID"]
+	if aUuid == "" || bUuid == "" {
+		aUuid, bUuid = headers["Unique-ID"], headers["Other-Leg-Unique-ID"]
+	}
+	var changes []*CallChange
+	call, ok := t.legs[aUuid]
+	if !ok || bUuid == "" {
+		return nil
+	}
+	other, ok := t.legs[bUuid]
+	if !ok {
+		var started []*CallChange
+		other, started = t.join(bUuid, aUuid, event, at)
+		changes = append(changes, started...)
+	}
+	if other != call {
+		for _, leg := range other.Legs {
+			t.legs[leg.Uuid] = call
+		}
+		call.Legs = append(call.Legs, other.Legs...)
+		call.Transfers = append(call.Transfers, other.Transfers...)
+		if other.StartedAt.Before(call.StartedAt) {
+			call.StartedAt = other.StartedAt
+		}
+		if call.AnsweredAt.IsZero() || (!other.AnsweredAt.IsZero() && other.AnsweredAt.Before(call.AnsweredAt)) {
+			call.AnsweredAt = other.AnsweredAt
+		}
+		delete(t.calls, other.Id)
+		other.MergedInto = call.Id
+		changes = append(changes, &CallChange{Type: CALL_MERGED, Call: other.clone(), Event: event})
+	}
+	transferred := false
+	aLeg, bLeg := call.Leg(aUuid), call.Leg(bUuid)
+	for _, pair := range [][2]*CallLeg{{aLeg, bLeg}, {bLeg, aLeg}} {
+		leg, partner := pair[0], pair[1]
+		if !transferred && leg.BridgedTo != "" && leg.BridgedTo != partner.Uuid {
+			call.Transfers = append(call.Transfers, &CallTransfer{
+				Attended: true,
+				Uuid:     leg.Uuid,
+				FromUuid: leg.BridgedTo,
+				ToUuid:   partner.Uuid,
+				At:       at,
+			})
+			transferred = true
+		}
+		leg.BridgedTo = partner.Uuid
+		leg.BridgedAt = at
+	}
+	if call.BridgedAt.IsZero() {
+		call.BridgedAt = at
+		changes = append(changes, &CallChange{Type: CALL_BRIDGED, Call: call.clone(), Event: event})
+	}
+	if transferred {
+		changes = append(changes, &CallChange{Type: CALL_TRANSFERRED, Call: call.clone(), Event: event})
+	}
+	return changes
+}
+
+// hangup - End the call once all its legs are ended. Must be called with the lock held.
+func (t *CallTracker) hangup(call *Call, event *EslEvent, at time.Time) *CallChange {
+	for _, leg := range call.Legs {
+		if !leg.IsEnded() {
+			return nil
+		}
+	}
+	call.EndedAt = at
+	delete(t.calls, call.Id)
+	for _, leg := range call.Legs {
+		delete(t.legs, leg.Uuid)
+	}
+	return &CallChange{Type: CALL_ENDED, Call: call.clone(), Event: event}
+}
+
+func (l *CallLeg) apply(headers map[string]string) {
+	setIfPresent(&l.Name, headers["Channel-Name"])
+	setIfPresent(&l.Direction, headers["Call-Direction"])
+	setIfPresent(&l.CallerIdName, headers["Caller-Caller-ID-Name"])
+	setIfPresent(&l.CallerIdNumber, headers["Caller-Caller-ID-Number"])
+	setIfPresent(&l.DestinationNumber, headers["Caller-Destination-Number"])
+	if t := parseMicroseconds(headers["Caller-Channel-Created-Time"]); !t.IsZero() {
+		l.CreatedAt = t
+	}
+}
+
+func runCallCallbacks(callbacks []func(change *CallChange), changes []*CallChange) {
+	for _, change := range changes {
+		for _, callback := range callbacks {
+			callback(change)
+		}
+	}
+}
+
+// Authenticated - Implements IEslConnectionListener, ends the calls that ended while disconnected.
+func (t *CallTracker) Authenticated(authenticated bool, c *Client) {
+	if !authenticated {
+		return
+	}
+	if err := t.Sync(); err != nil {
+		logger.Errorf("Call tracker synchronization failure, cause %v\n", err)
+	}
+}
+
+// eventTime - The Event-Date-Timestamp of an event, now when it is missing.
+func eventTime(event *EslEvent) time.Time {
+	if t := parseMicroseconds(event.GetEventDateTimestamp()); !t.IsZero() {
+		return t
+	}
+	return time.Now()
+}
@@ -0,0 +1,51 @@
+package esl_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/zhouhailin/freeswitch-esl-go/esl"
+	"github.com/zhouhailin/freeswitch-esl-go/esl/esltest"
+)
+
+func TestCallTrackerIgnoresLateEventsOfEndedCalls(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	server.SetApiResponse("show", `{"row_count":0}`)
+	tracker := esl.NewCallTracker(client)
+	var mtx sync.Mutex
+	var changes []esl.CallChangeType
+	tracker.OnChange(func(change *esl.CallChange) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		changes = append(changes, change.Type)
+	})
+	conn := connect(t, server, client)
+	waitCommand(t, server, "api show channels")
+
+	conn.SendEvent(esltest.NewEvent("CHANNEL_CREATE").Set("Unique-ID", "a").Set("Channel-State", "CS_INIT"))
+	conn.SendEvent(esltest.NewEvent("CHANNEL_ANSWER").Set("Unique-ID", "a").Set("Channel-State", "CS_EXECUTE"))
+	conn.SendEvent(esltest.NewEvent("CHANNEL_HANGUP_COMPLETE").Set("Unique-ID", "a").
+		Set("Channel-State", "CS_REPORTING").Set("Hangup-Cause", "NORMAL_CLEARING"))
+	conn.SendEvent(esltest.NewEvent("CHANNEL_STATE").Set("Unique-ID", "a").Set("Channel-State", "CS_DESTROY"))
+	conn.SendEvent(esltest.NewEvent("CHANNEL_DESTROY").Set("Unique-ID", "a").Set("Channel-State", "CS_DESTROY"))
+	conn.SendEvent(esltest.NewEvent("CHANNEL_CREATE").Set("Unique-ID", "b").Set("Channel-State", "CS_INIT"))
+	eventually(t, "call b", func() bool {
+		_, ok := tracker.Get("b")
+		return ok
+	})
+
+	if _, ok := tracker.GetByLeg("a"); ok {
+		t.Error("leg a started a new call")
+	}
+	mtx.Lock()
+	defer mtx.Unlock()
+	want := []esl.CallChangeType{esl.CALL_STARTED, esl.CALL_ANSWERED, esl.CALL_ENDED, esl.CALL_STARTED}
+	if len(changes) != len(want) {
+		t.Fatalf("changes %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Fatalf("changes %v, want %v", changes, want)
+		}
+	}
+}
@@ -0,0 +1,520 @@
+package esl
+
+import (
+	"errors"
+	"github.com/bytedance/gopkg/util/logger"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CALLCENTER_EVENTS - the events a Callcenter subscribes to
+const CALLCENTER_EVENTS = "CUSTOM callcenter::info"
+
+// mod_callcenter agent types
+const (
+	AGENT_TYPE_CALLBACK     = "callback"
+	AGENT_TYPE_UUID_STANDBY = "uuid-standby"
+)
+
+// mod_callcenter agent statuses
+const (
+	AGENT_STATUS_LOGGED_OUT          = "Logged Out"
+	AGENT_STATUS_AVAILABLE           = "Available"
+	AGENT_STATUS_AVAILABLE_ON_DEMAND = "Available (On Demand)"
+	AGENT_STATUS_ON_BREAK            = "On Break"
+)
+
+// mod_callcenter agent states
+const (
+	AGENT_STATE_IDLE            = "Idle"
+	AGENT_STATE_WAITING         = "Waiting"
+	AGENT_STATE_RECEIVING       = "Receiving"
+	AGENT_STATE_IN_A_QUEUE_CALL = "In a queue call"
+)
+
+// mod_callcenter tier states
+const (
+	TIER_STATE_UNKNOWN   = "Unknown"
+	TIER_STATE_NO_ANSWER = "No Answer"
+	TIER_STATE_READY     = "Ready"
+	TIER_STATE_OFFERING  = "Offering"
+	TIER_STATE_ACTIVE    = "Active"
+	TIER_STATE_STANDBY   = "Standby"
+)
+
+// CC-Action values of the callcenter::info events
+const (
+	CC_AGENT_STATUS_CHANGE = "agent-status-change"
+	CC_AGENT_STATE_CHANGE  = "agent-state-change"
+	CC_AGENT_OFFERING      = "agent-offering"
+	CC_BRIDGE_AGENT_START  = "bridge-agent-start"
+	CC_BRIDGE_AGENT_END    = "bridge-agent-end"
+	CC_BRIDGE_AGENT_FAIL   = "bridge-agent-fail"
+	CC_MEMBER_QUEUE_START  = "member-queue-start"
+	CC_MEMBER_QUEUE_END    = "member-queue-end"
+	CC_MEMBERS_COUNT       = "members-count"
+)
+
+// CallcenterAgent - A row of "callcenter_config agent list".
+type CallcenterAgent struct {
+	Name              string
+	InstanceId        string
+	Uuid              string
+	Type              string
+	Contact           string
+	Status            string
+	State             string
+	MaxNoAnswer       int
+	WrapUpTime        int
+	RejectDelayTime   int
+	BusyDelayTime     int
+	NoAnswerDelayTime int
+	LastBridgeStart   time.Time
+	LastBridgeEnd     time.Time
+	LastOfferedCall   time.Time
+	LastStatusChange  time.Time
+	NoAnswerCount     int
+	CallsAnswered     int
+	TalkTime          int
+	ReadyTime         int
+}
+
+// CallcenterTier - A row of "callcenter_config tier list".
+type CallcenterTier struct {
+	Queue    string
+	Agent    string
+	State    string
+	Level    int
+	Position int
+}
+
+// CallcenterMember - A row of "callcenter_config queue list members".
+type CallcenterMember struct {
+	Queue         string
+	InstanceId    string
+	Uuid          string
+	SessionUuid   string
+	CidNumber     string
+	CidName       string
+	JoinedAt      time.Time
+	RejoinedAt    time.Time
+	BridgedAt     time.Time
+	AbandonedAt   time.Time
+	BaseScore     int
+	SkillScore    int
+	ServingAgent  string
+	ServingSystem string
+	State         string
+	Score         int
+}
+
+// CallcenterQueue - A row of "callcenter_config queue list", Fields holds every column.
+type CallcenterQueue struct {
+	Name     string
+	Strategy string
+	MohSound string
+	Fields   map[string]string
+}
+
+// CallcenterEvent - A decoded callcenter::info event, only the fields of its Action are set.
+type CallcenterEvent struct {
+	Action            string
+	Queue             string
+	Agent             string
+	AgentStatus       string
+	AgentState        string
+	AgentType         string
+	AgentSystem       string
+	AgentUuid         string
+	MemberUuid        string
+	MemberSessionUuid string
+	MemberCidName     string
+	MemberCidNumber   string
+	Count             int
+	Selection         string
+	Cause             string
+	CancelReason      string
+	HangupCause       string
+	AgentCalledAt     time.Time
+	AgentAnsweredAt   time.Time
+	MemberJoinedAt    time.Time
+	MemberLeavingAt   time.Time
+	BridgeEndedAt     time.Time
+	Event             *EslEvent
+}
+
+// DecodeCallcenterEvent - Decode a callcenter::info event.
+//   - @return false if the event is not a callcenter::info event
+func DecodeCallcenterEvent(event *EslEvent) (*CallcenterEvent, bool) {
+	headers := *event.GetEventHeaders()
+	if event.GetEventName() != "CUSTOM" || headers["Event-Subclass"] != "callcenter::info" {
+		return nil, false
+	}
+	count, _ := strconv.Atoi(headers["CC-Count"])
+	return &CallcenterEvent{
+		Action:            headers["CC-Action"],
+		Queue:             headers["CC-Queue"],
+		Agent:             headers["CC-Agent"],
+		AgentStatus:       headers["CC-Agent-Status"],
+		AgentState:        headers["CC-Agent-State"],
+		AgentType:         headers["CC-Agent-Type"],
+		AgentSystem:       headers["CC-Agent-System"],
+		AgentUuid:         headers["CC-Agent-UUID"],
+		MemberUuid:        headers["CC-Member-UUID"],
+		MemberSessionUuid: headers["CC-Member-Session-UUID"],
+		MemberCidName:     headers["CC-Member-CID-Name"],
+		MemberCidNumber:   headers["CC-Member-CID-Number"],
+		Count:             count,
+		Selection:         headers["CC-Selection"],
+		Cause:             headers["CC-Cause"],
+		CancelReason:      headers["CC-Cancel-Reason"],
+		HangupCause:       headers["CC-Hangup-Cause"],
+		AgentCalledAt:     parseEpoch(headers["CC-Agent-Called-Time"]),
+		AgentAnsweredAt:   parseEpoch(headers["CC-Agent-Answered-Time"]),
+		MemberJoinedAt:    parseEpoch(headers["CC-Member-Joined-Time"]),
+		MemberLeavingAt:   parseEpoch(headers["CC-Member-Leaving-Time"]),
+		BridgeEndedAt:     parseEpoch(headers["CC-Bridge-Terminated-Time"]),
+		Event:             event,
+	}, true
+}
+
+// Callcenter - Typed mod_callcenter api and callcenter::info events of a Client.
+//   - The callcenter::info events are subscribed to every time the client authenticates once a callback is registered
+//   - with OnEvent, call Subscribe when the client is already connected.
+type Callcenter struct {
+	listenerBase
+	client    *Client
+	mtx       sync.RWMutex
+	callbacks []func(event *CallcenterEvent)
+}
+
+// NewCallcenter - Constructor, registers the callcenter as event and connection listener of client.
+func NewCallcenter(client *Client) *Callcenter {
+	cc := &Callcenter{client: client}
+	client.AddEventListener(cc)
+	client.AddConnectionListener(cc)
+	return cc
+}
+
+// OnEvent - Register a callback, it is called from the event dispatch goroutine in the order of the events.
+func (cc *Callcenter) OnEvent(callback func(event *CallcenterEvent)) {
+	cc.mtx.Lock()
+	defer cc.mtx.Unlock()
+	cc.callbacks = append(cc.callbacks, callback)
+}
+
+// Subscribe - Subscribe to the callcenter::info events.
+func (cc *Callcenter) Subscribe() error {
+	return subscribe(cc.client, CALLCENTER_EVENTS, "Callcenter")
+}
+
+// AddAgent - callcenter_config agent add <name> <type>
+func (cc *Callcenter) AddAgent(name, agentType string) error {
+	_, err := cc.Config("agent add " + quoteArg(name) + " " + agentType)
+	return err
+}
+
+// DeleteAgent - callcenter_config agent del <name>
+func (cc *Callcenter) DeleteAgent(name string) error {
+	_, err := cc.Config("agent del " + quoteArg(name))
+	return err
+}
+
+// SetAgentStatus - callcenter_config agent set status <name> <status>, see AGENT_STATUS_*.
+func (cc *Callcenter) SetAgentStatus(name, status string) error {
+	return cc.SetAgent(name, "status", status)
+}
+
+// SetAgentState - callcenter_config agent set state <name> <state>, see AGENT_STATE_*.
+func (cc *Callcenter) SetAgentState(name, state string) error {
+	return cc.SetAgent(name, "state", state)
+}
+
+// SetAgentContact - callcenter_config agent set contact <name> <contact>
+func (cc *Callcenter) SetAgentContact(name, contact string) error {
+	return cc.SetAgent(name, "contact", contact)
+}
+
+// SetAgent - callcenter_config agent set <key> <name> <value>, for example max_no_answer or wrap_up_time.
+//   - The name and the value are quoted when they hold spaces.
+func (cc *Callcenter) SetAgent(name, key, value string) error {
+	_, err := cc.Config("agent set " + key + " " + quoteArg(name) + " " + quoteArg(value))
+	return err
+}
+
+// ListAgents - callcenter_config agent list
+func (cc *Callcenter) ListAgents() ([]*CallcenterAgent, error) {
+	return cc.listAgents("agent list")
+}
+
+// GetAgent - callcenter_config agent list <name>
+//   - @return nil if the agent does not exist
+func (cc *Callcenter) GetAgent(name string) (*CallcenterAgent, error) {
+	agents, err := cc.listAgents("agent list " + quoteArg(name))
+	if err != nil || len(agents) == 0 {
+		return nil, err
+	}
+	return agents[0], nil
+}
+
+// AddTier - callcenter_config tier add <queue> <agent> <level> <position>
+func (cc *Callcenter) AddTier(queue, agent string, level, position int) error {
+	_, err := cc.Config("tier add " + quoteArg(queue) + " " + quoteArg(agent) + " " + strconv.Itoa(level) + " " + strconv.Itoa(position))
+	return err
+}
+
+// DeleteTier - callcenter_config tier del <queue> <agent>
+func (cc *Callcenter) DeleteTier(queue, agent string) error {
+	_, err := cc.Config("tier del " + quoteArg(queue) + " " + quoteArg(agent))
+	return err
+}
+
+// SetTierState - callcenter_config tier set state <queue> <agent> <state>, see TIER_STATE_*.
+func (cc *Callcenter) SetTierState(queue, agent, state string) error {
+	_, err := cc.Config("tier set state " + quoteArg(queue) + " " + quoteArg(agent) + " " + quoteArg(state))
+	return err
+}
+
+// SetTierLevel - callcenter_config tier set level <queue> <agent> <level>
+func (cc *Callcenter) SetTierLevel(queue, agent string, level int) error {
+	_, err := cc.Config("tier set level " + quoteArg(queue) + " " + quoteArg(agent) + " " + strconv.Itoa(level))
+	return err
+}
+
+// SetTierPosition - callcenter_config tier set position <queue> <agent> <position>
+func (cc *Callcenter) SetTierPosition(queue, agent string, position int) error {
+	_, err := cc.Config("tier set position " + quoteArg(queue) + " " + quoteArg(agent) + " " + strconv.Itoa(position))
+	return err
+}
+
+// ListTiers - callcenter_config tier list
+func (cc *Callcenter) ListTiers() ([]*CallcenterTier, error) {
+	return cc.listTiers("tier list")
+}
+
+// LoadQueue - callcenter_config queue load <queue>
+func (cc *Callcenter) LoadQueue(queue string) error {
+	_, err := cc.Config("queue load " + quoteArg(queue))
+	return err
+}
+
+// UnloadQueue - callcenter_config queue unload <queue>
+func (cc *Callcenter) UnloadQueue(queue string) error {
+	_, err := cc.Config("queue unload " + quoteArg(queue))
+	return err
+}
+
+// ReloadQueue - callcenter_config queue reload <queue>
+func (cc *Callcenter) ReloadQueue(queue string) error {
+	_, err := cc.Config("queue reload " + quoteArg(queue))
+	return err
+}
+
+// ListQueues - callcenter_config queue list
+func (cc *Callcenter) ListQueues() ([]*CallcenterQueue, error) {
+	rows, err := cc.configTable("queue list")
+	if err != nil {
+		return nil, err
+	}
+	queues := make([]*CallcenterQueue, 0, len(rows))
+	for _, row := range rows {
+		queues = append(queues, &CallcenterQueue{
+			Name:     row["name"],
+			Strategy: row["strategy"],
+			MohSound: row["moh_sound"],
+			Fields:   row,
+		})
+	}
+	return queues, nil
+}
+
+// CountMembers - callcenter_config queue count members <queue>
+func (cc *Callcenter) CountMembers(queue string) (int, error) {
+	body, err := cc.Config("queue count members " + quoteArg(queue))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(body))
+}
+
+// ListQueueMembers - callcenter_config queue list members <queue>
+func (cc *Callcenter) ListQueueMembers(queue string) ([]*CallcenterMember, error) {
+	rows, err := cc.configTable("queue list members " + quoteArg(queue))
+	if err != nil {
+		return nil, err
+	}
+	members := make([]*CallcenterMember, 0, len(rows))
+	for _, row := range rows {
+		members = append(members, &CallcenterMember{
+			Queue:         row["queue"],
+			InstanceId:    row["instance_id"],
+			Uuid:          row["uuid"],
+			SessionUuid:   row["session_uuid"],
+			CidNumber:     row["cid_number"],
+			CidName:       row["cid_name"],
+			JoinedAt:      parseEpoch(row["joined_epoch"]),
+			RejoinedAt:    parseEpoch(row["rejoined_epoch"]),
+			BridgedAt:     parseEpoch(row["bridge_epoch"]),
+			AbandonedAt:   parseEpoch(row["abandoned_epoch"]),
+			BaseScore:     atoi(row["base_score"]),
+			SkillScore:    atoi(row["skill_score"]),
+			ServingAgent:  row["serving_agent"],
+			ServingSystem: row["serving_system"],
+			State:         row["state"],
+			Score:         atoi(row["score"]),
+		})
+	}
+	return members, nil
+}
+
+// ListQueueAgents - callcenter_config queue list agents <queue>
+func (cc *Callcenter) ListQueueAgents(queue string) ([]*CallcenterAgent, error) {
+	return cc.listAgents("queue list agents " + quoteArg(queue))
+}
+
+// ListQueueTiers - callcenter_config queue list tiers <queue>
+func (cc *Callcenter) ListQueueTiers(queue string) ([]*CallcenterTier, error) {
+	return cc.listTiers("queue list tiers " + quoteArg(queue))
+}
+
+// Config - Send "callcenter_config <args>".
+//   - @return the response body, an error when it starts with -ERR
+func (cc *Callcenter) Config(args string) (string, error) {
+	message, err := cc.client.SendSyncApiCommand("callcenter_config", args)
+	if err != nil {
+		return "", err
+	}
+	body := message.getBody()
+	if strings.HasPrefix(body, "-ERR") {
+		return "", errors.New("callcenter_config " + args + ": " + strings.TrimSpace(body))
+	}
+	return body, nil
+}
+
+func (cc *Callcenter) configTable(args string) ([]map[string]string, error) {
+	body, err := cc.Config(args)
+	if err != nil {
+		return nil, err
+	}
+	return parsePipeTable(body), nil
+}
+
+func (cc *Callcenter) listAgents(args string) ([]*CallcenterAgent, error) {
+	rows, err := cc.configTable(args)
+	if err != nil {
+		return nil, err
+	}
+	agents := make([]*CallcenterAgent, 0, len(rows))
+	for _, row := range rows {
+		agents = append(agents, &CallcenterAgent{
+			Name:              row["name"],
+			InstanceId:        row["instance_id"],
+			Uuid:              row["uuid"],
+			Type:              row["type"],
+			Contact:           row["contact"],
+			Status:            row["status"],
+			State:             row["state"],
+			MaxNoAnswer:       atoi(row["max_no_answer"]),
+			WrapUpTime:        atoi(row["wrap_up_time"]),
+			RejectDelayTime:   atoi(row["reject_delay_time"]),
+			BusyDelayTime:     atoi(row["busy_delay_time"]),
+			NoAnswerDelayTime: atoi(row["no_answer_delay_time"]),
+			LastBridgeStart:   parseEpoch(row["last_bridge_start"]),
+			LastBridgeEnd:     parseEpoch(row["last_bridge_end"]),
+			LastOfferedCall:   parseEpoch(row["last_offered_call"]),
+			LastStatusChange:  parseEpoch(row["last_status_change"]),
+			NoAnswerCount:     atoi(row["no_answer_count"]),
+			CallsAnswered:     atoi(row["calls_answered"]),
+			TalkTime:          atoi(row["talk_time"]),
+			ReadyTime:         atoi(row["ready_time"]),
+		})
+	}
+	return agents, nil
+}
+
+func (cc *Callcenter) listTiers(args string) ([]*CallcenterTier, error) {
+	rows, err := cc.configTable(args)
+	if err != nil {
+		return nil, err
+	}
+	tiers := make([]*CallcenterTier, 0, len(rows))
+	for _, row := range rows {
+		tiers = append(tiers, &CallcenterTier{
+			Queue:    row["queue"],
+			Agent:    row["agent"],
+			State:    row["state"],
+			Level:    atoi(row["level"]),
+			Position: atoi(row["position"]),
+		})
+	}
+	return tiers, nil
+}
+
+// EventReceived - Implements IEslEventListener.
+func (cc *Callcenter) EventReceived(event *EslEvent) error {
+	decoded, ok := DecodeCallcenterEvent(event)
+	if !ok {
+		return nil
+	}
+	cc.mtx.RLock()
+	callbacks := cc.callbacks
+	cc.mtx.RUnlock()
+	for _, callback := range callbacks {
+		callback(decoded)
+	}
+	return nil
+}
+
+// Authenticated - Implements IEslConnectionListener, subscribes to the callcenter::info events when needed.
+func (cc *Callcenter) Authenticated(authenticated bool, c *Client) {
+	cc.mtx.RLock()
+	subscribe := len(cc.callbacks) > 0
+	cc.mtx.RUnlock()
+	if !authenticated || !subscribe {
+		return
+	}
+	if err := cc.Subscribe(); err != nil {
+		logger.Errorf("Callcenter subscription failure, cause %v\n", err)
+	}
+}
+
+// parsePipeTable - Parse the "a|b|c" tables printed by the api commands, ended by a +OK line.
+func parsePipeTable(body string) []map[string]string {
+	var columns []string
+	var rows []map[string]string
+	for _, line := range strings.Split(body, LINE_TERMINATOR) {
+		line = strings.TrimRight(line, "\r")
+		if line == "" || line == OK {
+			continue
+		}
+		values := strings.Split(line, "|")
+		if columns == nil {
+			columns = values
+			continue
+		}
+		row := make(map[string]string, len(columns))
+		for i, column := range columns {
+			if i < len(values) {
+				row[column] = values[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// parseEpoch - Parse seconds since epoch, zero when unset.
+func parseEpoch(value string) time.Time {
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
+
+func atoi(value string) int {
+	i, _ := strconv.Atoi(value)
+	return i
+}
@@ -0,0 +1,144 @@
+package esl_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zhouhailin/freeswitch-esl-go/esl"
+	"github.com/zhouhailin/freeswitch-esl-go/esl/esltest"
+)
+
+// callcenterConfig - Answer callcenter_config from responses, the unknown arguments are answered with -ERR.
+func callcenterConfig(server *esltest.Server, responses map[string]string) {
+	server.HandleApi("callcenter_config", func(args string) string {
+		if response, ok := responses[args]; ok {
+			return response
+		}
+		return "-ERR Unknown Command " + args + "\n"
+	})
+}
+
+func TestCallcenterLists(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	callcenterConfig(server, map[string]string{
+		"agent list": "name|instance_id|uuid|type|contact|status|state|max_no_answer|wrap_up_time|" +
+			"reject_delay_time|busy_delay_time|no_answer_delay_time|last_bridge_start|last_bridge_end|" +
+			"last_offered_call|last_status_change|no_answer_count|calls_answered|talk_time|ready_time\n" +
+			"1000@default|single_box||callback|[call_timeout=10]user/1000|Available|Waiting|3|10|0|0|0|" +
+			"1700000000|1700000060|1700000000|1699999000|1|12|600|0\n" +
+			"+OK\n",
+		"tier list": "queue|agent|state|level|position\n" +
+			"support@default|1000@default|Ready|1|2\n" +
+			"+OK\n",
+		"queue list members 'sales queue'": "queue|instance_id|uuid|session_uuid|cid_number|cid_name|" +
+			"system_epoch|joined_epoch|rejoined_epoch|bridge_epoch|abandoned_epoch|base_score|skill_score|" +
+			"serving_agent|serving_system|state|score\n" +
+			"sales queue|single_box|m1|s1|1001|Bob|1700000000|1700000000|0|0|0|0|0|||Waiting|30\n" +
+			"+OK\n",
+	})
+	callcenter := esl.NewCallcenter(client)
+	connect(t, server, client)
+
+	agents, err := callcenter.ListAgents()
+	if err != nil || len(agents) != 1 {
+		t.Fatalf("agents %v %v", agents, err)
+	}
+	agent := agents[0]
+	if agent.Name != "1000@default" || agent.Contact != "[call_timeout=10]user/1000" ||
+		agent.Status != esl.AGENT_STATUS_AVAILABLE || agent.State != esl.AGENT_STATE_WAITING ||
+		agent.MaxNoAnswer != 3 || agent.CallsAnswered != 12 || agent.TalkTime != 600 ||
+		!agent.LastBridgeEnd.Equal(time.Unix(1700000060, 0)) {
+		t.Errorf("agent %+v", agent)
+	}
+
+	tiers, err := callcenter.ListTiers()
+	if err != nil || len(tiers) != 1 {
+		t.Fatalf("tiers %v %v", tiers, err)
+	}
+	if tier := tiers[0]; tier.Queue != "support@default" || tier.State != esl.TIER_STATE_READY ||
+		tier.Level != 1 || tier.Position != 2 {
+		t.Errorf("tier %+v", tier)
+	}
+
+	members, err := callcenter.ListQueueMembers("sales queue")
+	if err != nil || len(members) != 1 {
+		t.Fatalf("members %v %v", members, err)
+	}
+	if member := members[0]; member.Uuid != "m1" || member.CidName != "Bob" || member.Score != 30 ||
+		!member.JoinedAt.Equal(time.Unix(1700000000, 0)) || !member.BridgedAt.IsZero() {
+		t.Errorf("member %+v", member)
+	}
+}
+
+func TestCallcenterQuotesArguments(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	callcenterConfig(server, map[string]string{
+		"agent set status 1000@default 'On Break'":              "+OK\n",
+		"agent set contact 1000@default '{a=b c}user/1000'":     "+OK\n",
+		"tier set state 'sales queue' 1000@default 'No Answer'": "+OK\n",
+		"tier add 'sales queue' 1000@default 1 1":               "+OK\n",
+		"agent add 'o\\'brien' callback":                        "+OK\n",
+		"queue count members 'sales queue'":                     "2\n",
+	})
+	callcenter := esl.NewCallcenter(client)
+	connect(t, server, client)
+
+	if err := callcenter.SetAgentStatus("1000@default", esl.AGENT_STATUS_ON_BREAK); err != nil {
+		t.Error(err)
+	}
+	if err := callcenter.SetAgentContact("1000@default", "{a=b c}user/1000"); err != nil {
+		t.Error(err)
+	}
+	if err := callcenter.SetTierState("sales queue", "1000@default", esl.TIER_STATE_NO_ANSWER); err != nil {
+		t.Error(err)
+	}
+	if err := callcenter.AddTier("sales queue", "1000@default", 1, 1); err != nil {
+		t.Error(err)
+	}
+	if err := callcenter.AddAgent("o'brien", esl.AGENT_TYPE_CALLBACK); err != nil {
+		t.Error(err)
+	}
+	if count, err := callcenter.CountMembers("sales queue"); err != nil || count != 2 {
+		t.Errorf("count %d %v", count, err)
+	}
+	if err := callcenter.DeleteAgent("unknown"); err == nil {
+		t.Error("a -ERR response must fail")
+	}
+}
+
+func TestCallcenterEvents(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	callcenter := esl.NewCallcenter(client)
+	var mtx sync.Mutex
+	var events []*esl.CallcenterEvent
+	callcenter.OnEvent(func(event *esl.CallcenterEvent) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		events = append(events, event)
+	})
+	conn := connect(t, server, client)
+	waitCommand(t, server, "event plain "+esl.CALLCENTER_EVENTS)
+
+	conn.SendEvent(esltest.NewEvent("HEARTBEAT"))
+	conn.SendEvent(esltest.NewCustomEvent("callcenter::info").Set("CC-Action", esl.CC_BRIDGE_AGENT_START).
+		Set("CC-Queue", "support@default").Set("CC-Agent", "1000@default").Set("CC-Member-UUID", "m1").
+		Set("CC-Member-CID-Name", "Bob").Set("CC-Agent-Answered-Time", "1700000010"))
+	conn.SendEvent(esltest.NewCustomEvent("callcenter::info").Set("CC-Action", esl.CC_MEMBERS_COUNT).
+		Set("CC-Queue", "support@default").Set("CC-Count", "4"))
+	eventually(t, "the callcenter events", func() bool {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return len(events) == 2
+	})
+	mtx.Lock()
+	defer mtx.Unlock()
+	bridge := events[0]
+	if bridge.Action != esl.CC_BRIDGE_AGENT_START || bridge.Agent != "1000@default" || bridge.MemberUuid != "m1" ||
+		bridge.MemberCidName != "Bob" || !bridge.AgentAnsweredAt.Equal(time.Unix(1700000010, 0)) || bridge.Event == nil {
+		t.Errorf("bridge event %+v", bridge)
+	}
+	if count := events[1]; count.Action != esl.CC_MEMBERS_COUNT || count.Count != 4 || count.Queue != "support@default" {
+		t.Errorf("members count event %+v", count)
+	}
+}
@@ -0,0 +1,425 @@
+package esl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/bytedance/gopkg/util/logger"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// CDR_EVENTS - the events a CdrCollector subscribes to
+const CDR_EVENTS = "CHANNEL_HANGUP_COMPLETE"
+
+// CDR_CSV_COLUMNS - The fixed columns of the CSV CDR files, the selected variables follow them.
+var CDR_CSV_COLUMNS = []string{"uuid", "call_uuid", "other_leg_uuid", "direction", "caller_id_name", "caller_id_number",
+	"destination_number", "callee_id_number", "context", "started_at", "answered_at", "ended_at", "duration",
+	"billsec", "hangup_cause", "hangup_disposition", "read_codec", "write_codec"}
+
+// Cdr - The record of a channel, built from its CHANNEL_HANGUP_COMPLETE event.
+type Cdr struct {
+	Uuid              string    `json:"uuid"`
+	CallUuid          string    `json:"call_uuid,omitempty"`
+	OtherLegUuid      string    `json:"other_leg_uuid,omitempty"`
+	Direction         string    `json:"direction"`
+	CallerIdName      string    `json:"caller_id_name"`
+	CallerIdNumber    string    `json:"caller_id_number"`
+	DestinationNumber string    `json:"destination_number"`
+	CalleeIdNumber    string    `json:"callee_id_number,omitempty"`
+	Context           string    `json:"context"`
+	StartedAt         time.Time `json:"started_at"`
+	AnsweredAt        time.Time `json:"answered_at"`
+	EndedAt           time.Time `json:"ended_at"`
+	// Duration - the seconds from start to end
+	Duration int `json:"duration"`
+	// BillSec - the seconds from answer to end, 0 when the channel was not answered
+	BillSec           int    `json:"billsec"`
+	HangupCause       string `json:"hangup_cause"`
+	HangupDisposition string `json:"hangup_disposition,omitempty"`
+	ReadCodec         string `json:"read_codec,omitempty"`
+	WriteCodec        string `json:"write_codec,omitempty"`
+	// Variables - the variables selected by the collector, unset ones are missing
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+// NewCdr - Build the record of a CHANNEL_HANGUP_COMPLETE event.
+//   - @param variables the channel variables copied into Variables, without the variable_ prefix
+func NewCdr(event *EslEvent, variables []string) *Cdr {
+	headers := *event.GetEventHeaders()
+	cdr := &Cdr{
+		Uuid:              headers["Unique-ID"],
+		CallUuid:          headers["variable_call_uuid"],
+		OtherLegUuid:      headers["Other-Leg-Unique-ID"],
+		Direction:         headers["Call-Direction"],
+		CallerIdName:      headers["Caller-Caller-ID-Name"],
+		CallerIdNumber:    headers["Caller-Caller-ID-Number"],
+		DestinationNumber: headers["Caller-Destination-Number"],
+		CalleeIdNumber:    headers["Caller-Callee-ID-Number"],
+		Context:           headers["Caller-Context"],
+		StartedAt:         cdrTime(headers, "Caller-Channel-Created-Time", "variable_start_uepoch"),
+		AnsweredAt:        cdrTime(headers, "Caller-Channel-Answered-Time", "variable_answer_uepoch"),
+		EndedAt:           cdrTime(headers, "Caller-Channel-Hangup-Time", "variable_end_uepoch"),
+		HangupCause:       headers["Hangup-Cause"],
+		HangupDisposition: headers["variable_sip_hangup_disposition"],
+		ReadCodec:         headers["variable_read_codec"],
+		WriteCodec:        headers["variable_write_codec"],
+	}
+	if cdr.HangupCause == "" {
+		cdr.HangupCause = headers["variable_hangup_cause"]
+	}
+	if cdr.EndedAt.IsZero() {
+		cdr.EndedAt = eventTime(event)
+	}
+	if duration, err := strconv.Atoi(headers["variable_duration"]); err == nil {
+		cdr.Duration = duration
+	} else if !cdr.StartedAt.IsZero() {
+		cdr.Duration = int(cdr.EndedAt.Sub(cdr.StartedAt) / time.Second)
+	}
+	if billSec, err := strconv.Atoi(headers["variable_billsec"]); err == nil {
+		cdr.BillSec = billSec
+	} else if !cdr.AnsweredAt.IsZero() {
+		cdr.BillSec = int(cdr.EndedAt.Sub(cdr.AnsweredAt) / time.Second)
+	}
+	for _, name := range variables {
+		if value, ok := headers["variable_"+name]; ok {
+			if cdr.Variables == nil {
+				cdr.Variables = make(map[string]string, len(variables))
+			}
+			cdr.Variables[name] = value
+		}
+	}
+	return cdr
+}
+
+// cdrTime - The microseconds of the first header that is set.
+func cdrTime(headers map[string]string, names ...string) time.Time {
+	for _, name := range names {
+		if t := parseMicroseconds(headers[name]); !t.IsZero() {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// CdrWriter - Where a CdrCollector writes its records, the calls are serialized by the collector.
+type CdrWriter interface {
+	// Write - Write a record, it may be buffered until Flush.
+	Write(cdr *Cdr) error
+	// Flush - Write the buffered records.
+	Flush() error
+	// Close - Flush and release the writer.
+	Close() error
+}
+
+// CdrCollector - Writes a Cdr for every CHANNEL_HANGUP_COMPLETE event of a Client.
+//   - The writer is flushed when the client disconnects, and closed when the client is shut down.
+type CdrCollector struct {
+	listenerBase
+	client    *Client
+	writer    CdrWriter
+	variables []string
+	mtx       sync.Mutex
+	written   int64
+	failed    int64
+	closed    bool
+}
+
+// NewCdrCollector - Constructor, registers the collector as event and connection listener of client.
+//   - @param variables the channel variables copied into the records, for example sip_call_id
+func NewCdrCollector(client *Client, writer CdrWriter, variables ...string) *CdrCollector {
+	c := &CdrCollector{client: client, writer: writer, variables: variables}
+	client.AddEventListener(c)
+	client.AddConnectionListener(c)
+	return c
+}
+
+// Sync - Subscribe to CDR_EVENTS.
+func (c *CdrCollector) Sync() error {
+	return subscribe(c.client, CDR_EVENTS, "CDR")
+}
+
+// Stats - The records written and the records the writer failed to write.
+func (c *CdrCollector) Stats() (written, failed int64) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.written, c.failed
+}
+
+// Flush - Flush the writer.
+func (c *CdrCollector) Flush() error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.closed {
+		return nil
+	}
+	return c.writer.Flush()
+}
+
+// Close - Close the writer, the later events are ignored.
+func (c *CdrCollector) Close() error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.writer.Close()
+}
+
+// EventReceived - Implements IEslEventListener.
+func (c *CdrCollector) EventReceived(event *EslEvent) error {
+	if event.GetEventName() != "CHANNEL_HANGUP_COMPLETE" {
+		return nil
+	}
+	cdr := NewCdr(event, c.variables)
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.closed {
+		return nil
+	}
+	if err := c.writer.Write(cdr); err != nil {
+		c.failed++
+		return err
+	}
+	c.written++
+	return nil
+}
+
+// Authenticated - Implements IEslConnectionListener, subscribes to CDR_EVENTS.
+func (c *CdrCollector) Authenticated(authenticated bool, client *Client) {
+	if !authenticated {
+		return
+	}
+	if err := c.Sync(); err != nil {
+		logger.Errorf("CDR collector subscription failure, cause %v\n", err)
+	}
+}
+
+// Disconnected - Implements IEslConnectionListener, flushes the writer.
+func (c *CdrCollector) Disconnected(client *Client) {
+	if err := c.Flush(); err != nil {
+		logger.Errorf("CDR flush failure, cause %v\n", err)
+	}
+}
+
+// Shutdown - Implements IEslShutdownListener, closes the writer.
+func (c *CdrCollector) Shutdown(client *Client) {
+	if err := c.Close(); err != nil {
+		logger.Errorf("CDR close failure, cause %v\n", err)
+	}
+}
+
+// CdrFileOptions - The file of a CSV or JSON lines CdrWriter.
+type CdrFileOptions struct {
+	// Path - the current file, rotated files are named after it with the time of the rotation, as in cdr-20060102-150405.csv
+	Path string
+	// MaxBytes - rotate the file once it is larger, 0 disables the size rotation
+	MaxBytes int64
+	// MaxAge - rotate the file once it was opened for longer, 0 disables the time rotation
+	MaxAge time.Duration
+	// FlushEach - flush after every record instead of on Flush, rotation and Close
+	FlushEach bool
+}
+
+// rotatingFile - A buffered append-only file rotated on size and age.
+type rotatingFile struct {
+	options  CdrFileOptions
+	file     *os.File
+	buffer   *bufio.Writer
+	size     int64
+	openedAt time.Time
+	// onOpen - called with the size of the file every time it is opened
+	onOpen func(size int64) error
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.options.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	f.file, f.buffer, f.size, f.openedAt = file, bufio.NewWriter(file), info.Size(), time.Now()
+	if f.onOpen != nil {
+		return f.onOpen(f.size)
+	}
+	return nil
+}
+
+// write - Write a record, rotating the file first when it is due.
+func (f *rotatingFile) write(record []byte) error {
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return err
+		}
+	} else if f.size > 0 && f.rotationDue(int64(len(record))) {
+		if err := f.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := f.buffer.Write(record)
+	f.size += int64(n)
+	if err != nil {
+		return err
+	}
+	if f.options.FlushEach {
+		return f.buffer.Flush()
+	}
+	return nil
+}
+
+func (f *rotatingFile) rotationDue(recordSize int64) bool {
+	if f.options.MaxBytes > 0 && f.size+recordSize > f.options.MaxBytes {
+		return true
+	}
+	return f.options.MaxAge > 0 && time.Since(f.openedAt) >= f.options.MaxAge
+}
+
+// rotate - Close the file, rename it with the time of the rotation and open a new one.
+func (f *rotatingFile) rotate() error {
+	if err := f.close(); err != nil {
+		return err
+	}
+	ext := filepath.Ext(f.options.Path)
+	base := f.options.Path[:len(f.options.Path)-len(ext)] + "-" + time.Now().Format("20060102-150405")
+	rotated := base + ext
+	for i := 1; ; i++ {
+		if _, err := os.Stat(rotated); os.IsNotExist(err) {
+			break
+		}
+		rotated = base + "." + strconv.Itoa(i) + ext
+	}
+	if err := os.Rename(f.options.Path, rotated); err != nil {
+		return err
+	}
+	if isInfoEnabled() {
+		logger.Infof("CDR file rotated to %s\n", rotated)
+	}
+	return f.open()
+}
+
+func (f *rotatingFile) flush() error {
+	if f.buffer == nil {
+		return nil
+	}
+	return f.buffer.Flush()
+}
+
+func (f *rotatingFile) close() error {
+	if f.file == nil {
+		return nil
+	}
+	err := f.buffer.Flush()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	f.file, f.buffer = nil, nil
+	return err
+}
+
+// CsvCdrWriter - Writes the records as CSV, a header line starts every new file.
+type CsvCdrWriter struct {
+	file      rotatingFile
+	variables []string
+}
+
+// NewCsvCdrWriter - Constructor, the file is opened by the first record.
+//   - @param variables the columns following CDR_CSV_COLUMNS, usually the variables selected by the collector
+func NewCsvCdrWriter(options CdrFileOptions, variables ...string) *CsvCdrWriter {
+	w := &CsvCdrWriter{file: rotatingFile{options: options}, variables: variables}
+	w.file.onOpen = func(size int64) error {
+		if size > 0 {
+			return nil
+		}
+		header := append(append([]string{}, CDR_CSV_COLUMNS...), variables...)
+		record, err := csvLine(header)
+		if err != nil {
+			return err
+		}
+		n, err := w.file.buffer.Write(record)
+		w.file.size += int64(n)
+		return err
+	}
+	return w
+}
+
+// Write - Implements CdrWriter.
+func (w *CsvCdrWriter) Write(cdr *Cdr) error {
+	columns := []string{cdr.Uuid, cdr.CallUuid, cdr.OtherLegUuid, cdr.Direction, cdr.CallerIdName, cdr.CallerIdNumber,
+		cdr.DestinationNumber, cdr.CalleeIdNumber, cdr.Context, formatCdrTime(cdr.StartedAt),
+		formatCdrTime(cdr.AnsweredAt), formatCdrTime(cdr.EndedAt), strconv.Itoa(cdr.Duration),
+		strconv.Itoa(cdr.BillSec), cdr.HangupCause, cdr.HangupDisposition, cdr.ReadCodec, cdr.WriteCodec}
+	for _, name := range w.variables {
+		columns = append(columns, cdr.Variables[name])
+	}
+	record, err := csvLine(columns)
+	if err != nil {
+		return err
+	}
+	return w.file.write(record)
+}
+
+// Flush - Implements CdrWriter.
+func (w *CsvCdrWriter) Flush() error {
+	return w.file.flush()
+}
+
+// Close - Implements CdrWriter.
+func (w *CsvCdrWriter) Close() error {
+	return w.file.close()
+}
+
+// JsonCdrWriter - Writes the records as JSON lines, one object per line.
+type JsonCdrWriter struct {
+	file rotatingFile
+}
+
+// NewJsonCdrWriter - Constructor, the file is opened by the first record.
+func NewJsonCdrWriter(options CdrFileOptions) *JsonCdrWriter {
+	return &JsonCdrWriter{file: rotatingFile{options: options}}
+}
+
+// Write - Implements CdrWriter.
+func (w *JsonCdrWriter) Write(cdr *Cdr) error {
+	record, err := json.Marshal(cdr)
+	if err != nil {
+		return err
+	}
+	return w.file.write(append(record, '\n'))
+}
+
+// Flush - Implements CdrWriter.
+func (w *JsonCdrWriter) Flush() error {
+	return w.file.flush()
+}
+
+// Close - Implements CdrWriter.
+func (w *JsonCdrWriter) Close() error {
+	return w.file.close()
+}
+
+func csvLine(columns []string) ([]byte, error) {
+	var line bytes.Buffer
+	writer := csv.NewWriter(&line)
+	if err := writer.Write(columns); err != nil {
+		return nil, err
+	}
+	writer.Flush()
+	return line.Bytes(), writer.Error()
+}
+
+// formatCdrTime - RFC 3339 with milliseconds, empty when unset.
+func formatCdrTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02T15:04:05.000Z07:00")
+}
@@ -0,0 +1,77 @@
+package esl_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zhouhailin/freeswitch-esl-go/esl"
+	"github.com/zhouhailin/freeswitch-esl-go/esl/esltest"
+)
+
+func TestCdrCollector(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cdr.csv")
+	server, client := newTestClient(t, nil)
+	collector := esl.NewCdrCollector(client, esl.NewCsvCdrWriter(esl.CdrFileOptions{Path: path}, "sip_call_id"),
+		"sip_call_id")
+	conn := connect(t, server, client)
+	waitCommand(t, server, "event plain "+esl.CDR_EVENTS)
+
+	conn.SendEvent(esltest.NewEvent("CHANNEL_CREATE").Set("Unique-ID", "a"))
+	conn.SendEvent(esltest.NewEvent("CHANNEL_HANGUP_COMPLETE").Set("Unique-ID", "a").
+		Set("Call-Direction", "inbound").Set("Caller-Caller-ID-Number", "1000").
+		Set("Caller-Destination-Number", "2000").Set("Caller-Channel-Created-Time", "1700000000000000").
+		Set("Caller-Channel-Answered-Time", "1700000002000000").Set("Caller-Channel-Hangup-Time", "1700000012000000").
+		Set("Hangup-Cause", "NORMAL_CLEARING").Set("variable_sip_call_id", "abc@10.0.0.2"))
+	eventually(t, "the record", func() bool {
+		written, _ := collector.Stats()
+		return written == 1
+	})
+	if err := collector.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("lines %q", lines)
+	}
+	if header := strings.Join(append(append([]string{}, esl.CDR_CSV_COLUMNS...), "sip_call_id"), ","); lines[0] != header {
+		t.Errorf("header %q", lines[0])
+	}
+	columns := strings.Split(lines[1], ",")
+	if columns[0] != "a" || columns[5] != "1000" || columns[6] != "2000" || columns[12] != "12" ||
+		columns[13] != "10" || columns[14] != "NORMAL_CLEARING" || columns[len(columns)-1] != "abc@10.0.0.2" {
+		t.Errorf("record %q", lines[1])
+	}
+}
+
+func TestCdrCollectorClosedOnShutdown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cdr.json")
+	server, client := newTestClient(t, nil)
+	collector := esl.NewCdrCollector(client, esl.NewJsonCdrWriter(esl.CdrFileOptions{Path: path}))
+	conn := connect(t, server, client)
+	waitCommand(t, server, "event plain "+esl.CDR_EVENTS)
+
+	conn.SendEvent(esltest.NewEvent("CHANNEL_HANGUP_COMPLETE").Set("Unique-ID", "a").
+		Set("Hangup-Cause", "NORMAL_CLEARING"))
+	eventually(t, "the record", func() bool {
+		written, _ := collector.Stats()
+		return written == 1
+	})
+	if err := client.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), `"uuid":"a"`) {
+		t.Fatalf("record not written on Shutdown: %q", content)
+	}
+}
@@ -0,0 +1,393 @@
+package esl
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/bytedance/gopkg/util/logger"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CHANNEL_TRACKER_EVENTS - the events a ChannelTracker subscribes to
+const CHANNEL_TRACKER_EVENTS = "CHANNEL_CREATE CHANNEL_PROGRESS CHANNEL_PROGRESS_MEDIA CHANNEL_ANSWER CHANNEL_BRIDGE " +
+	"CHANNEL_UNBRIDGE CHANNEL_CALLSTATE CHANNEL_HOLD CHANNEL_UNHOLD CHANNEL_HANGUP CHANNEL_HANGUP_COMPLETE CHANNEL_DESTROY"
+
+// ChannelChangeType - What happened to a tracked channel.
+type ChannelChangeType int
+
+const (
+	CHANNEL_ADDED ChannelChangeType = iota
+	CHANNEL_UPDATED
+	CHANNEL_REMOVED
+)
+
+// ChannelChange - A change of a tracked channel, Event is nil when the change comes from a resynchronization.
+type ChannelChange struct {
+	Type    ChannelChangeType
+	Channel *Channel
+	Event   *EslEvent
+}
+
+// Channel - The latest known state of a live channel.
+type Channel struct {
+	Uuid              string
+	Name              string
+	Direction         string
+	State             string
+	CallState         string
+	AnswerState       string
+	CallerIdName      string
+	CallerIdNumber    string
+	DestinationNumber string
+	Context           string
+	OtherLegUuid      string
+	HangupCause       string
+	CreatedAt         time.Time
+	AnsweredAt        time.Time
+	UpdatedAt         time.Time
+	// Headers - the headers of the latest event, without the variable_ headers
+	Headers map[string]string
+	// Variables - the channel variables, without the variable_ prefix
+	Variables map[string]string
+}
+
+// IsAnswered - Convenience method.
+//   - @return true if the channel has been answered
+func (c *Channel) IsAnswered() bool {
+	return c.AnswerState == "answered" || !c.AnsweredAt.IsZero()
+}
+
+func (c *Channel) clone() *Channel {
+	clone := *c
+	clone.Headers = make(map[string]string, len(c.Headers))
+	for name, value := range c.Headers {
+		clone.Headers[name] = value
+	}
+	clone.Variables = make(map[string]string, len(c.Variables))
+	for name, value := range c.Variables {
+		clone.Variables[name] = value
+	}
+	return &clone
+}
+
+// apply - Merge the headers of a channel event.
+func (c *Channel) apply(headers map[string]string) {
+	c.Headers = make(map[string]string, len(headers))
+	for name, value := range headers {
+		if strings.HasPrefix(name, "variable_") {
+			c.Variables[strings.TrimPrefix(name, "variable_")] = value
+		} else {
+			c.Headers[name] = value
+		}
+	}
+	setIfPresent(&c.Name, headers["Channel-Name"])
+	setIfPresent(&c.Direction, headers["Call-Direction"])
+	setIfPresent(&c.State, headers["Channel-State"])
+	setIfPresent(&c.CallState, headers["Channel-Call-State"])
+	setIfPresent(&c.AnswerState, headers["Answer-State"])
+	setIfPresent(&c.CallerIdName, headers["Caller-Caller-ID-Name"])
+	setIfPresent(&c.CallerIdNumber, headers["Caller-Caller-ID-Number"])
+	setIfPresent(&c.DestinationNumber, headers["Caller-Destination-Number"])
+	setIfPresent(&c.Context, headers["Caller-Context"])
+	setIfPresent(&c.OtherLegUuid, headers["Other-Leg-Unique-ID"])
+	setIfPresent(&c.HangupCause, headers["Hangup-Cause"])
+	if t := parseMicroseconds(headers["Caller-Channel-Created-Time"]); !t.IsZero() {
+		c.CreatedAt = t
+	}
+	if t := parseMicroseconds(headers["Caller-Channel-Answered-Time"]); !t.IsZero() {
+		c.AnsweredAt = t
+	}
+	c.UpdatedAt = time.Now()
+}
+
+// applyRow - Merge a row of "show channels as json".
+func (c *Channel) applyRow(row map[string]string) {
+	setIfPresent(&c.Name, row["name"])
+	setIfPresent(&c.Direction, row["direction"])
+	setIfPresent(&c.State, row["state"])
+	setIfPresent(&c.CallState, row["callstate"])
+	setIfPresent(&c.CallerIdName, row["cid_name"])
+	setIfPresent(&c.CallerIdNumber, row["cid_num"])
+	setIfPresent(&c.DestinationNumber, row["dest"])
+	setIfPresent(&c.Context, row["context"])
+	if epoch, err := strconv.ParseInt(row["created_epoch"], 10, 64); err == nil && epoch > 0 && c.CreatedAt.IsZero() {
+		c.CreatedAt = time.Unix(epoch, 0)
+	}
+	c.UpdatedAt = time.Now()
+}
+
+// ChannelTracker - Maintains the live channels of a Client from its channel events.
+type ChannelTracker struct {
+	listenerBase
+	client    *Client
+	mtx       sync.RWMutex
+	channels  map[string]*Channel
+	callbacks []func(change *ChannelChange)
+	watchers  map[*channelWatcher]struct{}
+	syncing   bool
+	removed   map[string]bool
+}
+
+type channelWatcher struct {
+	uuid    string
+	changes chan *ChannelChange
+}
+
+// NewChannelTracker - Constructor, registers the tracker as event and connection listener of client.
+func NewChannelTracker(client *Client) *ChannelTracker {
+	t := &ChannelTracker{
+		client:   client,
+		channels: make(map[string]*Channel),
+		watchers: make(map[*channelWatcher]struct{}),
+	}
+	client.AddEventListener(t)
+	client.AddConnectionListener(t)
+	return t
+}
+
+// Get - Lookup a live channel.
+//   - @return a copy of the channel, false if it is not live
+func (t *ChannelTracker) Get(uuid string) (*Channel, bool) {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	channel, ok := t.channels[uuid]
+	if !ok {
+		return nil, false
+	}
+	return channel.clone(), true
+}
+
+// List - A copy of every live channel.
+func (t *ChannelTracker) List() []*Channel {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	channels := make([]*Channel, 0, len(t.channels))
+	for _, channel := range t.channels {
+		channels = append(channels, channel.clone())
+	}
+	return channels
+}
+
+// Count - The number of live channels.
+func (t *ChannelTracker) Count() int {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	return len(t.channels)
+}
+
+// OnChange - Register a callback, it is called from the event dispatch goroutine in the order of the changes.
+func (t *ChannelTracker) OnChange(callback func(change *ChannelChange)) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.callbacks = append(t.callbacks, callback)
+}
+
+// Watch - Receive the changes of one channel, or of every channel when uuid is empty.
+//   - The returned chan is closed by the cancel function, or after the removal of the watched channel.
+//   - Changes are dropped when the receiver does not keep up with the buffer of 64 changes.
+func (t *ChannelTracker) Watch(uuid string) (<-chan *ChannelChange, func()) {
+	w := &channelWatcher{uuid: uuid, changes: make(chan *ChannelChange, 64)}
+	t.mtx.Lock()
+	t.watchers[w] = struct{}{}
+	t.mtx.Unlock()
+	return w.changes, func() {
+		t.mtx.Lock()
+		defer t.mtx.Unlock()
+		t.unwatch(w)
+	}
+}
+
+// unwatch - must be called with the lock held.
+func (t *ChannelTracker) unwatch(w *channelWatcher) {
+	if _, ok := t.watchers[w]; ok {
+		delete(t.watchers, w)
+		close(w.changes)
+	}
+}
+
+// Sync - Subscribe to the channel events and replace the tracked channels with "show channels as json".
+func (t *ChannelTracker) Sync() error {
+	if err := subscribe(t.client, CHANNEL_TRACKER_EVENTS, "Channel"); err != nil {
+		return err
+	}
+	t.mtx.Lock()
+	t.syncing = true
+	t.removed = make(map[string]bool)
+	t.mtx.Unlock()
+	defer func() {
+		t.mtx.Lock()
+		t.syncing = false
+		t.removed = nil
+		t.mtx.Unlock()
+	}()
+	message, err := t.client.SendSyncApiCommand("show", "channels as json")
+	if err != nil {
+		return err
+	}
+	rows, err := parseShowJson(message.getBody())
+	if err != nil {
+		return err
+	}
+	t.replace(rows)
+	return nil
+}
+
+// replace - Apply a snapshot, ignoring the channels removed by events since the snapshot was requested.
+func (t *ChannelTracker) replace(rows []map[string]string) {
+	var changes []*ChannelChange
+	t.mtx.Lock()
+	live := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		uuid := row["uuid"]
+		if uuid == "" || t.removed[uuid] {
+			continue
+		}
+		live[uuid] = true
+		channel, ok := t.channels[uuid]
+		changeType := CHANNEL_UPDATED
+		if !ok {
+			channel = &Channel{Uuid: uuid, Headers: make(map[string]string), Variables: make(map[string]string)}
+			t.channels[uuid] = channel
+			changeType = CHANNEL_ADDED
+		}
+		channel.applyRow(row)
+		changes = append(changes, &ChannelChange{Type: changeType, Channel: channel.clone()})
+	}
+	for uuid, channel := range t.channels {
+		if !live[uuid] {
+			delete(t.channels, uuid)
+			changes = append(changes, &ChannelChange{Type: CHANNEL_REMOVED, Channel: channel.clone()})
+		}
+	}
+	callbacks := t.notify(changes)
+	t.mtx.Unlock()
+	runChannelCallbacks(callbacks, changes)
+}
+
+// EventReceived - Implements IEslEventListener.
+func (t *ChannelTracker) EventReceived(event *EslEvent) error {
+	name := event.GetEventName()
+	headers := *event.GetEventHeaders()
+	uuid := headers["Unique-ID"]
+	if uuid == "" || !strings.HasPrefix(name, "CHANNEL_") {
+		return nil
+	}
+	var change *ChannelChange
+	t.mtx.Lock()
+	channel, ok := t.channels[uuid]
+	if name == "CHANNEL_HANGUP_COMPLETE" || name == "CHANNEL_DESTROY" {
+		if t.syncing {
+			t.removed[uuid] = true
+		}
+		if ok {
+			channel.apply(headers)
+			delete(t.channels, uuid)
+			change = &ChannelChange{Type: CHANNEL_REMOVED, Channel: channel.clone(), Event: event}
+		}
+	} else {
+		changeType := CHANNEL_UPDATED
+		if !ok {
+			if name != "CHANNEL_CREATE" && channelEnded(headers) {
+				// a late event of a channel already removed, for example CHANNEL_CALLSTATE HANGUP
+				t.mtx.Unlock()
+				return nil
+			}
+			channel = &Channel{Uuid: uuid, Variables: make(map[string]string)}
+			t.channels[uuid] = channel
+			changeType = CHANNEL_ADDED
+		}
+		channel.apply(headers)
+		change = &ChannelChange{Type: changeType, Channel: channel.clone(), Event: event}
+	}
+	if change == nil {
+		t.mtx.Unlock()
+		return nil
+	}
+	changes := []*ChannelChange{change}
+	callbacks := t.notify(changes)
+	t.mtx.Unlock()
+	runChannelCallbacks(callbacks, changes)
+	return nil
+}
+
+// notify - Deliver changes to the watchers, must be called with the lock held.
+//   - @return the callbacks to run once the lock is released
+func (t *ChannelTracker) notify(changes []*ChannelChange) []func(change *ChannelChange) {
+	for w := range t.watchers {
+		for _, change := range changes {
+			if w.uuid != "" && w.uuid != change.Channel.Uuid {
+				continue
+			}
+			select {
+			case w.changes <- change:
+			default:
+				logger.Warnf("Channel watcher is full, dropping change of %s\n", change.Channel.Uuid)
+			}
+			if w.uuid != "" && change.Type == CHANNEL_REMOVED {
+				t.unwatch(w)
+				break
+			}
+		}
+	}
+	return t.callbacks
+}
+
+func runChannelCallbacks(callbacks []func(change *ChannelChange), changes []*ChannelChange) {
+	for _, change := range changes {
+		for _, callback := range callbacks {
+			callback(change)
+		}
+	}
+}
+
+// Authenticated - Implements IEslConnectionListener, resynchronizes the tracked channels.
+func (t *ChannelTracker) Authenticated(authenticated bool, c *Client) {
+	if !authenticated {
+		return
+	}
+	if err := t.Sync(); err != nil {
+		logger.Errorf("Channel tracker synchronization failure, cause %v\n", err)
+	}
+}
+
+// parseShowJson - Parse the rows of "show ... as json", every column value is a string.
+func parseShowJson(body string) ([]map[string]string, error) {
+	body = strings.TrimSpace(body)
+	if strings.HasPrefix(body, "-ERR") {
+		return nil, errors.New(body)
+	}
+	var result struct {
+		RowCount int                 `json:"row_count"`
+		Rows     []map[string]string `json:"rows"`
+	}
+	if err := json.Unmarshal([]byte(body), &result); err != nil {
+		return nil, err
+	}
+	return result.Rows, nil
+}
+
+// channelEnded - Whether the headers of a channel event report a hung up channel.
+func channelEnded(headers map[string]string) bool {
+	switch headers["Channel-State"] {
+	case "CS_HANGUP", "CS_REPORTING", "CS_DESTROY":
+		return true
+	}
+	return headers["Channel-Call-State"] == "HANGUP" || headers["Answer-State"] == "hangup"
+}
+
+func setIfPresent(field *string, value string) {
+	if value != "" {
+		*field = value
+	}
+}
+
+// parseMicroseconds - Parse the microseconds since epoch of the *-Time headers, zero when unset.
+func parseMicroseconds(value string) time.Time {
+	micros, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || micros <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, micros*int64(time.Microsecond))
+}
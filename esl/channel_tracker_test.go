@@ -0,0 +1,50 @@
+package esl_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/zhouhailin/freeswitch-esl-go/esl"
+	"github.com/zhouhailin/freeswitch-esl-go/esl/esltest"
+)
+
+func TestChannelTrackerIgnoresLateEventsOfRemovedChannels(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	server.SetApiResponse("show", `{"row_count":0}`)
+	tracker := esl.NewChannelTracker(client)
+	var mtx sync.Mutex
+	var changes []string
+	tracker.OnChange(func(change *esl.ChannelChange) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		changes = append(changes, change.Channel.Uuid+" "+[]string{"added", "updated", "removed"}[change.Type])
+	})
+	conn := connect(t, server, client)
+	waitCommand(t, server, "api show channels")
+
+	conn.SendEvent(esltest.NewEvent("CHANNEL_CREATE").Set("Unique-ID", "a").Set("Channel-State", "CS_INIT"))
+	conn.SendEvent(esltest.NewEvent("CHANNEL_HANGUP_COMPLETE").Set("Unique-ID", "a").
+		Set("Channel-State", "CS_REPORTING").Set("Hangup-Cause", "NORMAL_CLEARING"))
+	conn.SendEvent(esltest.NewEvent("CHANNEL_CALLSTATE").Set("Unique-ID", "a").
+		Set("Channel-State", "CS_DESTROY").Set("Channel-Call-State", "HANGUP"))
+	conn.SendEvent(esltest.NewEvent("CHANNEL_CREATE").Set("Unique-ID", "b").Set("Channel-State", "CS_INIT"))
+	eventually(t, "channel b", func() bool {
+		_, ok := tracker.Get("b")
+		return ok
+	})
+
+	if _, ok := tracker.Get("a"); ok {
+		t.Error("channel a was added back")
+	}
+	mtx.Lock()
+	defer mtx.Unlock()
+	want := []string{"a added", "a removed", "b added"}
+	if len(changes) != len(want) {
+		t.Fatalf("changes %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Fatalf("changes %v, want %v", changes, want)
+		}
+	}
+}
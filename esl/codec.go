@@ -29,12 +29,15 @@ func decode(reader netpoll.Reader, m *EslMessage) (err error) {
 			reachedDoubleLF = true
 		} else {
 			headerParts := strings.SplitN(headerLine, ":", 2)
+			if len(headerParts) != 2 {
+				return errors.New("Malformed ESL header [" + headerLine + "]")
+			}
 			headerName := fromLiteral(headerParts[0])
 			if headerName == "" {
-				return errors.New("Unhandled ESL header [" + headerParts[0] + "]")
-			} else {
-				m.headers[headerName] = strings.TrimSpace(headerParts[1])
+				// the reply to the connect command of an outbound socket holds the channel data as headers
+				headerName = Name(headerParts[0])
 			}
+			m.headers[headerName] = strings.TrimSpace(headerParts[1])
 		}
 	}
 
@@ -61,7 +64,8 @@ func decode(reader netpoll.Reader, m *EslMessage) (err error) {
 		if err != nil {
 			return err
 		}
-		for _, bodyLine := range strings.Split(string(bytes[:len(bytes)-1]), LINE_TERMINATOR) {
+		// the body usually ends with a line terminator, but not always (uuid_exists answers true)
+		for _, bodyLine := range strings.Split(strings.TrimSuffix(string(bytes), LINE_TERMINATOR), LINE_TERMINATOR) {
 			m.addBodyLine(bodyLine)
 			if isTraceEnabled() {
 				logger.Tracef("read body line %s\n", bodyLine)
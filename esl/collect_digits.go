@@ -0,0 +1,221 @@
+package esl
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DIGITS_VARIABLE - The channel variable receiving the digits when PlayAndGetDigits.VariableName is empty.
+	DIGITS_VARIABLE = "esl_collected_digits"
+	// DIGITS_REGEXP - Any digit, star or pound, used when PlayAndGetDigits.Regexp is empty.
+	DIGITS_REGEXP = "^[0-9*#]+$"
+	// NO_TERMINATORS - No key ends the input.
+	NO_TERMINATORS = "none"
+)
+
+// ErrNoDigitsCollected - play_and_get_digits completed without valid input after every try.
+var ErrNoDigitsCollected = errors.New("no valid digits collected")
+
+// PlayAndGetDigits - The arguments of the play_and_get_digits application.
+//   - <pre>
+//   - play_and_get_digits <min> <max> <tries> <timeout> <terminators> <prompt> <invalid prompt> <variable> <regexp>
+//   - [digit timeout] [transfer on failure]
+//   - </pre>
+type PlayAndGetDigits struct {
+	MinDigits int
+	MaxDigits int
+	// Tries - the prompt is played again up to Tries times when no valid input is received
+	Tries int
+	// Timeout - how long to wait for the first digit after the prompt
+	Timeout time.Duration
+	// Terminators - the keys ending the input, for example #, NO_TERMINATORS when empty
+	Terminators string
+	Prompt      string
+	// InvalidPrompt - played after an invalid input, silence when empty
+	InvalidPrompt string
+	// VariableName - the channel variable receiving the digits, DIGITS_VARIABLE when empty
+	VariableName string
+	// Regexp - the input must match it, DIGITS_REGEXP when empty
+	Regexp string
+	// DigitTimeout - how long to wait between two digits, Timeout when 0
+	DigitTimeout time.Duration
+	// TransferOnFailure - "<extension> <dialplan> <context>" the call is transferred to after the last try
+	TransferOnFailure string
+}
+
+// NewPlayAndGetDigits - Constructor collecting between minDigits and maxDigits digits ended by #, in 3 tries of 5
+// seconds.
+func NewPlayAndGetDigits(prompt string, minDigits, maxDigits int) *PlayAndGetDigits {
+	return &PlayAndGetDigits{
+		MinDigits:   minDigits,
+		MaxDigits:   maxDigits,
+		Tries:       3,
+		Timeout:     5 * time.Second,
+		Terminators: "#",
+		Prompt:      prompt,
+	}
+}
+
+// Validate - Check the arguments, play_and_get_digits splits them on spaces so none may hold one.
+func (p *PlayAndGetDigits) Validate() error {
+	if p.MinDigits < 0 || p.MaxDigits < 1 || p.MinDigits > p.MaxDigits {
+		return errors.New("play_and_get_digits requires 0 <= min digits <= max digits and max digits >= 1")
+	}
+	if p.Tries < 1 {
+		return errors.New("play_and_get_digits requires at least one try")
+	}
+	if p.Timeout <= 0 {
+		return errors.New("play_and_get_digits requires a timeout")
+	}
+	if p.Prompt == "" {
+		return errors.New("play_and_get_digits requires a prompt")
+	}
+	for _, value := range []string{p.Terminators, p.Prompt, p.InvalidPrompt, p.VariableName, p.Regexp} {
+		if strings.ContainsAny(value, " \t\n") {
+			return errors.New("play_and_get_digits argument holds a space: " + value)
+		}
+	}
+	return nil
+}
+
+// GetVariableName - The channel variable receiving the digits.
+func (p *PlayAndGetDigits) GetVariableName() string {
+	if p.VariableName == "" {
+		return DIGITS_VARIABLE
+	}
+	return p.VariableName
+}
+
+// ToString - The application argument.
+func (p *PlayAndGetDigits) ToString() string {
+	terminators := p.Terminators
+	if terminators == "" {
+		terminators = NO_TERMINATORS
+	}
+	invalidPrompt := p.InvalidPrompt
+	if invalidPrompt == "" {
+		invalidPrompt = "silence_stream://250"
+	}
+	regexp := p.Regexp
+	if regexp == "" {
+		regexp = DIGITS_REGEXP
+	}
+	digitTimeout := p.DigitTimeout
+	if digitTimeout <= 0 {
+		digitTimeout = p.Timeout
+	}
+	args := []string{
+		strconv.Itoa(p.MinDigits),
+		strconv.Itoa(p.MaxDigits),
+		strconv.Itoa(p.Tries),
+		strconv.FormatInt(p.Timeout.Milliseconds(), 10),
+		terminators,
+		p.Prompt,
+		invalidPrompt,
+		p.GetVariableName(),
+		regexp,
+		strconv.FormatInt(digitTimeout.Milliseconds(), 10),
+	}
+	if p.TransferOnFailure != "" {
+		args = append(args, p.TransferOnFailure)
+	}
+	return strings.Join(args, " ")
+}
+
+// PlayAndGetDigits - Run play_and_get_digits on the channel and wait for it to complete.
+//   - @return the digits, ErrNoDigitsCollected when no try was valid, a *ChannelHangupError when the channel hangs up
+func (client *Client) PlayAndGetDigits(ctx context.Context, uuid string, request *PlayAndGetDigits) (string, error) {
+	err := request.Validate()
+	if err != nil {
+		return "", err
+	}
+	name := request.GetVariableName()
+	// a previous collection must not be mistaken for this one
+	err = client.UuidSetVar(uuid, name, "")
+	if err != nil {
+		return "", err
+	}
+	result, err := client.ExecuteAndWait(ctx, uuid, "play_and_get_digits", request.ToString())
+	if err != nil {
+		return "", err
+	}
+	digits, found := (*result.Event.GetEventHeaders())["variable_"+name]
+	if !found {
+		// the event does not carry the channel variables
+		digits, _, err = client.UuidGetVar(uuid, name)
+		if err != nil {
+			return "", err
+		}
+	}
+	if digits == "" {
+		return "", ErrNoDigitsCollected
+	}
+	return digits, nil
+}
+
+// Dtmf - A digit pressed on a channel.
+type Dtmf struct {
+	Uuid     string
+	Digit    string
+	Duration int
+	// Source - for example RTP, INBAND_AUDIO or APP
+	Source string
+	Event  *EslEvent
+}
+
+type dtmfWatcher struct {
+	uuid   string
+	digits chan *Dtmf
+}
+
+// WatchDtmf - Stream the digits pressed on the channel, the client subscribes to the DTMF event.
+//   - Digits are dropped when the receiver lags behind by more than 64 of them.
+//   - @return the digits and the function stopping the stream, it closes the channel
+func (client *Client) WatchDtmf(uuid string) (<-chan *Dtmf, func(), error) {
+	if err := subscribe(client, "DTMF", "DTMF"); err != nil {
+		return nil, nil, err
+	}
+	watcher := &dtmfWatcher{uuid: uuid, digits: make(chan *Dtmf, 64)}
+	client.dtmfMtx.Lock()
+	if client.dtmfWatchers == nil {
+		client.dtmfWatchers = make(map[*dtmfWatcher]bool)
+	}
+	client.dtmfWatchers[watcher] = true
+	client.dtmfMtx.Unlock()
+	var once sync.Once
+	return watcher.digits, func() {
+		once.Do(func() {
+			client.dtmfMtx.Lock()
+			delete(client.dtmfWatchers, watcher)
+			client.dtmfMtx.Unlock()
+			close(watcher.digits)
+		})
+	}, nil
+}
+
+// dtmfReceived - Hand a DTMF event to the streams of its channel.
+func (client *Client) dtmfReceived(event *EslEvent) {
+	headers := *event.GetEventHeaders()
+	dtmf := &Dtmf{
+		Uuid:     headers["Unique-ID"],
+		Digit:    headers["DTMF-Digit"],
+		Duration: atoi(headers["DTMF-Duration"]),
+		Source:   headers["DTMF-Source"],
+		Event:    event,
+	}
+	client.dtmfMtx.Lock()
+	defer client.dtmfMtx.Unlock()
+	for watcher := range client.dtmfWatchers {
+		if watcher.uuid == dtmf.Uuid {
+			select {
+			case watcher.digits <- dtmf:
+			default:
+			}
+		}
+	}
+}
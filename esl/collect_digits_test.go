@@ -0,0 +1,65 @@
+package esl_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zhouhailin/freeswitch-esl-go/esl"
+	"github.com/zhouhailin/freeswitch-esl-go/esl/esltest"
+)
+
+func TestPlayAndGetDigits(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	server.SetApiResponse("uuid_setvar", "+OK\n")
+	completeExecutions(server, map[string]string{esl.DIGITS_VARIABLE: "1234"})
+	connect(t, server, client)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	digits, err := client.PlayAndGetDigits(ctx, "u1", esl.NewPlayAndGetDigits("ivr/enter.wav", 1, 4))
+	if err != nil || digits != "1234" {
+		t.Fatalf("got %q %v", digits, err)
+	}
+	cmd := waitCommand(t, server, "sendmsg u1")
+	want := "1 4 3 5000 # ivr/enter.wav silence_stream://250 esl_collected_digits ^[0-9*#]+$ 5000"
+	if got := cmd.Headers["execute-app-arg"]; got != want {
+		t.Fatalf("argument %q, want %q", got, want)
+	}
+}
+
+func TestPlayAndGetDigitsValidate(t *testing.T) {
+	request := esl.NewPlayAndGetDigits("a prompt with spaces.wav", 1, 4)
+	if request.Validate() == nil {
+		t.Fatal("a prompt with spaces must be rejected")
+	}
+	if esl.NewPlayAndGetDigits("p.wav", 5, 4).Validate() == nil {
+		t.Fatal("min digits above max digits must be rejected")
+	}
+}
+
+func TestWatchDtmf(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	connect(t, server, client)
+	digits, stop, err := client.WatchDtmf("u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var once sync.Once
+	defer once.Do(stop)
+	server.SendEvent(esltest.NewEvent("DTMF").Set("Unique-ID", "other").Set("DTMF-Digit", "9"))
+	server.SendEvent(esltest.NewEvent("DTMF").Set("Unique-ID", "u1").Set("DTMF-Digit", "5").
+		Set("DTMF-Duration", "2000").Set("DTMF-Source", "RTP"))
+	select {
+	case dtmf := <-digits:
+		if dtmf.Digit != "5" || dtmf.Duration != 2000 || dtmf.Source != "RTP" {
+			t.Fatalf("unexpected digit %+v", dtmf)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no digit received")
+	}
+	once.Do(stop)
+	if _, open := <-digits; open {
+		t.Fatal("stream not closed")
+	}
+}
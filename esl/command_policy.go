@@ -0,0 +1,185 @@
+package esl
+
+import (
+	"strings"
+)
+
+// The classes of the api, bgapi and sendmsg commands
+const (
+	// COMMAND_CLASS_READ - reads the state of the switch or of a channel, for example status, show or uuid_getvar
+	COMMAND_CLASS_READ = "read"
+	// COMMAND_CLASS_CALL_CONTROL - acts on calls, for example originate, uuid_kill, conference kick or sendmsg
+	COMMAND_CLASS_CALL_CONTROL = "call_control"
+	// COMMAND_CLASS_SYSTEM - acts on the whole switch, for example fsctl, hupall, reloadxml, load or system
+	COMMAND_CLASS_SYSTEM = "system"
+	// COMMAND_CLASS_UNKNOWN - any other api command
+	COMMAND_CLASS_UNKNOWN = "unknown"
+)
+
+var readApis = map[string]bool{
+	"status": true, "version": true, "show": true, "uptime": true, "hostname": true, "strftime": true,
+	"global_getvar": true, "uuid_getvar": true, "uuid_exists": true, "uuid_dump": true, "uuid_buglist": true,
+	"module_exists": true, "list_users": true, "user_exists": true, "domain_exists": true, "sofia_contact": true,
+	"limit_usage": true, "valet_info": true,
+}
+
+var systemApis = map[string]bool{
+	"fsctl": true, "hupall": true, "shutdown": true, "reloadxml": true, "reload": true, "reloadacl": true,
+	"load": true, "unload": true, "system": true, "bg_system": true, "global_setvar": true, "xml_flush_cache": true,
+	"lua": true, "luarun": true, "js": true, "jsrun": true, "perlrun": true, "python": true, "pyrun": true,
+	"expand": true, "sched_api": true, "sched_del": true,
+}
+
+var callControlApis = map[string]bool{
+	"originate": true, "conference": true, "callcenter_config": true, "sched_hangup": true,
+	"sched_transfer": true, "sched_broadcast": true,
+}
+
+// CommandDeniedError - A command was rejected by the CommandPolicy of the client, it was not sent.
+type CommandDeniedError struct {
+	// Command - the command as matched by the policy, for example "fsctl shutdown" or "sendmsg hangup"
+	Command string
+	Class   string
+	Reason  string
+}
+
+func (e *CommandDeniedError) Error() string {
+	return "command denied by the policy: " + e.Command + " (" + e.Reason + ")"
+}
+
+// CommandPolicy - The api, bgapi and sendmsg commands a client may send, the other commands are always allowed.
+//   - A command matching Deny is rejected, otherwise a command matching Allow is allowed, otherwise its class must
+//   - be in Classes. A rule matches the commands starting with its words: "fsctl" matches every fsctl command,
+//   - "fsctl shutdown" only the shutdowns. The sendmsg commands are matched as "sendmsg <call-command> [<app>]", for
+//   - example "sendmsg hangup" or "sendmsg execute playback".
+type CommandPolicy struct {
+	// Classes - the allowed COMMAND_CLASS_*, empty allows every class
+	Classes []string
+	Allow   []string
+	Deny    []string
+}
+
+// ReadOnlyCommandPolicy - Allow the COMMAND_CLASS_READ commands only, which is enough for the trackers.
+func ReadOnlyCommandPolicy() *CommandPolicy {
+	return &CommandPolicy{Classes: []string{COMMAND_CLASS_READ}}
+}
+
+// Check - Whether the policy allows the command.
+//   - @return a *CommandDeniedError when it does not
+func (p *CommandPolicy) Check(command *Command) error {
+	subject, class := ClassifyCommand(command)
+	if class == "" {
+		return nil
+	}
+	for _, rule := range p.Deny {
+		if matchCommandRule(rule, subject) {
+			return &CommandDeniedError{Command: subject, Class: class, Reason: "denied by " + rule}
+		}
+	}
+	for _, rule := range p.Allow {
+		if matchCommandRule(rule, subject) {
+			return nil
+		}
+	}
+	if len(p.Classes) == 0 {
+		return nil
+	}
+	for _, allowed := range p.Classes {
+		if allowed == class {
+			return nil
+		}
+	}
+	return &CommandDeniedError{Command: subject, Class: class, Reason: class + " commands are not allowed"}
+}
+
+// ClassifyCommand - The class of an api, bgapi or sendmsg command.
+//   - @return the command as matched by the policy rules, and one of COMMAND_CLASS_*, or an empty class for the
+//   - other commands
+func ClassifyCommand(command *Command) (string, string) {
+	switch command.Name() {
+	case "api", "bgapi":
+		subject := strings.Join(strings.Fields(command.Args()), " ")
+		return subject, classifyApi(strings.Fields(strings.ToLower(subject)))
+	case "sendmsg":
+		subject := strings.TrimSpace("sendmsg " + commandHeader(command, "call-command"))
+		if app := commandHeader(command, "execute-app-name"); app != "" {
+			subject += " " + app
+		}
+		return subject, COMMAND_CLASS_CALL_CONTROL
+	}
+	return command.Name(), ""
+}
+
+func classifyApi(words []string) string {
+	if len(words) == 0 {
+		return COMMAND_CLASS_UNKNOWN
+	}
+	name := words[0]
+	switch {
+	case readApis[name]:
+		return COMMAND_CLASS_READ
+	case systemApis[name]:
+		return COMMAND_CLASS_SYSTEM
+	case name == "sofia":
+		// sofia status and sofia xmlstatus read, the other sofia commands restart profiles or change settings
+		if len(words) > 1 && (words[1] == "status" || words[1] == "xmlstatus") {
+			return COMMAND_CLASS_READ
+		}
+		return COMMAND_CLASS_SYSTEM
+	case name == "conference":
+		// conference [<name>] list|xml_list|json_list|count
+		for i := 1; i < len(words) && i < 3; i++ {
+			switch words[i] {
+			case "list", "xml_list", "json_list", "count":
+				return COMMAND_CLASS_READ
+			}
+		}
+		return COMMAND_CLASS_CALL_CONTROL
+	case name == "callcenter_config":
+		// callcenter_config agent|tier|queue list|count|get ...
+		if len(words) > 2 && (words[2] == "list" || words[2] == "count" || words[2] == "get") {
+			return COMMAND_CLASS_READ
+		}
+		return COMMAND_CLASS_CALL_CONTROL
+	case callControlApis[name] || strings.HasPrefix(name, "uuid_"):
+		return COMMAND_CLASS_CALL_CONTROL
+	}
+	return COMMAND_CLASS_UNKNOWN
+}
+
+// matchCommandRule - Whether the words of subject start with the words of rule, ignoring the case.
+func matchCommandRule(rule, subject string) bool {
+	ruleWords := strings.Fields(strings.ToLower(rule))
+	words := strings.Fields(strings.ToLower(subject))
+	if len(ruleWords) == 0 || len(ruleWords) > len(words) {
+		return false
+	}
+	for i, word := range ruleWords {
+		if words[i] != word {
+			return false
+		}
+	}
+	return true
+}
+
+// commandHeader - The value of a header line of a command, ignoring the case of its name.
+func commandHeader(command *Command, name string) string {
+	for _, line := range command.Lines[1:] {
+		if index := strings.Index(line, ":"); index > 0 && strings.EqualFold(line[:index], name) {
+			return strings.TrimSpace(line[index+1:])
+		}
+	}
+	return ""
+}
+
+// SetCommandPolicy - Check the api, bgapi and sendmsg commands of the client against policy before sending them,
+// nil removes the policy.
+//   - The policy runs after the command interceptors, so it sees the rewritten commands.
+func (client *Client) SetCommandPolicy(policy *CommandPolicy) {
+	client.interceptors.setPolicy(policy)
+}
+
+// GetCommandPolicy - The policy of the client, nil when every command is allowed.
+func (client *Client) GetCommandPolicy() *CommandPolicy {
+	return client.interceptors.getPolicy()
+}
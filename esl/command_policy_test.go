@@ -0,0 +1,91 @@
+package esl_test
+
+import (
+	"testing"
+
+	"github.com/zhouhailin/freeswitch-esl-go/esl"
+)
+
+func TestClassifyCommand(t *testing.T) {
+	for _, test := range []struct {
+		lines   []string
+		subject string
+		class   string
+	}{
+		{[]string{"api status"}, "status", esl.COMMAND_CLASS_READ},
+		{[]string{"bgapi  originate  user/1000 &park"}, "originate user/1000 &park", esl.COMMAND_CLASS_CALL_CONTROL},
+		{[]string{"api uuid_kill a"}, "uuid_kill a", esl.COMMAND_CLASS_CALL_CONTROL},
+		{[]string{"api uuid_getvar a x"}, "uuid_getvar a x", esl.COMMAND_CLASS_READ},
+		{[]string{"api sofia status"}, "sofia status", esl.COMMAND_CLASS_READ},
+		{[]string{"api sofia profile internal restart"}, "sofia profile internal restart", esl.COMMAND_CLASS_SYSTEM},
+		{[]string{"api conference room1 list"}, "conference room1 list", esl.COMMAND_CLASS_READ},
+		{[]string{"api conference room1 kick 3"}, "conference room1 kick 3", esl.COMMAND_CLASS_CALL_CONTROL},
+		{[]string{"api callcenter_config agent list"}, "callcenter_config agent list", esl.COMMAND_CLASS_READ},
+		{[]string{"api fsctl shutdown"}, "fsctl shutdown", esl.COMMAND_CLASS_SYSTEM},
+		{[]string{"api system rm -rf /tmp/x"}, "system rm -rf /tmp/x", esl.COMMAND_CLASS_SYSTEM},
+		{[]string{"bgapi bg_system /usr/local/bin/backup"}, "bg_system /usr/local/bin/backup", esl.COMMAND_CLASS_SYSTEM},
+		{[]string{"api frobnicate"}, "frobnicate", esl.COMMAND_CLASS_UNKNOWN},
+		{[]string{"sendmsg a", "call-command: execute", "execute-app-name: playback"}, "sendmsg execute playback", esl.COMMAND_CLASS_CALL_CONTROL},
+		{[]string{"event plain ALL"}, "event", ""},
+	} {
+		subject, class := esl.ClassifyCommand(&esl.Command{Lines: test.lines})
+		if subject != test.subject || class != test.class {
+			t.Errorf("%q: %q %q, want %q %q", test.lines, subject, class, test.subject, test.class)
+		}
+	}
+}
+
+func TestCommandPolicyCheck(t *testing.T) {
+	policy := &esl.CommandPolicy{
+		Classes: []string{esl.COMMAND_CLASS_READ},
+		Allow:   []string{"uuid_kill", "sendmsg hangup"},
+		Deny:    []string{"show registrations"},
+	}
+	for _, test := range []struct {
+		lines   []string
+		allowed bool
+	}{
+		{[]string{"api status"}, true},
+		{[]string{"api show channels"}, true},
+		{[]string{"api SHOW Registrations"}, false},
+		{[]string{"api uuid_kill a"}, true},
+		{[]string{"api uuid_transfer a 1000"}, false},
+		{[]string{"sendmsg a", "call-command: hangup"}, true},
+		{[]string{"sendmsg a", "call-command: execute", "execute-app-name: playback"}, false},
+		{[]string{"api fsctl shutdown"}, false},
+		{[]string{"event plain ALL"}, true},
+	} {
+		err := policy.Check(&esl.Command{Lines: test.lines})
+		if allowed := err == nil; allowed != test.allowed {
+			t.Errorf("%q: allowed %v, want %v (%v)", test.lines, allowed, test.allowed, err)
+		}
+		if _, ok := err.(*esl.CommandDeniedError); err != nil && !ok {
+			t.Errorf("%q: error %T", test.lines, err)
+		}
+	}
+}
+
+func TestReadOnlyCommandPolicy(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	server.SetApiResponse("status", "UP")
+	client.SetCommandPolicy(esl.ReadOnlyCommandPolicy())
+	connect(t, server, client)
+
+	if _, err := client.SendApi("status", ""); err != nil {
+		t.Errorf("status: %v", err)
+	}
+	_, err := client.SendApi("fsctl", "shutdown")
+	denied, ok := err.(*esl.CommandDeniedError)
+	if !ok || denied.Command != "fsctl shutdown" || denied.Class != esl.COMMAND_CLASS_SYSTEM {
+		t.Fatalf("fsctl: %T %v", err, err)
+	}
+	for _, cmd := range server.Commands() {
+		if cmd.Line == "api fsctl shutdown" {
+			t.Error("a denied command reached the switch")
+		}
+	}
+	client.SetCommandPolicy(nil)
+	if client.GetCommandPolicy() != nil {
+		t.Error("the policy was not removed")
+	}
+}
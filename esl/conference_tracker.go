@@ -0,0 +1,488 @@
+package esl
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/bytedance/gopkg/util/logger"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CONFERENCE_TRACKER_EVENTS - the events a ConferenceTracker subscribes to
+const CONFERENCE_TRACKER_EVENTS = "CUSTOM conference::maintenance"
+
+// ConferenceChangeType - What happened to a conference room or to one of its members.
+type ConferenceChangeType int
+
+const (
+	CONFERENCE_CREATED ConferenceChangeType = iota
+	CONFERENCE_UPDATED
+	CONFERENCE_DESTROYED
+	CONFERENCE_MEMBER_JOINED
+	CONFERENCE_MEMBER_UPDATED
+	CONFERENCE_MEMBER_LEFT
+)
+
+// ConferenceChange - A change of a conference room, Member is set for the member changes.
+//   - Action is the Action header of the conference::maintenance event, Event is nil when the change comes from a
+//   - resynchronization.
+type ConferenceChange struct {
+	Type       ConferenceChangeType
+	Action     string
+	Conference *Conference
+	Member     *ConferenceMember
+	Event      *EslEvent
+}
+
+// ConferenceMember - A member of a conference room.
+type ConferenceMember struct {
+	Id             int
+	Uuid           string
+	CallerIdName   string
+	CallerIdNumber string
+	Moderator      bool
+	Ghost          bool
+	Muted          bool
+	Deaf           bool
+	Talking        bool
+	Floor          bool
+	Energy         int
+	JoinedAt       time.Time
+}
+
+// Conference - A conference room and its members.
+type Conference struct {
+	Name      string
+	Domain    string
+	Uuid      string
+	Profile   string
+	Locked    bool
+	Recording bool
+	CreatedAt time.Time
+	// Members - the members ordered by id
+	Members []*ConferenceMember
+}
+
+// Member - Lookup a member by id.
+func (c *Conference) Member(id int) *ConferenceMember {
+	for _, member := range c.Members {
+		if member.Id == id {
+			return member
+		}
+	}
+	return nil
+}
+
+// FloorHolder - The member holding the floor, nil if none.
+func (c *Conference) FloorHolder() *ConferenceMember {
+	for _, member := range c.Members {
+		if member.Floor {
+			return member
+		}
+	}
+	return nil
+}
+
+func (c *Conference) clone() *Conference {
+	clone := *c
+	clone.Members = make([]*ConferenceMember, len(c.Members))
+	for i, member := range c.Members {
+		m := *member
+		clone.Members[i] = &m
+	}
+	return &clone
+}
+
+func (c *Conference) removeMember(id int) *ConferenceMember {
+	for i, member := range c.Members {
+		if member.Id == id {
+			c.Members = append(c.Members[:i], c.Members[i+1:]...)
+			return member
+		}
+	}
+	return nil
+}
+
+func (c *Conference) addMember(member *ConferenceMember) {
+	c.Members = append(c.Members, member)
+	sort.Slice(c.Members, func(i, j int) bool {
+		return c.Members[i].Id < c.Members[j].Id
+	})
+}
+
+// ConferenceTracker - Maintains the conference rooms of a Client from the conference::maintenance events and
+// controls them with the conference api.
+type ConferenceTracker struct {
+	listenerBase
+	client      *Client
+	mtx         sync.RWMutex
+	conferences map[string]*Conference
+	callbacks   []func(change *ConferenceChange)
+}
+
+// NewConferenceTracker - Constructor, registers the tracker as event and connection listener of client.
+func NewConferenceTracker(client *Client) *ConferenceTracker {
+	t := &ConferenceTracker{
+		client:      client,
+		conferences: make(map[string]*Conference),
+	}
+	client.AddEventListener(t)
+	client.AddConnectionListener(t)
+	return t
+}
+
+// Get - Lookup a conference room by name.
+//   - @return a copy of the conference, false if it is not running
+func (t *ConferenceTracker) Get(name string) (*Conference, bool) {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	conference, ok := t.conferences[name]
+	if !ok {
+		return nil, false
+	}
+	return conference.clone(), true
+}
+
+// List - A copy of every running conference room.
+func (t *ConferenceTracker) List() []*Conference {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	conferences := make([]*Conference, 0, len(t.conferences))
+	for _, conference := range t.conferences {
+		conferences = append(conferences, conference.clone())
+	}
+	return conferences
+}
+
+// OnChange - Register a callback, it is called from the event dispatch goroutine in the order of the changes.
+func (t *ConferenceTracker) OnChange(callback func(change *ConferenceChange)) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.callbacks = append(t.callbacks, callback)
+}
+
+// Mute - conference <name> mute <member>
+func (t *ConferenceTracker) Mute(conference string, memberId int) error {
+	return t.memberCommand(conference, "mute", memberId)
+}
+
+// Unmute - conference <name> unmute <member>
+func (t *ConferenceTracker) Unmute(conference string, memberId int) error {
+	return t.memberCommand(conference, "unmute", memberId)
+}
+
+// Deaf - conference <name> deaf <member>
+func (t *ConferenceTracker) Deaf(conference string, memberId int) error {
+	return t.memberCommand(conference, "deaf", memberId)
+}
+
+// Undeaf - conference <name> undeaf <member>
+func (t *ConferenceTracker) Undeaf(conference string, memberId int) error {
+	return t.memberCommand(conference, "undeaf", memberId)
+}
+
+// Kick - conference <name> kick <member>
+func (t *ConferenceTracker) Kick(conference string, memberId int) error {
+	return t.memberCommand(conference, "kick", memberId)
+}
+
+// Lock - conference <name> lock
+func (t *ConferenceTracker) Lock(conference string) error {
+	return t.Command(conference, "lock")
+}
+
+// Unlock - conference <name> unlock
+func (t *ConferenceTracker) Unlock(conference string) error {
+	return t.Command(conference, "unlock")
+}
+
+// Record - conference <name> record <path>, a path holding spaces is quoted.
+func (t *ConferenceTracker) Record(conference, path string) error {
+	return t.Command(conference, "record "+quoteArg(path))
+}
+
+// StopRecording - conference <name> norecord <path>, path may be "all".
+func (t *ConferenceTracker) StopRecording(conference, path string) error {
+	return t.Command(conference, "norecord "+quoteArg(path))
+}
+
+// Play - conference <name> play <file>, a file holding spaces is quoted.
+func (t *ConferenceTracker) Play(conference, file string) error {
+	return t.Command(conference, "play "+quoteArg(file))
+}
+
+// PlayToMember - conference <name> play <file> <member>
+func (t *ConferenceTracker) PlayToMember(conference, file string, memberId int) error {
+	return t.Command(conference, "play "+quoteArg(file)+" "+strconv.Itoa(memberId))
+}
+
+// Stop - conference <name> stop, stops the files being played.
+func (t *ConferenceTracker) Stop(conference string) error {
+	return t.Command(conference, "stop")
+}
+
+func (t *ConferenceTracker) memberCommand(conference, command string, memberId int) error {
+	return t.Command(conference, command+" "+strconv.Itoa(memberId))
+}
+
+// Command - Send "conference <name> <args>" and check the response.
+//   - @return an error holding the response when it starts with -ERR
+func (t *ConferenceTracker) Command(conference, args string) error {
+	message, err := t.client.SendSyncApiCommand("conference", conference+" "+args)
+	if err != nil {
+		return err
+	}
+	body := strings.TrimSpace(message.getBody())
+	if strings.HasPrefix(body, "-ERR") {
+		return errors.New("conference " + conference + " " + args + ": " + body)
+	}
+	return nil
+}
+
+// Sync - Subscribe to the conference events and replace the conference rooms with "conference json_list".
+func (t *ConferenceTracker) Sync() error {
+	if err := subscribe(t.client, CONFERENCE_TRACKER_EVENTS, "Conference"); err != nil {
+		return err
+	}
+	message, err := t.client.SendSyncApiCommand("conference", "json_list")
+	if err != nil {
+		return err
+	}
+	conferences, err := parseConferenceJsonList(message.getBody())
+	if err != nil {
+		return err
+	}
+	var changes []*ConferenceChange
+	t.mtx.Lock()
+	live := make(map[string]bool, len(conferences))
+	for _, conference := range conferences {
+		live[conference.Name] = true
+		changeType := CONFERENCE_UPDATED
+		if _, ok := t.conferences[conference.Name]; !ok {
+			changeType = CONFERENCE_CREATED
+		}
+		t.conferences[conference.Name] = conference
+		changes = append(changes, &ConferenceChange{Type: changeType, Conference: conference.clone()})
+	}
+	for name, conference := range t.conferences {
+		if !live[name] {
+			delete(t.conferences, name)
+			changes = append(changes, &ConferenceChange{Type: CONFERENCE_DESTROYED, Conference: conference.clone()})
+		}
+	}
+	callbacks := t.callbacks
+	t.mtx.Unlock()
+	runConferenceCallbacks(callbacks, changes)
+	return nil
+}
+
+// EventReceived - Implements IEslEventListener.
+func (t *ConferenceTracker) EventReceived(event *EslEvent) error {
+	headers := *event.GetEventHeaders()
+	name := headers["Conference-Name"]
+	if event.GetEventName() != "CUSTOM" || headers["Event-Subclass"] != "conference::maintenance" || name == "" {
+		return nil
+	}
+	action := headers["Action"]
+	var changes []*ConferenceChange
+	t.mtx.Lock()
+	conference, ok := t.conferences[name]
+	if action == "conference-destroy" {
+		if ok {
+			delete(t.conferences, name)
+			changes = append(changes, &ConferenceChange{Type: CONFERENCE_DESTROYED, Action: action, Conference: conference.clone(), Event: event})
+		}
+	} else {
+		if !ok {
+			conference = &Conference{Name: name, CreatedAt: eventTime(event)}
+			t.conferences[name] = conference
+			changes = append(changes, &ConferenceChange{Type: CONFERENCE_CREATED, Action: action, Conference: conference.clone(), Event: event})
+		}
+		setIfPresent(&conference.Domain, headers["Conference-Domain"])
+		setIfPresent(&conference.Uuid, headers["Conference-Unique-ID"])
+		setIfPresent(&conference.Profile, headers["Conference-Profile-Name"])
+		changes = append(changes, t.applyAction(conference, action, headers, event)...)
+	}
+	callbacks := t.callbacks
+	t.mtx.Unlock()
+	runConferenceCallbacks(callbacks, changes)
+	return nil
+}
+
+// applyAction - Must be called with the lock held.
+func (t *ConferenceTracker) applyAction(conference *Conference, action string, headers map[string]string, event *EslEvent) []*ConferenceChange {
+	roomChange := func() []*ConferenceChange {
+		return []*ConferenceChange{{Type: CONFERENCE_UPDATED, Action: action, Conference: conference.clone(), Event: event}}
+	}
+	switch action {
+	case "conference-create":
+		return nil
+	case "lock", "unlock":
+		conference.Locked = action == "lock"
+		return roomChange()
+	case "start-recording", "stop-recording":
+		conference.Recording = action == "start-recording"
+		return roomChange()
+	case "floor-change":
+		newId, _ := strconv.Atoi(headers["New-ID"])
+		var changes []*ConferenceChange
+		for _, member := range conference.Members {
+			if member.Floor != (member.Id == newId) {
+				member.Floor = member.Id == newId
+				changes = append(changes, &ConferenceChange{Type: CONFERENCE_MEMBER_UPDATED, Action: action, Conference: conference.clone(), Member: cloneMember(member), Event: event})
+			}
+		}
+		return changes
+	}
+	id, err := strconv.Atoi(headers["Member-ID"])
+	if err != nil {
+		return nil
+	}
+	if action == "del-member" {
+		if member := conference.removeMember(id); member != nil {
+			return []*ConferenceChange{{Type: CONFERENCE_MEMBER_LEFT, Action: action, Conference: conference.clone(), Member: member, Event: event}}
+		}
+		return nil
+	}
+	changeType := CONFERENCE_MEMBER_UPDATED
+	member := conference.Member(id)
+	if member == nil {
+		member = &ConferenceMember{Id: id, JoinedAt: eventTime(event)}
+		conference.addMember(member)
+		changeType = CONFERENCE_MEMBER_JOINED
+	}
+	member.apply(headers)
+	switch action {
+	case "start-talking", "stop-talking":
+		member.Talking = action == "start-talking"
+	case "mute-member", "unmute-member":
+		member.Muted = action == "mute-member"
+	case "deaf-member", "undeaf-member":
+		member.Deaf = action == "deaf-member"
+	}
+	return []*ConferenceChange{{Type: changeType, Action: action, Conference: conference.clone(), Member: cloneMember(member), Event: event}}
+}
+
+func (m *ConferenceMember) apply(headers map[string]string) {
+	setIfPresent(&m.Uuid, headers["Unique-ID"])
+	setIfPresent(&m.CallerIdName, headers["Caller-Caller-ID-Name"])
+	setIfPresent(&m.CallerIdNumber, headers["Caller-Caller-ID-Number"])
+	if value, ok := headers["Member-Type"]; ok {
+		m.Moderator = value == "moderator"
+	}
+	if value, ok := headers["Member-Ghost"]; ok {
+		m.Ghost = value == "true"
+	}
+	if value, ok := headers["Speak"]; ok {
+		m.Muted = value != "true"
+	}
+	if value, ok := headers["Hear"]; ok {
+		m.Deaf = value != "true"
+	}
+	if value, ok := headers["Talking"]; ok {
+		m.Talking = value == "true"
+	}
+	if value, ok := headers["Floor"]; ok {
+		m.Floor = value == "true"
+	}
+	if energy, err := strconv.Atoi(headers["Energy-Level"]); err == nil {
+		m.Energy = energy
+	}
+}
+
+func cloneMember(member *ConferenceMember) *ConferenceMember {
+	clone := *member
+	return &clone
+}
+
+func runConferenceCallbacks(callbacks []func(change *ConferenceChange), changes []*ConferenceChange) {
+	for _, change := range changes {
+		for _, callback := range callbacks {
+			callback(change)
+		}
+	}
+}
+
+// Authenticated - Implements IEslConnectionListener, resynchronizes the conference rooms.
+func (t *ConferenceTracker) Authenticated(authenticated bool, c *Client) {
+	if !authenticated {
+		return
+	}
+	if err := t.Sync(); err != nil {
+		logger.Errorf("Conference tracker synchronization failure, cause %v\n", err)
+	}
+}
+
+// parseConferenceJsonList - Parse the body of "conference json_list".
+func parseConferenceJsonList(body string) ([]*Conference, error) {
+	body = strings.TrimSpace(body)
+	if strings.HasPrefix(body, "No active conferences") {
+		return nil, nil
+	}
+	if strings.HasPrefix(body, "-ERR") {
+		return nil, errors.New(body)
+	}
+	var rows []struct {
+		Name      string `json:"conference_name"`
+		Uuid      string `json:"conference_uuid"`
+		Locked    bool   `json:"locked"`
+		Recording bool   `json:"recording"`
+		RunTime   int64  `json:"run_time"`
+		Members   []struct {
+			Type           string `json:"type"`
+			Id             int    `json:"id"`
+			Uuid           string `json:"uuid"`
+			CallerIdName   string `json:"caller_id_name"`
+			CallerIdNumber string `json:"caller_id_number"`
+			JoinTime       int64  `json:"join_time"`
+			Energy         int    `json:"energy"`
+			Flags          struct {
+				CanHear     bool `json:"can_hear"`
+				CanSpeak    bool `json:"can_speak"`
+				Talking     bool `json:"talking"`
+				HasFloor    bool `json:"has_floor"`
+				IsModerator bool `json:"is_moderator"`
+				IsGhost     bool `json:"is_ghost"`
+			} `json:"flags"`
+		} `json:"members"`
+	}
+	if err := json.Unmarshal([]byte(body), &rows); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	conferences := make([]*Conference, 0, len(rows))
+	for _, row := range rows {
+		conference := &Conference{
+			Name:      row.Name,
+			Uuid:      row.Uuid,
+			Locked:    row.Locked,
+			Recording: row.Recording,
+			CreatedAt: now.Add(-time.Duration(row.RunTime) * time.Second),
+		}
+		for _, m := range row.Members {
+			// only callers are members, the other entries are recording or playback nodes
+			if m.Type != "caller" {
+				continue
+			}
+			conference.addMember(&ConferenceMember{
+				Id:             m.Id,
+				Uuid:           m.Uuid,
+				CallerIdName:   m.CallerIdName,
+				CallerIdNumber: m.CallerIdNumber,
+				Moderator:      m.Flags.IsModerator,
+				Ghost:          m.Flags.IsGhost,
+				Muted:          !m.Flags.CanSpeak,
+				Deaf:           !m.Flags.CanHear,
+				Talking:        m.Flags.Talking,
+				Floor:          m.Flags.HasFloor,
+				Energy:         m.Energy,
+				JoinedAt:       now.Add(-time.Duration(m.JoinTime) * time.Second),
+			})
+		}
+		conferences = append(conferences, conference)
+	}
+	return conferences, nil
+}
@@ -0,0 +1,117 @@
+package esl_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/zhouhailin/freeswitch-esl-go/esl"
+	"github.com/zhouhailin/freeswitch-esl-go/esl/esltest"
+)
+
+const conferenceJsonList = `[{"conference_name":"3000","conference_uuid":"c1","locked":false,"recording":true,` +
+	`"run_time":60,"members":[{"type":"caller","id":1,"uuid":"u1","caller_id_name":"Alice",` +
+	`"caller_id_number":"1000","join_time":30,"energy":100,"flags":{"can_hear":true,"can_speak":true,` +
+	`"talking":false,"has_floor":true,"is_moderator":true,"is_ghost":false}},` +
+	`{"type":"recording_node","id":0,"record_path":"/tmp/3000.wav"}]}]` + "\n"
+
+// conferenceEvent - A conference::maintenance event of the room 3000.
+func conferenceEvent(action string) *esltest.Event {
+	return esltest.NewCustomEvent("conference::maintenance").Set("Conference-Name", "3000").Set("Action", action)
+}
+
+func TestConferenceTracker(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	server.SetApiResponse("conference", conferenceJsonList)
+	tracker := esl.NewConferenceTracker(client)
+	var mtx sync.Mutex
+	var changes []esl.ConferenceChangeType
+	tracker.OnChange(func(change *esl.ConferenceChange) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		changes = append(changes, change.Type)
+	})
+	conn := connect(t, server, client)
+	eventually(t, "the initial synchronization", func() bool {
+		_, ok := tracker.Get("3000")
+		return ok
+	})
+	conference, _ := tracker.Get("3000")
+	if !conference.Recording || len(conference.Members) != 1 || conference.FloorHolder().CallerIdName != "Alice" {
+		t.Fatalf("synchronized conference %+v", conference)
+	}
+
+	conn.SendEvent(conferenceEvent("add-member").Set("Member-ID", "2").Set("Unique-ID", "u2").
+		Set("Caller-Caller-ID-Number", "1001").Set("Speak", "true").Set("Hear", "true"))
+	conn.SendEvent(conferenceEvent("floor-change").Set("Old-ID", "1").Set("New-ID", "2"))
+	conn.SendEvent(conferenceEvent("mute-member").Set("Member-ID", "2"))
+	conn.SendEvent(conferenceEvent("del-member").Set("Member-ID", "1"))
+	conn.SendEvent(conferenceEvent("lock"))
+	eventually(t, "the maintenance events", func() bool {
+		conference, _ := tracker.Get("3000")
+		return conference.Locked
+	})
+	conference, _ = tracker.Get("3000")
+	member := conference.Member(2)
+	if len(conference.Members) != 1 || member == nil || !member.Floor || !member.Muted || member.CallerIdNumber != "1001" {
+		t.Fatalf("conference after the events %+v", conference)
+	}
+
+	conn.SendEvent(conferenceEvent("conference-destroy"))
+	eventually(t, "the conference destruction", func() bool {
+		_, ok := tracker.Get("3000")
+		return !ok
+	})
+	mtx.Lock()
+	defer mtx.Unlock()
+	want := []esl.ConferenceChangeType{esl.CONFERENCE_CREATED, esl.CONFERENCE_MEMBER_JOINED,
+		esl.CONFERENCE_MEMBER_UPDATED, esl.CONFERENCE_MEMBER_UPDATED, esl.CONFERENCE_MEMBER_UPDATED,
+		esl.CONFERENCE_MEMBER_LEFT, esl.CONFERENCE_UPDATED, esl.CONFERENCE_DESTROYED}
+	if len(changes) != len(want) {
+		t.Fatalf("changes %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Fatalf("changes %v, want %v", changes, want)
+		}
+	}
+}
+
+func TestConferenceCommands(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	responses := map[string]string{
+		"json_list":                      "No active conferences.\n",
+		"3000 kick 2":                    "+OK kicked 2\n",
+		"3000 kick 9":                    "-ERR Non-Existent ID 9\n",
+		"4000 lock":                      "-ERR Conference 4000 not found\n",
+		"3000 record '/tmp/my room.wav'": "+OK Record file /tmp/my room.wav\n",
+		"3000 play /tmp/hello.wav 2":     "+OK Playing file /tmp/hello.wav to member 2\n",
+		"3000 play 'it\\'s.wav'":         "+OK Playing file it's.wav\n",
+	}
+	server.HandleApi("conference", func(args string) string {
+		if response, ok := responses[args]; ok {
+			return response
+		}
+		return "-ERR unexpected " + args + "\n"
+	})
+	tracker := esl.NewConferenceTracker(client)
+	connect(t, server, client)
+
+	if err := tracker.Kick("3000", 2); err != nil {
+		t.Errorf("kick: %v", err)
+	}
+	if err := tracker.Kick("3000", 9); err == nil {
+		t.Error("kicking an unknown member must fail")
+	}
+	if err := tracker.Lock("4000"); err == nil {
+		t.Error("locking an unknown conference must fail")
+	}
+	if err := tracker.Record("3000", "/tmp/my room.wav"); err != nil {
+		t.Errorf("record: %v", err)
+	}
+	if err := tracker.PlayToMember("3000", "/tmp/hello.wav", 2); err != nil {
+		t.Errorf("play to member: %v", err)
+	}
+	if err := tracker.Play("3000", "it's.wav"); err != nil {
+		t.Errorf("play: %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package esl
 
 import (
+	"context"
 	"errors"
 	"github.com/cloudwego/netpoll"
 	"net"
@@ -17,11 +18,40 @@ type SocketConnection struct {
 	authenticatorResponded bool
 	authenticated          bool
 	rudeRejection          bool
-	listener               IEslProtocolListener
+	// responded - closed once the authentication is answered or rudely rejected, the fields above are set before
+	responded chan struct{}
+	listener  IEslProtocolListener
+	// interceptors - the chains of the client or of the session owning the connection, nil for none
+	interceptors *interceptors
+	// instruments - the metrics and the tracer of the client or of the server owning the connection, nil for none
+	instruments *instruments
+	// connMtx - guards Connection and authenticated, set again by every Connect
+	connMtx sync.RWMutex
+}
+
+// authenticationDone - Wake up Connect, it waits for the answer to the authentication.
+func (socket *SocketConnection) authenticationDone() {
+	select {
+	case <-socket.responded:
+	default:
+		close(socket.responded)
+	}
 }
 
 func (socket *SocketConnection) CanSend() bool {
-	return socket != nil && socket.Connection != nil && socket.IsActive() && socket.authenticated
+	if socket == nil {
+		return false
+	}
+	socket.connMtx.RLock()
+	defer socket.connMtx.RUnlock()
+	return socket.Connection != nil && socket.IsActive() && socket.authenticated
+}
+
+// connection - The current connection, nil before the first Connect.
+func (socket *SocketConnection) connection() netpoll.Connection {
+	socket.connMtx.RLock()
+	defer socket.connMtx.RUnlock()
+	return socket.Connection
 }
 
 // SendSyncApiCommand Sends a NextSWITCH API command to the server and blocks, waiting for an immediate response from the server.
@@ -165,11 +195,21 @@ func (socket *SocketConnection) SendEvent(sendMsg SendEvent) (*CommandResponse,
 //   - @param sendMsg a {@link SendMsg} with call UUID
 //   - @return a {@link CommandResponse} with the server's response.
 func (socket *SocketConnection) SendMessage(sendMsg SendMsg) (*CommandResponse, error) {
+	return socket.sendMessage(nil, sendMsg)
+}
+
+// sendMessage - SendMessage, the command carries ctx.
+func (socket *SocketConnection) sendMessage(ctx context.Context, sendMsg SendMsg) (*CommandResponse, error) {
 	err := socket.CheckConnected()
 	if err != nil {
 		return nil, err
 	}
-	response, err := socket.sendSyncMultiLineCommand(sendMsg.GetMsgLines())
+	err = sendMsg.Validate()
+	if err != nil {
+		return nil, err
+	}
+	lines := append([]string{}, *sendMsg.encode()...)
+	response, err := socket.sendCommand(&Command{Lines: lines, Body: sendMsg.GetBody(), Context: ctx})
 	if err != nil {
 		return nil, err
 	}
@@ -235,3 +275,16 @@ func (socket *SocketConnection) CheckConnected() error {
 	}
 	return errors.New("Not connected to FreeSWITCH Event Socket")
 }
+
+// subscribe - Set the plain event subscriptions of client, for the helpers which need events to work.
+//   - @param what names the events in the error, for example Channel
+func subscribe(client *Client, events, what string) error {
+	response, err := client.SetEventSubscriptions("plain", events)
+	if err != nil {
+		return err
+	}
+	if !response.IsOk() {
+		return errors.New(what + " events subscription failed: " + response.GetReplyText())
+	}
+	return nil
+}
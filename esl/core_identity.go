@@ -0,0 +1,141 @@
+package esl
+
+import (
+	"github.com/bytedance/gopkg/util/logger"
+)
+
+// CORE_IDENTITY_EVENTS - the events announcing a restart, subscribe to them to be notified as early as possible
+const CORE_IDENTITY_EVENTS = "SHUTDOWN STARTUP"
+
+// How a SwitchRestart was detected
+const (
+	// RESTART_DETECTED_ON_RECONNECT - the core_uuid read after authenticating differs from the known one
+	RESTART_DETECTED_ON_RECONNECT = "reconnect"
+	// RESTART_DETECTED_ON_EVENT - an event carried another Core-UUID
+	RESTART_DETECTED_ON_EVENT = "event"
+	// RESTART_DETECTED_ON_STARTUP - a STARTUP event was received
+	RESTART_DETECTED_ON_STARTUP = "startup"
+)
+
+// SwitchRestart - The switch behind the client restarted, or another switch answers on its address.
+//   - The channels, calls, registrations and jobs known before are stale. The trackers resynchronize when the client
+//   - authenticates, caches kept by the application should be reset.
+type SwitchRestart struct {
+	PreviousCoreUuid string
+	CoreUuid         string
+	// DetectedOn - one of RESTART_DETECTED_*
+	DetectedOn string
+	// ShutdownSeen - a SHUTDOWN event was received from the previous core
+	ShutdownSeen bool
+	// Event - the event revealing the restart, nil when detected on reconnect
+	Event *EslEvent
+}
+
+// CoreUuid - The Core-UUID of the switch, empty until known.
+func (client *Client) CoreUuid() string {
+	client.coreMtx.Lock()
+	defer client.coreMtx.Unlock()
+	return client.coreUuid
+}
+
+// OnSwitchRestart - Register a callback, it is called every time a restart is detected.
+//   - The callbacks are called from a goroutine notifying the restarts one at a time, in the order they were detected.
+func (client *Client) OnSwitchRestart(callback func(restart *SwitchRestart)) {
+	client.coreMtx.Lock()
+	defer client.coreMtx.Unlock()
+	client.restartCallbacks = append(client.restartCallbacks, callback)
+}
+
+// RefreshCoreIdentity - Read the core_uuid global variable, Connect calls it once authenticated.
+//   - @return the Core-UUID
+func (client *Client) RefreshCoreIdentity() (string, error) {
+	coreUuid, err := client.SendApi("global_getvar", "core_uuid")
+	if err != nil {
+		return "", err
+	}
+	client.observeCoreUuid(coreUuid, RESTART_DETECTED_ON_RECONNECT, nil)
+	return coreUuid, nil
+}
+
+func (client *Client) refreshCoreIdentity() {
+	if _, err := client.RefreshCoreIdentity(); err != nil {
+		logger.Warnf("Core identity refresh failure, cause %v\n", err)
+	}
+}
+
+// coreEventReceived - Check the Core-UUID of an event, and remember SHUTDOWN and STARTUP.
+func (client *Client) coreEventReceived(event *EslEvent) {
+	coreUuid := (*event.GetEventHeaders())["Core-UUID"]
+	switch event.GetEventName() {
+	case "SHUTDOWN":
+		if isInfoEnabled() {
+			logger.Infof("Switch %s is shutting down\n", coreUuid)
+		}
+		client.coreMtx.Lock()
+		client.coreShutdown = true
+		client.coreMtx.Unlock()
+	case "STARTUP":
+		client.observeCoreUuid(coreUuid, RESTART_DETECTED_ON_STARTUP, event)
+	default:
+		client.observeCoreUuid(coreUuid, RESTART_DETECTED_ON_EVENT, event)
+	}
+}
+
+// observeCoreUuid - Remember the Core-UUID, and notify the callbacks when it changed.
+func (client *Client) observeCoreUuid(coreUuid, detectedOn string, event *EslEvent) {
+	if coreUuid == "" {
+		return
+	}
+	client.coreMtx.Lock()
+	previous := client.coreUuid
+	if previous == coreUuid {
+		client.coreMtx.Unlock()
+		return
+	}
+	restart := &SwitchRestart{
+		PreviousCoreUuid: previous,
+		CoreUuid:         coreUuid,
+		DetectedOn:       detectedOn,
+		ShutdownSeen:     client.coreShutdown,
+		Event:            event,
+	}
+	client.coreUuid = coreUuid
+	client.coreShutdown = false
+	if previous == "" {
+		client.coreMtx.Unlock()
+		if isDebugEnabled() {
+			logger.Debugf("Switch core %s\n", coreUuid)
+		}
+		return
+	}
+	notify := false
+	if len(client.restartCallbacks) > 0 {
+		client.pendingRestarts = append(client.pendingRestarts, restart)
+		notify = !client.notifyingRestarts
+		client.notifyingRestarts = true
+	}
+	client.coreMtx.Unlock()
+	logger.Warnf("Switch restarted, core %s replaced %s\n", coreUuid, previous)
+	if notify {
+		go client.notifyRestarts()
+	}
+}
+
+// notifyRestarts - Call the callbacks with the pending restarts, in order, until none is left.
+func (client *Client) notifyRestarts() {
+	for {
+		client.coreMtx.Lock()
+		if len(client.pendingRestarts) == 0 {
+			client.notifyingRestarts = false
+			client.coreMtx.Unlock()
+			return
+		}
+		restart := client.pendingRestarts[0]
+		client.pendingRestarts = client.pendingRestarts[1:]
+		callbacks := client.restartCallbacks
+		client.coreMtx.Unlock()
+		for _, callback := range callbacks {
+			callback(restart)
+		}
+	}
+}
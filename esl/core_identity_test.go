@@ -0,0 +1,88 @@
+package esl_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zhouhailin/freeswitch-esl-go/esl"
+	"github.com/zhouhailin/freeswitch-esl-go/esl/esltest"
+)
+
+// onSwitchRestart - The restarts detected by client, in the order the callbacks received them.
+func onSwitchRestart(client *esl.Client) chan *esl.SwitchRestart {
+	restarts := make(chan *esl.SwitchRestart, 8)
+	client.OnSwitchRestart(func(restart *esl.SwitchRestart) {
+		// a slow callback does not reorder the restarts detected meanwhile
+		time.Sleep(20 * time.Millisecond)
+		restarts <- restart
+	})
+	return restarts
+}
+
+func nextRestart(t *testing.T, restarts chan *esl.SwitchRestart) *esl.SwitchRestart {
+	t.Helper()
+	select {
+	case restart := <-restarts:
+		return restart
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for the restart")
+		return nil
+	}
+}
+
+func TestSwitchRestartOnReconnect(t *testing.T) {
+	server, client := newTestClient(t, &esl.Options{AutoReconnection: true, ReconnectIntervalSeconds: 1})
+	server.SetCoreUuid("core-1")
+	restarts := onSwitchRestart(client)
+	connect(t, server, client)
+	if coreUuid := client.CoreUuid(); coreUuid != "core-1" {
+		t.Fatalf("core uuid %q once connected", coreUuid)
+	}
+
+	server.SetCoreUuid("core-2")
+	server.Disconnect()
+	if _, err := server.WaitConn(3 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	restart := nextRestart(t, restarts)
+	if restart.PreviousCoreUuid != "core-1" || restart.CoreUuid != "core-2" ||
+		restart.DetectedOn != esl.RESTART_DETECTED_ON_RECONNECT || restart.ShutdownSeen || restart.Event != nil {
+		t.Errorf("restart %+v", restart)
+	}
+	if coreUuid := client.CoreUuid(); coreUuid != "core-2" {
+		t.Errorf("core uuid %q after the restart", coreUuid)
+	}
+}
+
+func TestSwitchRestartOnEvents(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	server.SetCoreUuid("core-1")
+	restarts := onSwitchRestart(client)
+	conn := connect(t, server, client)
+	if _, err := client.SetEventSubscriptions("plain", esl.CORE_IDENTITY_EVENTS+" HEARTBEAT"); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SendEvent(esltest.NewEvent("HEARTBEAT").Set("Core-UUID", "core-1"))
+	conn.SendEvent(esltest.NewEvent("SHUTDOWN").Set("Core-UUID", "core-1"))
+	conn.SendEvent(esltest.NewEvent("STARTUP").Set("Core-UUID", "core-2"))
+	conn.SendEvent(esltest.NewEvent("HEARTBEAT").Set("Core-UUID", "core-3"))
+	conn.SendEvent(esltest.NewEvent("HEARTBEAT").Set("Core-UUID", "core-3"))
+
+	startup := nextRestart(t, restarts)
+	if startup.PreviousCoreUuid != "core-1" || startup.CoreUuid != "core-2" ||
+		startup.DetectedOn != esl.RESTART_DETECTED_ON_STARTUP || !startup.ShutdownSeen ||
+		startup.Event == nil || startup.Event.GetEventName() != "STARTUP" {
+		t.Errorf("startup %+v", startup)
+	}
+	changed := nextRestart(t, restarts)
+	if changed.PreviousCoreUuid != "core-2" || changed.CoreUuid != "core-3" ||
+		changed.DetectedOn != esl.RESTART_DETECTED_ON_EVENT || changed.ShutdownSeen {
+		t.Errorf("core uuid change %+v", changed)
+	}
+	select {
+	case restart := <-restarts:
+		t.Errorf("unexpected restart %+v", restart)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
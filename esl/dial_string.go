@@ -0,0 +1,381 @@
+package esl
+
+import (
+	"errors"
+	"strings"
+)
+
+// DialVariable - A channel variable set by a dial string.
+type DialVariable struct {
+	Name  string
+	Value string
+}
+
+// DialEndpoint - One endpoint of a dial string with its [leg] variables.
+type DialEndpoint struct {
+	Address   string
+	Variables []DialVariable
+}
+
+// NewDialEndpoint - Constructor for any endpoint address, for example sofia/internal/1000@example.com.
+func NewDialEndpoint(address string) *DialEndpoint {
+	return &DialEndpoint{Address: address}
+}
+
+// GatewayEndpoint - sofia/gateway/<gateway>/<number>
+func GatewayEndpoint(gateway, number string) *DialEndpoint {
+	return NewDialEndpoint("sofia/gateway/" + gateway + "/" + number)
+}
+
+// UserEndpoint - user/<user>@<domain>, the domain may be empty.
+func UserEndpoint(user, domain string) *DialEndpoint {
+	if domain == "" {
+		return NewDialEndpoint("user/" + user)
+	}
+	return NewDialEndpoint("user/" + user + "@" + domain)
+}
+
+// LoopbackEndpoint - loopback/<extension>/<context>, the context may be empty.
+func LoopbackEndpoint(extension, dialplanContext string) *DialEndpoint {
+	if dialplanContext == "" {
+		return NewDialEndpoint("loopback/" + extension)
+	}
+	return NewDialEndpoint("loopback/" + extension + "/" + dialplanContext)
+}
+
+// SofiaEndpoint - sofia/<profile>/<uri>
+func SofiaEndpoint(profile, uri string) *DialEndpoint {
+	return NewDialEndpoint("sofia/" + profile + "/" + uri)
+}
+
+// SetVariable - Set a [leg] variable of the endpoint.
+//   - @return the endpoint, so it can be built inline
+func (e *DialEndpoint) SetVariable(name, value string) *DialEndpoint {
+	e.Variables = setDialVariable(e.Variables, name, value)
+	return e
+}
+
+// ToString - [leg vars]address
+func (e *DialEndpoint) ToString() string {
+	return formatDialVariables("[", "]", e.Variables) + e.Address
+}
+
+// DialThread - The {thread} variables and the endpoints of one thread of an enterprise originate.
+//   - Groups are tried in sequence ("|" failover), the endpoints of a group are called simultaneously (",").
+type DialThread struct {
+	Variables []DialVariable
+	Groups    [][]*DialEndpoint
+}
+
+// ToString - {vars}a,b|c
+func (t *DialThread) ToString() string {
+	var sb strings.Builder
+	sb.WriteString(formatDialVariables("{", "}", t.Variables))
+	for i, group := range t.Groups {
+		if i > 0 {
+			sb.WriteString("|")
+		}
+		for j, endpoint := range group {
+			if j > 0 {
+				sb.WriteString(",")
+			}
+			sb.WriteString(endpoint.ToString())
+		}
+	}
+	return sb.String()
+}
+
+// DialString - A dial string, as used by originate, bridge and inline transfers.
+//   - <pre>
+//   - <enterprise vars>{vars}[leg vars]endpoint,[leg vars]endpoint|endpoint:_:{vars}endpoint
+//   - </pre>
+type DialString struct {
+	EnterpriseVariables []DialVariable
+	Threads             []*DialThread
+}
+
+// NewDialString - Constructor of an empty dial string with a single thread.
+func NewDialString() *DialString {
+	return &DialString{Threads: []*DialThread{{}}}
+}
+
+// SetVariable - Set a {variable} of the current thread, it applies to all its endpoints.
+func (d *DialString) SetVariable(name, value string) {
+	thread := d.currentThread()
+	thread.Variables = setDialVariable(thread.Variables, name, value)
+}
+
+// SetEnterpriseVariable - Set an <enterprise> variable, it applies to every thread.
+func (d *DialString) SetEnterpriseVariable(name, value string) {
+	d.EnterpriseVariables = setDialVariable(d.EnterpriseVariables, name, value)
+}
+
+// Add - Add an endpoint called simultaneously with the endpoints of the current group.
+func (d *DialString) Add(endpoint *DialEndpoint) {
+	thread := d.currentThread()
+	if len(thread.Groups) == 0 {
+		thread.Groups = append(thread.Groups, nil)
+	}
+	last := len(thread.Groups) - 1
+	thread.Groups[last] = append(thread.Groups[last], endpoint)
+}
+
+// AddFailover - Add an endpoint in a new group, called when every endpoint of the previous group failed.
+func (d *DialString) AddFailover(endpoint *DialEndpoint) {
+	thread := d.currentThread()
+	thread.Groups = append(thread.Groups, []*DialEndpoint{endpoint})
+}
+
+// AddThread - Start a new thread of an enterprise originate, called in parallel with the previous threads.
+func (d *DialString) AddThread() {
+	d.Threads = append(d.Threads, &DialThread{})
+}
+
+// Endpoints - Every endpoint, in order.
+func (d *DialString) Endpoints() []*DialEndpoint {
+	var endpoints []*DialEndpoint
+	for _, thread := range d.Threads {
+		for _, group := range thread.Groups {
+			endpoints = append(endpoints, group...)
+		}
+	}
+	return endpoints
+}
+
+// ToString - The dial string.
+func (d *DialString) ToString() string {
+	var sb strings.Builder
+	sb.WriteString(formatDialVariables("<", ">", d.EnterpriseVariables))
+	for i, thread := range d.Threads {
+		if i > 0 {
+			sb.WriteString(":_:")
+		}
+		sb.WriteString(thread.ToString())
+	}
+	return sb.String()
+}
+
+// InlineBridge - The inline dialplan bridging to the dial string, for uuid_transfer <uuid> <InlineBridge()> inline.
+//   - The m: prefix replaces the comma separating the inline applications, so the commas of the dial string stay.
+func (d *DialString) InlineBridge() string {
+	dialString := d.ToString()
+	for _, delimiter := range []string{"~", "^", "!", "#"} {
+		if !strings.Contains(dialString, delimiter) {
+			return "m:" + delimiter + ":bridge:" + dialString
+		}
+	}
+	return "bridge:" + dialString
+}
+
+func (d *DialString) currentThread() *DialThread {
+	if len(d.Threads) == 0 {
+		d.Threads = append(d.Threads, &DialThread{})
+	}
+	return d.Threads[len(d.Threads)-1]
+}
+
+// NewBridgeSendMsg - A sendmsg executing bridge to the dial string on the channel uuid.
+func NewBridgeSendMsg(uuid string, dialString *DialString) *SendMsg {
+	return NewExecuteMsg(uuid, "bridge", dialString.ToString())
+}
+
+// ParseDialString - Parse a dial string, the escaping of ToString and the ^^<delimiter> syntax are understood.
+func ParseDialString(s string) (*DialString, error) {
+	s = strings.TrimSpace(s)
+	d := &DialString{}
+	if strings.HasPrefix(s, "<") {
+		vars, rest, err := parseDialVariables(s, '<', '>')
+		if err != nil {
+			return nil, err
+		}
+		d.EnterpriseVariables = vars
+		s = rest
+	}
+	for _, threadString := range strings.Split(s, ":_:") {
+		thread := &DialThread{}
+		threadString = strings.TrimSpace(threadString)
+		if strings.HasPrefix(threadString, "{") {
+			vars, rest, err := parseDialVariables(threadString, '{', '}')
+			if err != nil {
+				return nil, err
+			}
+			thread.Variables = vars
+			threadString = rest
+		}
+		for _, groupString := range splitDialString(threadString, '|') {
+			var group []*DialEndpoint
+			for _, endpointString := range splitDialString(groupString, ',') {
+				endpoint := &DialEndpoint{}
+				endpointString = strings.TrimSpace(endpointString)
+				if strings.HasPrefix(endpointString, "[") {
+					vars, rest, err := parseDialVariables(endpointString, '[', ']')
+					if err != nil {
+						return nil, err
+					}
+					endpoint.Variables = vars
+					endpointString = rest
+				}
+				if endpointString == "" {
+					return nil, errors.New("Empty endpoint in dial string: " + s)
+				}
+				endpoint.Address = endpointString
+				group = append(group, endpoint)
+			}
+			if len(group) > 0 {
+				thread.Groups = append(thread.Groups, group)
+			}
+		}
+		d.Threads = append(d.Threads, thread)
+	}
+	return d, nil
+}
+
+func setDialVariable(variables []DialVariable, name, value string) []DialVariable {
+	for i := range variables {
+		if variables[i].Name == name {
+			variables[i].Value = value
+			return variables
+		}
+	}
+	return append(variables, DialVariable{Name: name, Value: value})
+}
+
+// formatDialVariables - Format a variable block, empty when there is no variable.
+//   - When a value holds a comma the block uses the ^^<delimiter> syntax with a delimiter found in no value, the
+//   - commas are escaped when no such delimiter exists. Values holding spaces, quotes or brackets are quoted.
+func formatDialVariables(open, close string, variables []DialVariable) string {
+	if len(variables) == 0 {
+		return ""
+	}
+	delimiter := ","
+	prefix := ""
+	for _, variable := range variables {
+		if strings.Contains(variable.Value, ",") {
+			delimiter = ""
+			break
+		}
+	}
+	if delimiter == "" {
+		for _, candidate := range []string{":", ";", "!", "#", "~"} {
+			used := false
+			for _, variable := range variables {
+				if strings.Contains(variable.Name, candidate) || strings.Contains(variable.Value, candidate) {
+					used = true
+					break
+				}
+			}
+			if !used {
+				delimiter, prefix = candidate, "^^"+candidate
+				break
+			}
+		}
+	}
+	var sb strings.Builder
+	sb.WriteString(open)
+	sb.WriteString(prefix)
+	for i, variable := range variables {
+		if i > 0 {
+			if delimiter == "" {
+				sb.WriteString(",")
+			} else {
+				sb.WriteString(delimiter)
+			}
+		}
+		sb.WriteString(variable.Name)
+		sb.WriteString("=")
+		value := variable.Value
+		if delimiter == "" {
+			value = strings.Replace(value, ",", "\\,", -1)
+		}
+		if strings.ContainsAny(value, " '{}[]<>") {
+			value = "'" + strings.Replace(value, "'", "\\'", -1) + "'"
+		}
+		sb.WriteString(value)
+	}
+	sb.WriteString(close)
+	return sb.String()
+}
+
+// parseDialVariables - Parse the variable block s starts with.
+//   - @return the variables and what follows the block
+func parseDialVariables(s string, open, close byte) ([]DialVariable, string, error) {
+	end := -1
+	quoted := false
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '\'':
+			quoted = !quoted
+		case close:
+			if !quoted {
+				end = i
+			}
+		}
+		if end >= 0 {
+			break
+		}
+	}
+	if end < 0 {
+		return nil, "", errors.New("Unterminated " + string(open) + " in dial string: " + s)
+	}
+	block := s[1:end]
+	delimiter := byte(',')
+	if strings.HasPrefix(block, "^^") && len(block) > 2 {
+		delimiter = block[2]
+		block = block[3:]
+	}
+	var variables []DialVariable
+	for _, part := range splitDialString(block, delimiter) {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		pair := strings.SplitN(part, "=", 2)
+		if len(pair) != 2 {
+			return nil, "", errors.New("Invalid variable [" + part + "] in dial string")
+		}
+		variables = append(variables, DialVariable{Name: strings.TrimSpace(pair[0]), Value: unquoteDialValue(pair[1])})
+	}
+	return variables, s[end+1:], nil
+}
+
+// splitDialString - Split on sep outside of quotes, variable blocks and escapes, the escapes are kept.
+func splitDialString(s string, sep byte) []string {
+	var parts []string
+	depth := 0
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\':
+			i++
+		case c == '\'':
+			quoted = !quoted
+		case quoted:
+		case c == '[' || c == '{' || c == '<':
+			depth++
+		case c == ']' || c == '}' || c == '>':
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unquoteDialValue - Remove the quotes and escapes added by formatDialVariables.
+func unquoteDialValue(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+		value = value[1 : len(value)-1]
+	}
+	var sb strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+		}
+		sb.WriteByte(value[i])
+	}
+	return sb.String()
+}
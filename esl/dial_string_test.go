@@ -0,0 +1,61 @@
+package esl_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/zhouhailin/freeswitch-esl-go/esl"
+)
+
+func TestDialStringToString(t *testing.T) {
+	d := esl.NewDialString()
+	d.SetVariable("ignore_early_media", "true")
+	d.Add(esl.UserEndpoint("1000", "example.com"))
+	d.Add(esl.GatewayEndpoint("gw1", "5551234").SetVariable("leg_timeout", "10"))
+	d.AddFailover(esl.LoopbackEndpoint("9999", "default"))
+	want := "{ignore_early_media=true}user/1000@example.com,[leg_timeout=10]sofia/gateway/gw1/5551234|loopback/9999/default"
+	if got := d.ToString(); got != want {
+		t.Fatalf("got %s\nwant %s", got, want)
+	}
+}
+
+func TestDialStringEscapesValues(t *testing.T) {
+	d := esl.NewDialString()
+	d.SetVariable("sip_h_X-List", "a,b")
+	d.SetVariable("effective_caller_id_name", "John Doe")
+	d.Add(esl.UserEndpoint("1000", ""))
+	want := "{^^:sip_h_X-List=a,b:effective_caller_id_name='John Doe'}user/1000"
+	if got := d.ToString(); got != want {
+		t.Fatalf("got %s\nwant %s", got, want)
+	}
+}
+
+func TestParseDialStringRoundTrip(t *testing.T) {
+	d := esl.NewDialString()
+	d.SetEnterpriseVariable("origination_caller_id_number", "1000")
+	d.SetVariable("sip_h_X-List", "a,b")
+	d.SetVariable("note", "it's {odd}")
+	d.Add(esl.UserEndpoint("1001", "").SetVariable("leg_delay_start", "5"))
+	d.AddFailover(esl.UserEndpoint("1002", ""))
+	d.AddThread()
+	d.Add(esl.SofiaEndpoint("external", "1003@example.com"))
+
+	parsed, err := esl.ParseDialString(d.ToString())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, d) {
+		t.Fatalf("round trip of %s gave %s", d.ToString(), parsed.ToString())
+	}
+	if n := len(parsed.Endpoints()); n != 3 {
+		t.Fatalf("%d endpoints", n)
+	}
+}
+
+func TestParseDialStringErrors(t *testing.T) {
+	for _, s := range []string{"{a=b user/1000", "[a]user/1000", "user/1000,,user/1001"} {
+		if _, err := esl.ParseDialString(s); err == nil {
+			t.Errorf("%s parsed without error", s)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+// Package esl - FreeSWITCH event socket library: the inbound Client, the outbound sessions and the helpers built on
+// their events.
+//
+// The trackers and monitors, for example ChannelTracker, subscribe to their events and resynchronize every time the
+// client authenticates. Call their Sync method when one is created for a client that is already connected.
+package esl
@@ -110,6 +110,11 @@ func (e *EslEvent) GetEventBodyLines() *[]string {
 	return &e.eventBody
 }
 
+// getBody - The event body lines joined back together.
+func (e *EslEvent) getBody() string {
+	return strings.Join(e.eventBody, LINE_TERMINATOR)
+}
+
 // GetEventName - Convenience method.
 //   - @return the string value of the event header "Event-Name"
 func (e *EslEvent) GetEventName() string {
@@ -82,6 +82,11 @@ func (m *EslMessage) addBodyLine(line string) {
 	m.body = append(m.body, line)
 }
 
+// getBody - The body lines joined back together.
+func (m *EslMessage) getBody() string {
+	return strings.Join(m.body, LINE_TERMINATOR)
+}
+
 // ToString - To String
 func (m *EslMessage) ToString() string {
 	var sb strings.Builder
@@ -0,0 +1,88 @@
+package esltest
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Event - An event injected by the fake server, encoded as text/event-plain like FreeSWITCH does.
+type Event struct {
+	headers map[string]string
+	body    string
+}
+
+// NewEvent - Constructor, the Event-Name header is set to name.
+//   - @param name the event name, for example CHANNEL_CREATE or CUSTOM
+func NewEvent(name string) *Event {
+	return &Event{headers: map[string]string{"Event-Name": name}}
+}
+
+// NewCustomEvent - Constructor for CUSTOM events, the Event-Subclass header is set to subclass.
+//   - @param subclass the event subclass, for example sofia::register
+func NewCustomEvent(subclass string) *Event {
+	return NewEvent("CUSTOM").Set("Event-Subclass", subclass)
+}
+
+// Set - Set an event header, the value is url encoded on the wire.
+func (e *Event) Set(name, value string) *Event {
+	e.headers[name] = value
+	return e
+}
+
+// SetBody - Set the event body, a Content-Length header is added on the wire.
+func (e *Event) SetBody(body string) *Event {
+	e.body = body
+	return e
+}
+
+// Get - The value of an event header.
+func (e *Event) Get(name string) string {
+	return e.headers[name]
+}
+
+// Name - The value of the Event-Name header.
+func (e *Event) Name() string {
+	return e.headers["Event-Name"]
+}
+
+// encode - The text/event-plain payload, Event-Name first and the other headers sorted by name.
+func (e *Event) encode() string {
+	var sb strings.Builder
+	for _, line := range e.headerLines() {
+		sb.WriteString(line)
+		sb.WriteString("\n")
+	}
+	if e.body != "" {
+		sb.WriteString("Content-Length: ")
+		sb.WriteString(strconv.Itoa(len(e.body)))
+		sb.WriteString("\n\n")
+		sb.WriteString(e.body)
+	} else {
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// headerLines - The "name: value" lines of the headers, Event-Name first and the others sorted by name.
+func (e *Event) headerLines() []string {
+	names := make([]string, 0, len(e.headers))
+	for name := range e.headers {
+		if name != "Event-Name" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	lines := make([]string, 0, len(e.headers))
+	lines = append(lines, "Event-Name: "+escape(e.headers["Event-Name"]))
+	for _, name := range names {
+		lines = append(lines, name+": "+escape(e.headers[name]))
+	}
+	return lines
+}
+
+// escape - FreeSWITCH url encodes header values, spaces included.
+func escape(value string) string {
+	return strings.Replace(url.QueryEscape(value), "+", "%20", -1)
+}
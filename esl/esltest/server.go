@@ -0,0 +1,695 @@
+// Package esltest provides an in-process fake FreeSWITCH event socket for hermetic tests of esl.Client.
+//
+// The server speaks the inbound event socket protocol: it requests authentication, answers the common
+// commands (api, bgapi, event, filter, sendmsg, exit, ...) with canned replies, and lets a test script
+// api results, inject events, send disconnect notices and delay replies. Like FreeSWITCH, it only delivers the
+// events a connection subscribed to with event, myevents or nixevent. DialOutbound connects it to an
+// outbound socket server, as FreeSWITCH does when a call reaches the socket application.
+package esltest
+
+import (
+	"bufio"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	disconnectNotice = "Disconnected, goodbye.\nSee you at ClueCon! http://www.cluecon.com/\n"
+	rudeRejection    = "Access Denied, go away.\n"
+)
+
+// ApiHandler - Produces the body of an api/response, or of a BACKGROUND_JOB event for bgapi.
+//   - @param args everything after the api command name
+type ApiHandler func(args string) string
+
+// CommandHandler - Replaces the default handling of a command, the handler must reply on conn itself.
+type CommandHandler func(conn *Conn, cmd *Command)
+
+// Server - A fake FreeSWITCH listening on a random port of 127.0.0.1.
+type Server struct {
+	Password        string
+	listener        net.Listener
+	mtx             sync.Mutex
+	conns           map[*Conn]struct{}
+	apiHandlers     map[string]ApiHandler
+	commandHandlers map[string]CommandHandler
+	commands        []*Command
+	rudeRejection   bool
+	replyDelay      time.Duration
+	coreUuid        string
+	connected       chan *Conn
+	wg              sync.WaitGroup
+}
+
+// NewServer - Starts a fake FreeSWITCH accepting the given password, it panics if it cannot listen.
+func NewServer(password string) *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("esltest: failed to listen on a port: %v", err))
+	}
+	s := &Server{
+		Password:        password,
+		listener:        listener,
+		conns:           make(map[*Conn]struct{}),
+		apiHandlers:     make(map[string]ApiHandler),
+		commandHandlers: make(map[string]CommandHandler),
+		coreUuid:        newUuid(),
+		connected:       make(chan *Conn, 16),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Host - The host to pass to esl.NewClient.
+func (s *Server) Host() string {
+	return s.listener.Addr().(*net.TCPAddr).IP.String()
+}
+
+// Port - The port to pass to esl.NewClient.
+func (s *Server) Port() uint {
+	return uint(s.listener.Addr().(*net.TCPAddr).Port)
+}
+
+// Addr - The listening address as host:port.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close - Stop listening and close every connection.
+func (s *Server) Close() {
+	_ = s.listener.Close()
+	for _, c := range s.Conns() {
+		_ = c.Close()
+	}
+	s.wg.Wait()
+}
+
+// SetRudeRejection - When true new connections receive a text/rude-rejection and are closed, as an ACL denial does.
+func (s *Server) SetRudeRejection(reject bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.rudeRejection = reject
+}
+
+// SetReplyDelay - Delay every reply sent after authentication.
+func (s *Server) SetReplyDelay(delay time.Duration) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.replyDelay = delay
+}
+
+// CoreUuid - The Core-UUID answered to "global_getvar core_uuid".
+func (s *Server) CoreUuid() string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.coreUuid
+}
+
+// SetCoreUuid - Change the Core-UUID, as a restart of FreeSWITCH does.
+func (s *Server) SetCoreUuid(coreUuid string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.coreUuid = coreUuid
+}
+
+// HandleApi - Script the result of an api command, used for both api and bgapi.
+//   - @param command the api command name, for example status
+func (s *Server) HandleApi(command string, handler ApiHandler) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.apiHandlers[command] = handler
+}
+
+// SetApiResponse - Script a fixed result for an api command.
+func (s *Server) SetApiResponse(command, body string) {
+	s.HandleApi(command, func(string) string {
+		return body
+	})
+}
+
+// HandleCommand - Replace the default handling of a command, for example sendmsg or event.
+//   - @param name the first word of the command line
+func (s *Server) HandleCommand(name string, handler CommandHandler) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.commandHandlers[name] = handler
+}
+
+// SendEvent - Send an event to every authenticated connection subscribed to it.
+func (s *Server) SendEvent(event *Event) {
+	for _, c := range s.Conns() {
+		if c.isAuthenticated() {
+			_ = c.SendEvent(event)
+		}
+	}
+}
+
+// Disconnect - Send a text/disconnect-notice to every connection and close it.
+func (s *Server) Disconnect() {
+	for _, c := range s.Conns() {
+		_ = c.SendDisconnectNotice()
+		_ = c.Close()
+	}
+}
+
+// Conns - The open connections.
+func (s *Server) Conns() []*Conn {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	conns := make([]*Conn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+// WaitConn - Wait for the next connection to authenticate successfully.
+func (s *Server) WaitConn(timeout time.Duration) (*Conn, error) {
+	select {
+	case c := <-s.connected:
+		return c, nil
+	case <-time.After(timeout):
+		return nil, errors.New("esltest: no connection authenticated within " + timeout.String())
+	}
+}
+
+// Commands - Every command received after authentication, in order.
+func (s *Server) Commands() []*Command {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	commands := make([]*Command, len(s.commands))
+	copy(commands, s.commands)
+	return commands
+}
+
+// WaitCommand - Wait for a command whose line starts with prefix to be received.
+func (s *Server) WaitCommand(prefix string, timeout time.Duration) (*Command, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		for _, cmd := range s.Commands() {
+			if strings.HasPrefix(cmd.Line, prefix) {
+				return cmd, nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.New("esltest: command [" + prefix + "] not received within " + timeout.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// DialOutbound - Connect to an outbound socket server for a channel, the connection needs no authentication.
+//   - @param channelData the headers of the channel, Unique-ID and Channel-Name are generated when missing
+func (s *Server) DialOutbound(address string, channelData *Event) (*Conn, error) {
+	if channelData == nil {
+		channelData = NewEvent("CHANNEL_DATA")
+	}
+	if channelData.Get("Unique-ID") == "" {
+		channelData.Set("Unique-ID", newUuid())
+	}
+	if channelData.Get("Channel-Name") == "" {
+		channelData.Set("Channel-Name", "sofia/internal/1000@127.0.0.1")
+	}
+	nc, err := net.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	c := &Conn{server: s, conn: nc, reader: bufio.NewReader(nc), authenticated: true, channelData: channelData}
+	s.mtx.Lock()
+	s.conns[c] = struct{}{}
+	s.mtx.Unlock()
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer s.remove(c)
+		c.serve()
+	}()
+	return c, nil
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		nc, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		c := &Conn{server: s, conn: nc, reader: bufio.NewReader(nc)}
+		s.mtx.Lock()
+		s.conns[c] = struct{}{}
+		reject := s.rudeRejection
+		s.mtx.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer s.remove(c)
+			if reject {
+				_ = c.write([]string{"Content-Type: text/rude-rejection"}, rudeRejection)
+				_ = c.Close()
+				return
+			}
+			c.serve()
+		}()
+	}
+}
+
+func (s *Server) remove(c *Conn) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	delete(s.conns, c)
+}
+
+func (s *Server) record(cmd *Command) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.commands = append(s.commands, cmd)
+}
+
+func (s *Server) apiHandler(command string) ApiHandler {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.apiHandlers[command]
+}
+
+func (s *Server) commandHandler(name string) CommandHandler {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.commandHandlers[name]
+}
+
+func (s *Server) delay() time.Duration {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.replyDelay
+}
+
+// Command - A command received from the client.
+type Command struct {
+	// Line - the first line, for example "api status" or "sendmsg <uuid>"
+	Line string
+	// Headers - the following "name: value" lines, as sent by sendmsg and sendevent
+	Headers map[string]string
+	// Body - the content after the headers when a Content-Length header was sent
+	Body string
+}
+
+// Name - The first word of the command line.
+func (c *Command) Name() string {
+	return strings.SplitN(c.Line, " ", 2)[0]
+}
+
+// Args - Everything after the first word of the command line.
+func (c *Command) Args() string {
+	parts := strings.SplitN(c.Line, " ", 2)
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[1]
+}
+
+// Conn - One client connection to the fake server.
+type Conn struct {
+	server        *Server
+	conn          net.Conn
+	reader        *bufio.Reader
+	wmtx          sync.Mutex
+	mtx           sync.Mutex
+	authenticated bool
+	// channelData - the channel of an outbound connection, sent as the reply of the connect command
+	channelData *Event
+	// subscriptions - the events the connection receives, changed by event, myevents, nixevent and noevents
+	subscriptions subscriptions
+}
+
+// subscriptions - The event subscriptions of a connection, as mod_event_socket keeps them.
+type subscriptions struct {
+	all        bool
+	events     map[string]bool
+	subclasses map[string]bool
+	// myUuid - set by myevents, only the events of this channel are then delivered
+	myUuid string
+}
+
+// Reply - Send a command/reply with the given Reply-Text.
+func (c *Conn) Reply(replyText string) error {
+	return c.write([]string{"Content-Type: command/reply", "Reply-Text: " + replyText}, "")
+}
+
+// ApiResponse - Send an api/response with the given body.
+func (c *Conn) ApiResponse(body string) error {
+	return c.write([]string{"Content-Type: api/response"}, body)
+}
+
+// SendEvent - Send an event to this connection, it is silently dropped when the connection is not subscribed to it.
+func (c *Conn) SendEvent(event *Event) error {
+	if !c.Subscribed(event) {
+		return nil
+	}
+	return c.write([]string{"Content-Type: text/event-plain"}, event.encode())
+}
+
+// Subscribed - Whether the connection receives the event: its name, or CUSTOM and its subclass, or ALL was
+// subscribed to, and the event belongs to the channel given to myevents if any.
+func (c *Conn) Subscribed(event *Event) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	sub := &c.subscriptions
+	if sub.myUuid != "" && event.Get("Unique-ID") != sub.myUuid {
+		return false
+	}
+	if sub.all {
+		return true
+	}
+	if !sub.events[event.Name()] {
+		return false
+	}
+	return event.Name() != "CUSTOM" || event.Get("Event-Subclass") == "" || sub.subclasses[event.Get("Event-Subclass")]
+}
+
+// subscribe - event [plain|json|xml] <names>, the words following CUSTOM are subclasses.
+func (c *Conn) subscribe(args string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	sub := &c.subscriptions
+	if sub.events == nil {
+		sub.events = make(map[string]bool)
+		sub.subclasses = make(map[string]bool)
+	}
+	custom := false
+	for i, word := range strings.Fields(args) {
+		if i == 0 && (word == "plain" || word == "json" || word == "xml") {
+			continue
+		}
+		switch {
+		case custom:
+			sub.subclasses[word] = true
+		case word == "ALL":
+			sub.all = true
+		default:
+			sub.events[word] = true
+			custom = word == "CUSTOM"
+		}
+	}
+}
+
+// unsubscribe - nixevent <names>, the words following CUSTOM are subclasses.
+func (c *Conn) unsubscribe(args string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	sub := &c.subscriptions
+	custom := false
+	words := strings.Fields(args)
+	for i, word := range words {
+		switch {
+		case custom:
+			delete(sub.subclasses, word)
+		case word == "ALL":
+			sub.all = false
+			sub.events = nil
+			sub.subclasses = nil
+		case word == "CUSTOM":
+			// CUSTOM alone removes the whole CUSTOM subscription, otherwise the subclasses following it
+			custom = true
+			if i == len(words)-1 {
+				delete(sub.events, word)
+			}
+		default:
+			delete(sub.events, word)
+		}
+	}
+}
+
+// clearSubscriptions - noevents.
+func (c *Conn) clearSubscriptions() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.subscriptions = subscriptions{}
+}
+
+// subscribeMyEvents - myevents [plain|json|xml] [<uuid>], every event of the channel, the channel of an outbound
+// connection by default.
+func (c *Conn) subscribeMyEvents(args string) {
+	uuid := c.Uuid()
+	for _, word := range strings.Fields(args) {
+		if word != "plain" && word != "json" && word != "xml" {
+			uuid = word
+		}
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.subscriptions.all = true
+	c.subscriptions.myUuid = uuid
+}
+
+// SendDisconnectNotice - Send a text/disconnect-notice, the connection is left open.
+func (c *Conn) SendDisconnectNotice() error {
+	return c.write([]string{"Content-Type: text/disconnect-notice"}, disconnectNotice)
+}
+
+// Close - Close the connection without notice, as a crashed switch would.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// RemoteAddr - The address of the client.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *Conn) isAuthenticated() bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.authenticated
+}
+
+func (c *Conn) write(headers []string, body string) error {
+	var sb strings.Builder
+	if body != "" {
+		sb.WriteString("Content-Length: ")
+		sb.WriteString(strconv.Itoa(len(body)))
+		sb.WriteString("\n")
+	}
+	for _, header := range headers {
+		sb.WriteString(header)
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\n")
+	sb.WriteString(body)
+	c.wmtx.Lock()
+	defer c.wmtx.Unlock()
+	_, err := io.WriteString(c.conn, sb.String())
+	return err
+}
+
+// ChannelData - The channel of an outbound connection, nil for an inbound one.
+func (c *Conn) ChannelData() *Event {
+	return c.channelData
+}
+
+// Uuid - The Unique-ID of the channel of an outbound connection.
+func (c *Conn) Uuid() string {
+	if c.channelData == nil {
+		return ""
+	}
+	return c.channelData.Get("Unique-ID")
+}
+
+// SendOutboundDisconnectNotice - Send the text/disconnect-notice of a hung up outbound channel.
+//   - @param linger true when the client asked to linger, the connection then stays open for the last events
+func (c *Conn) SendOutboundDisconnectNotice(linger bool) error {
+	disposition := "disconnect"
+	if linger {
+		disposition = "linger"
+	}
+	return c.write([]string{
+		"Content-Type: text/disconnect-notice",
+		"Controlled-Session-UUID: " + c.Uuid(),
+		"Content-Disposition: " + disposition,
+		"Channel-Name: " + escape(c.channelData.Get("Channel-Name")),
+	}, disconnectNotice)
+}
+
+func (c *Conn) serve() {
+	defer c.conn.Close()
+	if c.channelData == nil && c.write([]string{"Content-Type: auth/request"}, "") != nil {
+		return
+	}
+	for {
+		cmd, err := c.readCommand()
+		if err != nil {
+			return
+		}
+		if !c.isAuthenticated() {
+			if !c.authenticate(cmd) {
+				return
+			}
+			continue
+		}
+		c.server.record(cmd)
+		if delay := c.server.delay(); delay > 0 {
+			time.Sleep(delay)
+		}
+		if handler := c.server.commandHandler(cmd.Name()); handler != nil {
+			handler(c, cmd)
+			continue
+		}
+		if !c.handle(cmd) {
+			return
+		}
+	}
+}
+
+func (c *Conn) readCommand() (*Command, error) {
+	cmd := &Command{Headers: make(map[string]string)}
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if cmd.Line == "" {
+				continue
+			}
+			break
+		}
+		if cmd.Line == "" {
+			cmd.Line = line
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) == 2 {
+			cmd.Headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+	for name, value := range cmd.Headers {
+		if strings.EqualFold(name, "Content-Length") {
+			length, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, err
+			}
+			body := make([]byte, length)
+			if _, err = io.ReadFull(c.reader, body); err != nil {
+				return nil, err
+			}
+			cmd.Body = string(body)
+		}
+	}
+	return cmd, nil
+}
+
+func (c *Conn) authenticate(cmd *Command) bool {
+	if cmd.Name() != "auth" || cmd.Args() != c.server.Password {
+		_ = c.Reply("-ERR invalid")
+		return false
+	}
+	c.mtx.Lock()
+	c.authenticated = true
+	c.mtx.Unlock()
+	if c.Reply("+OK accepted") != nil {
+		return false
+	}
+	select {
+	case c.server.connected <- c:
+	default:
+	}
+	return true
+}
+
+// handle - The default replies, it returns false when the connection must be closed.
+func (c *Conn) handle(cmd *Command) bool {
+	switch cmd.Name() {
+	case "connect":
+		if c.channelData == nil {
+			_ = c.Reply("-ERR command not found")
+			break
+		}
+		headers := []string{"Content-Type: command/reply", "Reply-Text: +OK", "Socket-Mode: async", "Control: full"}
+		_ = c.write(append(headers, c.channelData.headerLines()...), "")
+	case "api":
+		_ = c.ApiResponse(c.api(cmd.Args()))
+	case "bgapi":
+		jobUuid := cmd.Headers["Job-UUID"]
+		if jobUuid == "" {
+			jobUuid = newUuid()
+		}
+		_ = c.write([]string{"Content-Type: command/reply", "Reply-Text: +OK Job-UUID: " + jobUuid, "Job-UUID: " + jobUuid}, "")
+		go c.backgroundJob(jobUuid, cmd.Args())
+	case "event":
+		c.subscribe(cmd.Args())
+		_ = c.Reply("+OK event listener enabled " + strings.SplitN(cmd.Args(), " ", 2)[0])
+	case "noevents":
+		c.clearSubscriptions()
+		_ = c.Reply("+OK no longer listening for events")
+	case "nixevent":
+		c.unsubscribe(cmd.Args())
+		_ = c.Reply("+OK events nixed")
+	case "myevents":
+		c.subscribeMyEvents(cmd.Args())
+		_ = c.Reply("+OK Events Enabled")
+	case "filter":
+		parts := strings.SplitN(cmd.Args(), " ", 2)
+		if parts[0] == "delete" {
+			_ = c.Reply("+OK filter deleted. [" + strings.Replace(cmd.Args(), "delete ", "", 1) + "]")
+		} else if len(parts) == 2 {
+			_ = c.Reply("+OK filter added. [" + parts[0] + "]=[" + parts[1] + "]")
+		} else {
+			_ = c.Reply("-ERR invalid syntax")
+		}
+	case "log":
+		_ = c.Reply("+OK log level " + cmd.Args())
+	case "nolog":
+		_ = c.Reply("+OK no longer spewing logs.")
+	case "sendmsg", "sendevent", "divert_events", "linger", "nolinger", "resume":
+		_ = c.Reply("+OK")
+	case "exit":
+		_ = c.Reply("+OK bye")
+		_ = c.SendDisconnectNotice()
+		return false
+	default:
+		_ = c.Reply("-ERR command not found")
+	}
+	return true
+}
+
+func (c *Conn) api(line string) string {
+	parts := strings.SplitN(line, " ", 2)
+	args := ""
+	if len(parts) == 2 {
+		args = parts[1]
+	}
+	if handler := c.server.apiHandler(parts[0]); handler != nil {
+		return handler(args)
+	}
+	if parts[0] == "global_getvar" && args == "core_uuid" {
+		return c.server.CoreUuid()
+	}
+	return "-ERR " + parts[0] + " Command not found!\n"
+}
+
+func (c *Conn) backgroundJob(jobUuid, line string) {
+	parts := strings.SplitN(line, " ", 2)
+	event := NewEvent("BACKGROUND_JOB").Set("Job-UUID", jobUuid).Set("Job-Command", parts[0])
+	if len(parts) == 2 {
+		event.Set("Job-Command-Arg", parts[1])
+	}
+	event.SetBody(c.api(line))
+	_ = c.SendEvent(event)
+}
+
+// newUuid - A random version 4 UUID.
+func newUuid() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
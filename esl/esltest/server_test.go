@@ -0,0 +1,201 @@
+package esltest
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testClient - A raw event socket client.
+type testClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// frame - A message received from the fake server.
+type frame struct {
+	headers map[string]string
+	body    string
+}
+
+func dial(t *testing.T, server *Server) *testClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	c := &testClient{t: t, conn: conn, reader: bufio.NewReader(conn)}
+	if f := c.read(); f.headers["Content-Type"] != "auth/request" {
+		t.Fatalf("expected auth/request, got %v", f.headers)
+	}
+	if reply := c.command("auth " + server.Password); reply != "+OK accepted" {
+		t.Fatalf("auth: %s", reply)
+	}
+	return c
+}
+
+func (c *testClient) read() *frame {
+	c.t.Helper()
+	_ = c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	f := &frame{headers: make(map[string]string)}
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		line = strings.TrimRight(line, "\n")
+		if line == "" {
+			break
+		}
+		parts := strings.SplitN(line, ": ", 2)
+		f.headers[parts[0]] = parts[1]
+	}
+	if length, _ := strconv.Atoi(f.headers["Content-Length"]); length > 0 {
+		body := make([]byte, length)
+		if _, err := io.ReadFull(c.reader, body); err != nil {
+			c.t.Fatal(err)
+		}
+		f.body = string(body)
+	}
+	return f
+}
+
+// command - Send a command and return the Reply-Text of its command/reply.
+func (c *testClient) command(line string) string {
+	c.t.Helper()
+	if _, err := io.WriteString(c.conn, line+"\n\n"); err != nil {
+		c.t.Fatal(err)
+	}
+	return c.read().headers["Reply-Text"]
+}
+
+// events - Send the events followed by a marker, and return the names of the events received before the marker.
+func (c *testClient) events(server *Server, events ...*Event) []string {
+	c.t.Helper()
+	for _, event := range events {
+		server.SendEvent(event)
+	}
+	server.SendEvent(NewCustomEvent("test::marker"))
+	var names []string
+	for {
+		f := c.read()
+		lines := strings.Split(f.body, "\n")
+		headers := make(map[string]string)
+		for _, line := range lines {
+			if parts := strings.SplitN(line, ": ", 2); len(parts) == 2 {
+				headers[parts[0]] = parts[1]
+			}
+		}
+		name := headers["Event-Name"]
+		if subclass := headers["Event-Subclass"]; subclass != "" {
+			if subclass == "test%3A%3Amarker" {
+				return names
+			}
+			name += " " + subclass
+		}
+		names = append(names, name)
+	}
+}
+
+func TestEventsAreOnlyDeliveredWhenSubscribed(t *testing.T) {
+	server := NewServer("ClueCon")
+	defer server.Close()
+	c := dial(t, server)
+	c.command("event plain CHANNEL_CREATE CUSTOM test::marker sofia::register")
+	got := c.events(server,
+		NewEvent("HEARTBEAT"),
+		NewEvent("CHANNEL_CREATE"),
+		NewCustomEvent("sofia::register"),
+		NewCustomEvent("sofia::unregister"),
+		NewEvent("CHANNEL_DESTROY"))
+	if want := []string{"CHANNEL_CREATE", "CUSTOM sofia%3A%3Aregister"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("received %v, want %v", got, want)
+	}
+}
+
+func TestSubscriptionsAddUp(t *testing.T) {
+	server := NewServer("ClueCon")
+	defer server.Close()
+	c := dial(t, server)
+	c.command("event plain CUSTOM test::marker")
+	c.command("event plain HEARTBEAT")
+	c.command("event json CHANNEL_CREATE")
+	got := c.events(server, NewEvent("HEARTBEAT"), NewEvent("CHANNEL_CREATE"), NewEvent("CHANNEL_ANSWER"))
+	if want := []string{"HEARTBEAT", "CHANNEL_CREATE"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("received %v, want %v", got, want)
+	}
+}
+
+func TestAllEvents(t *testing.T) {
+	server := NewServer("ClueCon")
+	defer server.Close()
+	c := dial(t, server)
+	c.command("event plain ALL")
+	got := c.events(server, NewEvent("HEARTBEAT"), NewCustomEvent("sofia::register"))
+	if want := []string{"HEARTBEAT", "CUSTOM sofia%3A%3Aregister"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("received %v, want %v", got, want)
+	}
+}
+
+func TestNixeventAndNoevents(t *testing.T) {
+	server := NewServer("ClueCon")
+	defer server.Close()
+	c := dial(t, server)
+	c.command("event plain HEARTBEAT CHANNEL_CREATE CUSTOM test::marker sofia::register")
+	c.command("nixevent HEARTBEAT CUSTOM sofia::register")
+	got := c.events(server, NewEvent("HEARTBEAT"), NewEvent("CHANNEL_CREATE"), NewCustomEvent("sofia::register"))
+	if want := []string{"CHANNEL_CREATE"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("received %v, want %v", got, want)
+	}
+	c.command("noevents")
+	for _, conn := range server.Conns() {
+		if conn.Subscribed(NewEvent("CHANNEL_CREATE")) || conn.Subscribed(NewCustomEvent("test::marker")) {
+			t.Fatal("still subscribed after noevents")
+		}
+	}
+}
+
+func TestBackgroundJobIsOnlyDeliveredWhenSubscribed(t *testing.T) {
+	server := NewServer("ClueCon")
+	defer server.Close()
+	server.SetApiResponse("status", "UP")
+	c := dial(t, server)
+	c.command("event plain CUSTOM test::marker")
+	if reply := c.command("bgapi status"); !strings.HasPrefix(reply, "+OK Job-UUID: ") {
+		t.Fatalf("bgapi: %s", reply)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := c.events(server); len(got) != 0 {
+		t.Fatalf("received %v without a BACKGROUND_JOB subscription", got)
+	}
+	c.command("event plain BACKGROUND_JOB")
+	c.command("bgapi status")
+	if f := c.read(); !strings.Contains(f.body, "Event-Name: BACKGROUND_JOB") || !strings.HasSuffix(f.body, "UP") {
+		t.Fatalf("unexpected event %q", f.body)
+	}
+}
+
+func TestMyEventsOnlyDeliversTheEventsOfTheChannel(t *testing.T) {
+	server := NewServer("ClueCon")
+	defer server.Close()
+	c := dial(t, server)
+	mine := NewEvent("CHANNEL_ANSWER").Set("Unique-ID", "mine")
+	if server.Conns()[0].Subscribed(mine) {
+		t.Fatal("subscribed before myevents")
+	}
+	c.command("myevents mine")
+	conn := server.Conns()[0]
+	if !conn.Subscribed(mine) {
+		t.Fatal("not subscribed to the events of the channel")
+	}
+	if conn.Subscribed(NewEvent("CHANNEL_ANSWER").Set("Unique-ID", "other")) {
+		t.Fatal("subscribed to the events of another channel")
+	}
+}
@@ -0,0 +1,129 @@
+package esl
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// EXECUTE_EVENTS - The events ExecuteAndWait subscribes to.
+const EXECUTE_EVENTS = "CHANNEL_EXECUTE_COMPLETE CHANNEL_HANGUP"
+
+// ExecuteResult - The outcome of an application run by ExecuteAndWait.
+type ExecuteResult struct {
+	Application string
+	// Response - The Application-Response header, for example the result of play_and_get_digits or FILE PLAYED
+	Response string
+	// Event - The CHANNEL_EXECUTE_COMPLETE event
+	Event *EslEvent
+}
+
+// ChannelHangupError - The channel hung up before the application completed.
+type ChannelHangupError struct {
+	Uuid  string
+	Cause string
+}
+
+func (e *ChannelHangupError) Error() string {
+	return "channel " + e.Uuid + " hung up: " + e.Cause
+}
+
+type executeWaiter struct {
+	channelUuid string
+	done        chan *EslEvent
+}
+
+// executions - The applications waiting for their CHANNEL_EXECUTE_COMPLETE, keyed by Application-UUID.
+type executions struct {
+	mtx     sync.Mutex
+	waiters map[string]*executeWaiter
+}
+
+// ExecuteAndWait - Execute an application on the channel and block until its CHANNEL_EXECUTE_COMPLETE event is
+// received or ctx is done.
+//   - The sendmsg carries an Event-UUID chosen by the client, FreeSWITCH echoes it as the Application-UUID of the
+//   - completion event. The client subscribes to EXECUTE_EVENTS, event filters must let them through.
+//   - @return a *ChannelHangupError when the channel hangs up first
+func (client *Client) ExecuteAndWait(ctx context.Context, uuid, application, arg string) (*ExecuteResult, error) {
+	if uuid == "" {
+		return nil, errors.New("ExecuteAndWait requires the uuid of the channel")
+	}
+	if err := subscribe(client, EXECUTE_EVENTS, "Execute"); err != nil {
+		return nil, err
+	}
+	return client.executions.executeAndWait(ctx, &client.SocketConnection, uuid, application, arg, nil)
+}
+
+// executeAndWait - Send the execute sendmsg on socket and wait for the completion, the hangup of the channel, ctx
+// or closed.
+func (e *executions) executeAndWait(ctx context.Context, socket *SocketConnection, uuid, application, arg string,
+	closed <-chan struct{}) (*ExecuteResult, error) {
+	applicationUuid := newUuid()
+	waiter := &executeWaiter{channelUuid: uuid, done: make(chan *EslEvent, 1)}
+	e.mtx.Lock()
+	if e.waiters == nil {
+		e.waiters = make(map[string]*executeWaiter)
+	}
+	e.waiters[applicationUuid] = waiter
+	e.mtx.Unlock()
+	defer func() {
+		e.mtx.Lock()
+		delete(e.waiters, applicationUuid)
+		e.mtx.Unlock()
+	}()
+
+	sendMsg := NewExecuteMsg(uuid, application, arg)
+	sendMsg.AddGenericLine("Event-UUID", applicationUuid)
+	response, err := socket.sendMessage(ctx, *sendMsg)
+	if err != nil {
+		return nil, err
+	}
+	if !response.IsOk() {
+		return nil, errors.New("execute " + application + " rejected: " + response.GetReplyText())
+	}
+
+	select {
+	case event := <-waiter.done:
+		headers := *event.GetEventHeaders()
+		if event.GetEventName() == "CHANNEL_HANGUP" {
+			return nil, &ChannelHangupError{Uuid: uuid, Cause: headers["Hangup-Cause"]}
+		}
+		return &ExecuteResult{
+			Application: headers["Application"],
+			Response:    headers["Application-Response"],
+			Event:       event,
+		}, nil
+	case <-closed:
+		return nil, &ChannelHangupError{Uuid: uuid, Cause: "DISCONNECTED"}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// eventReceived - Hand a CHANNEL_EXECUTE_COMPLETE or CHANNEL_HANGUP event to the executions waiting for it.
+func (e *executions) eventReceived(event *EslEvent) {
+	headers := *event.GetEventHeaders()
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	if len(e.waiters) == 0 {
+		return
+	}
+	if event.GetEventName() == "CHANNEL_EXECUTE_COMPLETE" {
+		if waiter := e.waiters[headers["Application-UUID"]]; waiter != nil {
+			waiter.notify(event)
+		}
+		return
+	}
+	for _, waiter := range e.waiters {
+		if waiter.channelUuid == headers["Unique-ID"] {
+			waiter.notify(event)
+		}
+	}
+}
+
+func (w *executeWaiter) notify(event *EslEvent) {
+	select {
+	case w.done <- event:
+	default:
+	}
+}
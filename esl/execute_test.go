@@ -0,0 +1,72 @@
+package esl_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/zhouhailin/freeswitch-esl-go/esl"
+	"github.com/zhouhailin/freeswitch-esl-go/esl/esltest"
+)
+
+// completeExecutions - Answer every execute sendmsg with a CHANNEL_EXECUTE_COMPLETE, or with a CHANNEL_HANGUP when
+// the application is hangup_first.
+func completeExecutions(server *esltest.Server, variables map[string]string) {
+	server.HandleCommand("sendmsg", func(conn *esltest.Conn, cmd *esltest.Command) {
+		_ = conn.Reply("+OK")
+		uuid := cmd.Args()
+		if cmd.Headers["execute-app-name"] == "hangup_first" {
+			_ = conn.SendEvent(esltest.NewEvent("CHANNEL_HANGUP").Set("Unique-ID", uuid).
+				Set("Hangup-Cause", "NORMAL_CLEARING"))
+			return
+		}
+		event := esltest.NewEvent("CHANNEL_EXECUTE_COMPLETE").
+			Set("Unique-ID", uuid).
+			Set("Application-UUID", cmd.Headers["Event-UUID"]).
+			Set("Application", cmd.Headers["execute-app-name"]).
+			Set("Application-Data", cmd.Headers["execute-app-arg"]).
+			Set("Application-Response", "_none_")
+		for name, value := range variables {
+			event.Set("variable_"+name, value)
+		}
+		_ = conn.SendEvent(event)
+	})
+}
+
+func TestExecuteAndWait(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	completeExecutions(server, nil)
+	connect(t, server, client)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	result, err := client.ExecuteAndWait(ctx, "u1", "playback", "/tmp/a.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Application != "playback" || result.Response != "_none_" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	waitCommand(t, server, "event plain "+esl.EXECUTE_EVENTS)
+}
+
+func TestExecuteAndWaitHangup(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	completeExecutions(server, nil)
+	connect(t, server, client)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err := client.ExecuteAndWait(ctx, "u1", "hangup_first", "")
+	if hangup, ok := err.(*esl.ChannelHangupError); !ok || hangup.Cause != "NORMAL_CLEARING" {
+		t.Fatalf("expected a ChannelHangupError, got %v", err)
+	}
+}
+
+func TestExecuteAndWaitContext(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	connect(t, server, client)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.ExecuteAndWait(ctx, "u1", "playback", "x"); err != context.DeadlineExceeded {
+		t.Fatalf("expected the deadline, got %v", err)
+	}
+}
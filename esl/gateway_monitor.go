@@ -0,0 +1,393 @@
+package esl
+
+import (
+	"encoding/xml"
+	"github.com/bytedance/gopkg/util/logger"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GATEWAY_MONITOR_EVENTS - the events a GatewayMonitor subscribes to
+const GATEWAY_MONITOR_EVENTS = "CUSTOM sofia::gateway_state sofia::gateway_add sofia::gateway_delete"
+
+// sofia gateway registration states
+const (
+	GATEWAY_STATE_UNREGED    = "UNREGED"
+	GATEWAY_STATE_TRYING     = "TRYING"
+	GATEWAY_STATE_REGISTER   = "REGISTER"
+	GATEWAY_STATE_REGED      = "REGED"
+	GATEWAY_STATE_UNREGISTER = "UNREGISTER"
+	GATEWAY_STATE_FAILED     = "FAILED"
+	GATEWAY_STATE_FAIL_WAIT  = "FAIL_WAIT"
+	GATEWAY_STATE_EXPIRED    = "EXPIRED"
+	GATEWAY_STATE_NOREG      = "NOREG"
+	GATEWAY_STATE_DOWN       = "DOWN"
+)
+
+// sofia gateway ping statuses
+const (
+	GATEWAY_STATUS_UP   = "UP"
+	GATEWAY_STATUS_DOWN = "DOWN"
+)
+
+// GatewayChangeType - What happened to a monitored gateway.
+type GatewayChangeType int
+
+const (
+	GATEWAY_ADDED GatewayChangeType = iota
+	GATEWAY_UPDATED
+	GATEWAY_REMOVED
+)
+
+// GatewayChange - A change of the state, the ping status or the availability of a gateway, Event is nil when the
+// change comes from a poll.
+type GatewayChange struct {
+	Type    GatewayChangeType
+	Gateway *GatewayHealth
+	// Previous - the gateway before the change, nil for GATEWAY_ADDED
+	Previous *GatewayHealth
+	Event    *EslEvent
+}
+
+// WentDown - Convenience method.
+//   - @return true if the gateway was up before the change and is not anymore
+func (c *GatewayChange) WentDown() bool {
+	return c.Previous != nil && c.Previous.Up && (!c.Gateway.Up || c.Type == GATEWAY_REMOVED)
+}
+
+// WentUp - Convenience method.
+//   - @return true if the gateway is up after the change and was not before
+func (c *GatewayChange) WentUp() bool {
+	return c.Type != GATEWAY_REMOVED && c.Gateway.Up && (c.Previous == nil || !c.Previous.Up)
+}
+
+// GatewayHealth - The latest known state of a sofia gateway.
+type GatewayHealth struct {
+	Name    string
+	Profile string
+	// State - the registration state, one of GATEWAY_STATE_*
+	State string
+	// Status - the ping status, GATEWAY_STATUS_UP or GATEWAY_STATUS_DOWN, FreeSWITCH reports UP for the gateways which
+	// are not pinged
+	Status string
+	// Up - whether calls can be sent to the gateway, see GatewayIsUp
+	Up bool
+	// PingTime - the round trip of the last OPTIONS ping
+	PingTime time.Duration
+	// LastPing - when the gateway was last pinged
+	LastPing time.Time
+	// FailureStatus, FailurePhrase - the SIP response of the last failed registration
+	FailureStatus string
+	FailurePhrase string
+	// ChangedAt - when State, Status or Up last changed
+	ChangedAt time.Time
+	UpdatedAt time.Time
+}
+
+// GatewayIsUp - A gateway is up when it is registered, or does not register, and its pings are not failing.
+//   - A gateway in FAIL_WAIT or UNREGED is down even when sofia still reports its ping status UP.
+//   - REGISTER and TRYING are down here, GatewayMonitor keeps an up gateway up while it refreshes its registration.
+func GatewayIsUp(state, status string) bool {
+	if status == GATEWAY_STATUS_DOWN {
+		return false
+	}
+	return state == GATEWAY_STATE_REGED || state == GATEWAY_STATE_NOREG
+}
+
+// gatewayRegistering - Whether the state is a registration in progress, a registered gateway goes through it on
+// every refresh.
+func gatewayRegistering(state string) bool {
+	return state == GATEWAY_STATE_REGISTER || state == GATEWAY_STATE_TRYING
+}
+
+func (g *GatewayHealth) clone() *GatewayHealth {
+	clone := *g
+	return &clone
+}
+
+// GatewayMonitor - Maintains the health of the sofia gateways from the sofia::gateway_state events and a periodic
+// "sofia xmlstatus gateway" poll.
+//   - Call Start to poll periodically.
+type GatewayMonitor struct {
+	listenerBase
+	client    *Client
+	interval  time.Duration
+	mtx       sync.RWMutex
+	gateways  map[string]*GatewayHealth
+	callbacks []func(change *GatewayChange)
+	stop      chan struct{}
+	// pollMtx - serializes the polls
+	pollMtx sync.Mutex
+}
+
+// NewGatewayMonitor - Constructor, registers the monitor as event and connection listener of client.
+//   - @param interval the period of the polls started by Start, 0 means 30 seconds
+func NewGatewayMonitor(client *Client, interval time.Duration) *GatewayMonitor {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	m := &GatewayMonitor{client: client, interval: interval, gateways: make(map[string]*GatewayHealth)}
+	client.AddEventListener(m)
+	client.AddConnectionListener(m)
+	return m
+}
+
+// Start - Poll the gateways every interval until Stop is called.
+func (m *GatewayMonitor) Start() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if m.stop != nil {
+		return
+	}
+	m.stop = make(chan struct{})
+	go m.run(m.stop)
+}
+
+// Stop - Stop the periodic poll.
+func (m *GatewayMonitor) Stop() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if m.stop != nil {
+		close(m.stop)
+		m.stop = nil
+	}
+}
+
+func (m *GatewayMonitor) run(stop chan struct{}) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := m.Poll(); err != nil {
+				logger.Warnf("Gateway poll failure, cause %v\n", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Get - Lookup a gateway by name.
+//   - @return a copy of the gateway, false if it is unknown
+func (m *GatewayMonitor) Get(name string) (*GatewayHealth, bool) {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	gateway, ok := m.gateways[name]
+	if !ok {
+		return nil, false
+	}
+	return gateway.clone(), true
+}
+
+// List - A copy of every known gateway.
+func (m *GatewayMonitor) List() []*GatewayHealth {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	gateways := make([]*GatewayHealth, 0, len(m.gateways))
+	for _, gateway := range m.gateways {
+		gateways = append(gateways, gateway.clone())
+	}
+	return gateways
+}
+
+// IsUp - Convenience method.
+//   - @return true if the gateway is known and up
+func (m *GatewayMonitor) IsUp(name string) bool {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	gateway, ok := m.gateways[name]
+	return ok && gateway.Up
+}
+
+// Available - Filter the gateways which are up, in the given order, for example to build a failover dial string.
+func (m *GatewayMonitor) Available(names ...string) []string {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	var available []string
+	for _, name := range names {
+		if gateway, ok := m.gateways[name]; ok && gateway.Up {
+			available = append(available, name)
+		}
+	}
+	return available
+}
+
+// OnChange - Register a callback, it is called from the event dispatch goroutine or from the goroutine polling.
+func (m *GatewayMonitor) OnChange(callback func(change *GatewayChange)) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.callbacks = append(m.callbacks, callback)
+}
+
+// Sync - Subscribe to GATEWAY_MONITOR_EVENTS and poll the gateways.
+func (m *GatewayMonitor) Sync() error {
+	if err := subscribe(m.client, GATEWAY_MONITOR_EVENTS, "Gateway"); err != nil {
+		return err
+	}
+	return m.Poll()
+}
+
+// xmlGateway - A <gateway> of "sofia xmlstatus gateway".
+type xmlGateway struct {
+	Name     string `xml:"name"`
+	Profile  string `xml:"profile"`
+	State    string `xml:"state"`
+	Status   string `xml:"status"`
+	Ping     string `xml:"ping"`
+	PingTime string `xml:"pingtime"`
+}
+
+// Poll - Read every gateway with "sofia xmlstatus gateway", the gateways missing from the answer are removed.
+func (m *GatewayMonitor) Poll() error {
+	m.pollMtx.Lock()
+	defer m.pollMtx.Unlock()
+	body, err := m.client.SendApi("sofia", "xmlstatus gateway")
+	if err != nil {
+		return err
+	}
+	var status struct {
+		Gateways []xmlGateway `xml:"gateway"`
+	}
+	decoder := xml.NewDecoder(strings.NewReader(body))
+	// the answer declares ISO-8859-1, gateway names and states are ASCII
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	if err := decoder.Decode(&status); err != nil {
+		return err
+	}
+	var changes []*GatewayChange
+	now := time.Now()
+	m.mtx.Lock()
+	live := make(map[string]bool, len(status.Gateways))
+	for _, row := range status.Gateways {
+		if row.Name == "" {
+			continue
+		}
+		live[row.Name] = true
+		gateway, previous := m.gateway(row.Name)
+		setIfPresent(&gateway.Profile, row.Profile)
+		gateway.State = row.State
+		gateway.Status = row.Status
+		if ping, err := strconv.ParseInt(row.Ping, 10, 64); err == nil && ping > 0 {
+			gateway.LastPing = time.Unix(ping, 0)
+		}
+		if pingTime, err := strconv.ParseFloat(row.PingTime, 64); err == nil {
+			gateway.PingTime = time.Duration(pingTime * float64(time.Millisecond))
+		}
+		if change := m.update(gateway, previous, nil, now); change != nil {
+			changes = append(changes, change)
+		}
+	}
+	for name, gateway := range m.gateways {
+		if !live[name] {
+			delete(m.gateways, name)
+			changes = append(changes, &GatewayChange{Type: GATEWAY_REMOVED, Gateway: gateway.clone(), Previous: gateway.clone()})
+		}
+	}
+	callbacks := m.callbacks
+	m.mtx.Unlock()
+	runGatewayCallbacks(callbacks, changes)
+	return nil
+}
+
+// gateway - The gateway to update and a copy of it before the update, nil when it is new.
+func (m *GatewayMonitor) gateway(name string) (*GatewayHealth, *GatewayHealth) {
+	gateway, ok := m.gateways[name]
+	if !ok {
+		gateway = &GatewayHealth{Name: name}
+		m.gateways[name] = gateway
+		return gateway, nil
+	}
+	return gateway, gateway.clone()
+}
+
+// update - Recompute Up and build the change, nil when neither the state, the status nor the availability changed.
+func (m *GatewayMonitor) update(gateway, previous *GatewayHealth, event *EslEvent, now time.Time) *GatewayChange {
+	gateway.Up = GatewayIsUp(gateway.State, gateway.Status)
+	if !gateway.Up && previous != nil && previous.Up && gatewayRegistering(gateway.State) &&
+		gateway.Status != GATEWAY_STATUS_DOWN {
+		// refreshing the registration
+		gateway.Up = true
+	}
+	gateway.UpdatedAt = now
+	if previous == nil {
+		gateway.ChangedAt = now
+		return &GatewayChange{Type: GATEWAY_ADDED, Gateway: gateway.clone(), Event: event}
+	}
+	if previous.State == gateway.State && previous.Status == gateway.Status && previous.Up == gateway.Up {
+		return nil
+	}
+	gateway.ChangedAt = now
+	if isInfoEnabled() {
+		logger.Infof("Gateway %s changed from %s/%s to %s/%s\n", gateway.Name, previous.State, previous.Status,
+			gateway.State, gateway.Status)
+	}
+	return &GatewayChange{Type: GATEWAY_UPDATED, Gateway: gateway.clone(), Previous: previous, Event: event}
+}
+
+// EventReceived - Implements IEslEventListener.
+func (m *GatewayMonitor) EventReceived(event *EslEvent) error {
+	headers := *event.GetEventHeaders()
+	subclass := headers["Event-Subclass"]
+	name := headers["Gateway"]
+	if event.GetEventName() != "CUSTOM" || !strings.HasPrefix(subclass, "sofia::gateway_") || name == "" {
+		return nil
+	}
+	now := time.Now()
+	var changes []*GatewayChange
+	m.mtx.Lock()
+	switch subclass {
+	case "sofia::gateway_state":
+		gateway, previous := m.gateway(name)
+		setIfPresent(&gateway.Profile, headers["Profile-Name"])
+		setIfPresent(&gateway.State, headers["State"])
+		if status := headers["Ping-Status"]; status == GATEWAY_STATUS_UP || status == GATEWAY_STATUS_DOWN {
+			gateway.Status = status
+		}
+		if headers["Status"] != "" {
+			gateway.FailureStatus, gateway.FailurePhrase = headers["Status"], headers["Phrase"]
+		}
+		if change := m.update(gateway, previous, event, now); change != nil {
+			changes = append(changes, change)
+		}
+	case "sofia::gateway_add":
+		if _, ok := m.gateways[name]; !ok {
+			gateway, _ := m.gateway(name)
+			setIfPresent(&gateway.Profile, headers["profile-name"])
+			changes = append(changes, m.update(gateway, nil, event, now))
+		}
+	case "sofia::gateway_delete":
+		if gateway, ok := m.gateways[name]; ok {
+			delete(m.gateways, name)
+			changes = append(changes, &GatewayChange{Type: GATEWAY_REMOVED, Gateway: gateway.clone(),
+				Previous: gateway.clone(), Event: event})
+		}
+	}
+	callbacks := m.callbacks
+	m.mtx.Unlock()
+	runGatewayCallbacks(callbacks, changes)
+	return nil
+}
+
+func runGatewayCallbacks(callbacks []func(change *GatewayChange), changes []*GatewayChange) {
+	for _, change := range changes {
+		for _, callback := range callbacks {
+			callback(change)
+		}
+	}
+}
+
+// Authenticated - Implements IEslConnectionListener, polls the gateways that changed while disconnected.
+func (m *GatewayMonitor) Authenticated(authenticated bool, c *Client) {
+	if !authenticated {
+		return
+	}
+	if err := m.Sync(); err != nil {
+		logger.Errorf("Gateway monitor synchronization failure, cause %v\n", err)
+	}
+}
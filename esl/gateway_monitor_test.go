@@ -0,0 +1,191 @@
+package esl_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zhouhailin/freeswitch-esl-go/esl"
+	"github.com/zhouhailin/freeswitch-esl-go/esl/esltest"
+)
+
+// xmlGateway - A <gateway> of "sofia xmlstatus gateway" as FreeSWITCH prints it, fields the monitor ignores included.
+func xmlGateway(name, state, status string) string {
+	return `  <gateway>
+    <name>` + name + `</name>
+    <profile>external</profile>
+    <scheme>Digest</scheme>
+    <realm>203.0.113.1</realm>
+    <username>alice</username>
+    <password>yes</password>
+    <from>&lt;sip:alice@203.0.113.1&gt;</from>
+    <contact>&lt;sip:gw+` + name + `@198.51.100.7:5080;transport=udp;gw=` + name + `&gt;</contact>
+    <exten>alice</exten>
+    <to>sip:alice@203.0.113.1</to>
+    <proxy>sip:203.0.113.1</proxy>
+    <context>public</context>
+    <expires>3600</expires>
+    <freq>3600</freq>
+    <ping>1700000000</ping>
+    <pingfreq>30</pingfreq>
+    <pingmin>1</pingmin>
+    <pingcount>1</pingcount>
+    <pingmax>3</pingmax>
+    <pingtime>12.50</pingtime>
+    <pinging>0</pinging>
+    <state>` + state + `</state>
+    <status>` + status + `</status>
+    <uptime-usec>3600000000</uptime-usec>
+    <calls-in>0</calls-in>
+    <calls-out>0</calls-out>
+    <failed-calls-in>0</failed-calls-in>
+    <failed-calls-out>0</failed-calls-out>
+  </gateway>
+`
+}
+
+func xmlGateways(gateways ...string) string {
+	return `<?xml version="1.0" encoding="ISO-8859-1"?>
+<gateways>
+` + strings.Join(gateways, "") + `</gateways>
+`
+}
+
+func gatewayState(name, state, pingStatus string) *esltest.Event {
+	return esltest.NewCustomEvent("sofia::gateway_state").Set("Gateway", name).Set("Profile-Name", "external").
+		Set("State", state).Set("Ping-Status", pingStatus)
+}
+
+func TestGatewayIsUp(t *testing.T) {
+	for _, c := range []struct {
+		state, status string
+		up            bool
+	}{
+		{esl.GATEWAY_STATE_REGED, esl.GATEWAY_STATUS_UP, true},
+		{esl.GATEWAY_STATE_NOREG, esl.GATEWAY_STATUS_UP, true},
+		{esl.GATEWAY_STATE_REGED, esl.GATEWAY_STATUS_DOWN, false},
+		{esl.GATEWAY_STATE_NOREG, esl.GATEWAY_STATUS_DOWN, false},
+		{esl.GATEWAY_STATE_FAIL_WAIT, esl.GATEWAY_STATUS_UP, false},
+		{esl.GATEWAY_STATE_UNREGED, esl.GATEWAY_STATUS_UP, false},
+		{esl.GATEWAY_STATE_FAILED, esl.GATEWAY_STATUS_UP, false},
+		{esl.GATEWAY_STATE_EXPIRED, esl.GATEWAY_STATUS_UP, false},
+		{esl.GATEWAY_STATE_TRYING, esl.GATEWAY_STATUS_UP, false},
+	} {
+		if up := esl.GatewayIsUp(c.state, c.status); up != c.up {
+			t.Errorf("%s/%s up %v", c.state, c.status, up)
+		}
+	}
+}
+
+func TestGatewayMonitor(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	var mtx sync.Mutex
+	answer := xmlGateways(xmlGateway("gw1", "REGED", "UP"), xmlGateway("gw2", "NOREG", "UP"),
+		xmlGateway("gw3", "FAIL_WAIT", "UP"), xmlGateway("gw4", "NOREG", "DOWN"))
+	server.HandleApi("sofia", func(args string) string {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return answer
+	})
+	monitor := esl.NewGatewayMonitor(client, time.Hour)
+	changes := make(chan *esl.GatewayChange, 16)
+	monitor.OnChange(func(change *esl.GatewayChange) {
+		changes <- change
+	})
+	next := func() *esl.GatewayChange {
+		t.Helper()
+		select {
+		case change := <-changes:
+			return change
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for a gateway change")
+			return nil
+		}
+	}
+	conn := connect(t, server, client)
+	waitCommand(t, server, "event plain "+esl.GATEWAY_MONITOR_EVENTS)
+
+	added := make(map[string]*esl.GatewayChange)
+	for i := 0; i < 4; i++ {
+		change := next()
+		if change.Type != esl.GATEWAY_ADDED || change.Event != nil {
+			t.Fatalf("change %+v", change)
+		}
+		added[change.Gateway.Name] = change
+	}
+	for name, up := range map[string]bool{"gw1": true, "gw2": true, "gw3": false, "gw4": false} {
+		change := added[name]
+		if change.Gateway.Up != up || change.WentUp() != up || change.WentDown() || monitor.IsUp(name) != up {
+			t.Errorf("%s up %v went up %v", name, change.Gateway.Up, change.WentUp())
+		}
+	}
+	if gw1 := added["gw1"].Gateway; gw1.Profile != "external" || gw1.PingTime != 12500*time.Microsecond ||
+		!gw1.LastPing.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("gw1 %+v", gw1)
+	}
+	if available := monitor.Available("gw4", "gw3", "gw2", "gw1"); strings.Join(available, ",") != "gw2,gw1" {
+		t.Errorf("available %v", available)
+	}
+
+	// refreshing the registration keeps the gateway up
+	conn.SendEvent(gatewayState("gw1", "REGISTER", "UP"))
+	if change := next(); change.Type != esl.GATEWAY_UPDATED || !change.Gateway.Up || change.WentDown() ||
+		change.WentUp() || change.Event == nil {
+		t.Errorf("refresh %+v", change.Gateway)
+	}
+	conn.SendEvent(gatewayState("gw1", "FAIL_WAIT", "UP").Set("Status", "503").Set("Phrase", "Service Unavailable"))
+	if change := next(); !change.WentDown() || change.Gateway.State != esl.GATEWAY_STATE_FAIL_WAIT ||
+		change.Gateway.FailureStatus != "503" || change.Gateway.FailurePhrase != "Service Unavailable" {
+		t.Errorf("failure %+v", change.Gateway)
+	}
+	// a failed gateway trying again stays down
+	conn.SendEvent(gatewayState("gw1", "TRYING", "UP"))
+	if change := next(); change.Gateway.Up || change.WentUp() || change.WentDown() {
+		t.Errorf("retry %+v", change.Gateway)
+	}
+	conn.SendEvent(gatewayState("gw1", "REGED", "UP"))
+	if change := next(); !change.WentUp() || change.Previous.State != esl.GATEWAY_STATE_TRYING {
+		t.Errorf("registered %+v", change.Gateway)
+	}
+	// failing pings take a registered gateway down
+	conn.SendEvent(gatewayState("gw1", "REGED", "DOWN"))
+	if change := next(); !change.WentDown() || change.Gateway.Status != esl.GATEWAY_STATUS_DOWN {
+		t.Errorf("ping failure %+v", change.Gateway)
+	}
+	conn.SendEvent(gatewayState("gw1", "REGED", "UP"))
+	if change := next(); !change.WentUp() {
+		t.Errorf("ping recovered %+v", change.Gateway)
+	}
+	// an event repeating the known state is not a change
+	conn.SendEvent(gatewayState("gw1", "REGED", "UP"))
+	conn.SendEvent(esltest.NewCustomEvent("sofia::gateway_delete").Set("Gateway", "gw2"))
+	if change := next(); change.Type != esl.GATEWAY_REMOVED || change.Gateway.Name != "gw2" || !change.WentDown() {
+		t.Errorf("delete %+v", change)
+	}
+
+	// the gateways missing from the next poll are removed
+	mtx.Lock()
+	answer = xmlGateways(xmlGateway("gw3", "REGED", "UP"))
+	mtx.Unlock()
+	if err := monitor.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	polled := make(map[string]*esl.GatewayChange)
+	for i := 0; i < 3; i++ {
+		change := next()
+		polled[change.Gateway.Name] = change
+	}
+	if change := polled["gw3"]; change.Type != esl.GATEWAY_UPDATED || !change.WentUp() || change.Event != nil {
+		t.Errorf("gw3 %+v", change)
+	}
+	if change := polled["gw1"]; change.Type != esl.GATEWAY_REMOVED || !change.WentDown() || change.WentUp() {
+		t.Errorf("gw1 %+v", change)
+	}
+	if change := polled["gw4"]; change.Type != esl.GATEWAY_REMOVED || change.WentDown() {
+		t.Errorf("gw4 %+v", change)
+	}
+	if _, ok := monitor.Get("gw1"); ok || len(monitor.List()) != 1 {
+		t.Errorf("gateways %+v", monitor.List())
+	}
+}
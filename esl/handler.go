@@ -1,13 +1,17 @@
 package esl
 
 import (
+	"context"
 	"errors"
 	"github.com/bytedance/gopkg/util/logger"
 	"strings"
+	"time"
 )
 
 func messageReceived(c *Client, m *EslMessage) error {
+	c.touch()
 	contentType := m.GetContentType()
+	c.instruments.getMetrics().MessageReceived(contentType)
 	if contentType == TEXT_EVENT_PLAIN || contentType == TEXT_EVENT_XML {
 		event, err := NewEslEvent(m, true)
 		if err != nil {
@@ -33,18 +37,7 @@ func (socket *SocketConnection) sendSyncSingleLineCommand(command string) (*EslM
 	if isTraceEnabled() {
 		logger.Tracef("sendSyncSingleLineCommand command : %s\n", command)
 	}
-	socket.mtx.Lock()
-	defer socket.mtx.Unlock()
-	_, err := socket.Writer().WriteString(command + MESSAGE_TERMINATOR)
-	if err != nil {
-		return nil, err
-	}
-	err = socket.Writer().Flush()
-	if err != nil {
-		return nil, err
-	}
-	// Block until the response is available
-	return <-socket.msg, nil
+	return socket.sendCommand(&Command{Lines: []string{command}})
 }
 
 // sendSyncMultiLineCommand - Synthesise a synchronous command/response by creating a callback object which is placed in
@@ -55,15 +48,51 @@ func (socket *SocketConnection) sendSyncSingleLineCommand(command string) (*EslM
 // - @param command List of command lines to send
 // - @return the {@link EslMessage} attached to this command's callback
 func (socket *SocketConnection) sendSyncMultiLineCommand(commandLines *[]string) (*EslMessage, error) {
+	return socket.sendSyncCommandWithBody(commandLines, "")
+}
+
+// sendSyncCommandWithBody - sendSyncMultiLineCommand followed by a body, the lines must hold its content-length.
+func (socket *SocketConnection) sendSyncCommandWithBody(commandLines *[]string, body string) (*EslMessage, error) {
+	lines := append([]string{}, *commandLines...)
+	return socket.sendCommand(&Command{Lines: lines, Body: body})
+}
+
+// sendCommand - Send a command through the command interceptors.
+func (socket *SocketConnection) sendCommand(command *Command) (*EslMessage, error) {
+	return socket.interceptors.commandHandler(socket.writeCommand)(command)
+}
+
+// writeCommand - The end of the command interceptor chain, writes the lines and the body of the command.
+func (socket *SocketConnection) writeCommand(command *Command) (*EslMessage, error) {
 	var sb strings.Builder
-	for _, line := range *commandLines {
+	for _, line := range command.Lines {
 		sb.WriteString(line)
 		sb.WriteString(LINE_TERMINATOR)
 	}
 	sb.WriteString(LINE_TERMINATOR)
+	sb.WriteString(command.Body)
+	return socket.sendSync(command.Context, command.Name(), sb.String())
+}
+
+// sendSync - Write a whole command and block until its reply, the commands are serialized.
+func (socket *SocketConnection) sendSync(ctx context.Context, name, payload string) (message *EslMessage, err error) {
+	metrics := socket.instruments.getMetrics()
+	metrics.CommandSent(name)
+	start := time.Now()
+	finishTrace := socket.instruments.traceCommand(ctx, name, payload)
+	defer func() {
+		metrics.CommandCompleted(name, time.Since(start), err)
+		if finishTrace != nil {
+			finishTrace(message, err)
+		}
+	}()
 	socket.mtx.Lock()
 	defer socket.mtx.Unlock()
-	_, err := socket.Writer().WriteString(sb.String())
+	// a command queued behind an exit finds the connection closed
+	if !socket.IsActive() {
+		return nil, errors.New("connection closed before the command was sent")
+	}
+	_, err = socket.Writer().WriteString(payload)
 	if err != nil {
 		return nil, err
 	}
@@ -72,7 +101,16 @@ func (socket *SocketConnection) sendSyncMultiLineCommand(commandLines *[]string)
 		return nil, err
 	}
 	// Block until the response is available
-	return <-socket.msg, nil
+	return socket.awaitReply()
+}
+
+// awaitReply - Block until the reply of the command is available, the channel is closed with the connection.
+func (socket *SocketConnection) awaitReply() (*EslMessage, error) {
+	m, ok := <-socket.msg
+	if !ok || m == nil {
+		return nil, errors.New("connection closed while waiting for the reply")
+	}
+	return m, nil
 }
 
 // sendAsyncCommand - Returns the Job UUID of that the response event will have.
@@ -145,7 +183,8 @@ func handleAuthRequest(c *Client) error {
 	if isDebugEnabled() {
 		logger.Debugf("Auth requested, sending [auth %s]\n", "*****")
 	}
-	response, err := c.sendSyncSingleLineCommand("auth " + c.Password)
+	// the password is kept away from the interceptors and the command policy
+	response, err := c.writeCommand(&Command{Lines: []string{"auth " + c.Password}})
 	if err != nil {
 		return err
 	}
@@ -174,5 +213,6 @@ func handleRudeRejection(c *Client) error {
 		logger.Debugf("Received rude rejection")
 	}
 	c.rudeRejection = true
+	c.authenticationDone()
 	return nil
 }
@@ -0,0 +1,105 @@
+package esl_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bytedance/gopkg/util/logger"
+	"github.com/zhouhailin/freeswitch-esl-go/esl"
+	"github.com/zhouhailin/freeswitch-esl-go/esl/esltest"
+)
+
+const testPassword = "ClueCon"
+
+// newTestClient - A fake switch and a client of it, both closed at the end of the test.
+func newTestClient(t *testing.T, options *esl.Options) (*esltest.Server, *esl.Client) {
+	t.Helper()
+	server := esltest.NewServer(testPassword)
+	t.Cleanup(server.Close)
+	if options == nil {
+		options = &esl.Options{}
+	}
+	options.Level = logger.LevelWarn
+	client := esl.NewClient(server.Host(), server.Port(), testPassword, 5, options)
+	t.Cleanup(func() { _ = client.Shutdown() })
+	return server, client
+}
+
+// connect - Connect the client and wait for the fake switch to accept it.
+func connect(t *testing.T, server *esltest.Server, client *esl.Client) *esltest.Conn {
+	t.Helper()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	conn, err := server.WaitConn(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// waitCommand - Wait for the fake switch to receive a command starting with prefix.
+func waitCommand(t *testing.T, server *esltest.Server, prefix string) *esltest.Command {
+	t.Helper()
+	cmd, err := server.WaitCommand(prefix, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cmd
+}
+
+// eventually - Wait for condition to hold.
+func eventually(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// recorder - An event listener keeping the events it receives.
+type recorder struct {
+	mtx    sync.Mutex
+	events []*esl.EslEvent
+}
+
+func (r *recorder) EventReceived(event *esl.EslEvent) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *recorder) BackgroundJobResultReceived(event *esl.EslEvent) error {
+	return r.EventReceived(event)
+}
+
+func (r *recorder) names() []string {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	names := make([]string, 0, len(r.events))
+	for _, event := range r.events {
+		names = append(names, event.GetEventName())
+	}
+	return names
+}
+
+// waitSendMsg - Wait for the fake switch to receive a sendmsg with the call-command for the channel.
+func waitSendMsg(t *testing.T, server *esltest.Server, uuid, callCommand string) *esltest.Command {
+	t.Helper()
+	var found *esltest.Command
+	eventually(t, "sendmsg "+uuid+" "+callCommand, func() bool {
+		for _, cmd := range server.Commands() {
+			if cmd.Line == "sendmsg "+uuid && cmd.Headers["call-command"] == callCommand {
+				found = cmd
+				return true
+			}
+		}
+		return false
+	})
+	return found
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/cloudwego/netpoll"
 	"net"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -17,8 +19,36 @@ type Client struct {
 	Password            string
 	TimeoutSeconds      int
 	reconnectAttempts   int
+	listenerMtx         sync.RWMutex
 	eventListeners      []IEslEventListener
 	connectionListeners []IEslConnectionListener
+	events              chan *EslEvent
+	jobs                chan *EslEvent
+	jobMtx              sync.Mutex
+	jobWaiters          map[string]chan *EslEvent
+	executions          executions
+	dtmfMtx             sync.Mutex
+	dtmfWatchers        map[*dtmfWatcher]bool
+	coreMtx             sync.Mutex
+	coreUuid            string
+	coreShutdown        bool
+	restartCallbacks    []func(restart *SwitchRestart)
+	pendingRestarts     []*SwitchRestart
+	notifyingRestarts   bool
+	// lastActivity - the UnixNano time of the last message received, accessed atomically
+	lastActivity int64
+	interceptors interceptors
+	// instruments - from the options given to NewClient, they outlive the reconnections
+	instruments *instruments
+	// dropEvents - drop the events arriving while their queue is full instead of waiting for the listeners
+	dropEvents bool
+	// done - closed by Shutdown, it stops the reconnections and the goroutines dispatching the events
+	done         chan struct{}
+	shutdownOnce sync.Once
+	// dispatching - the goroutines dispatching the events, Shutdown waits for them
+	dispatching sync.WaitGroup
+	// connectMtx - serializes Connect, a reconnection waits for the Connect in progress
+	connectMtx sync.Mutex
 }
 
 type Options struct {
@@ -26,13 +56,37 @@ type Options struct {
 	ReconnectIntervalSeconds int
 	MaxReconnectAttempts     int
 	Level                    logger.Level
+	// EventQueueSize - events waiting for the listeners, 0 means 1024, once full the connection waits for the listeners
+	EventQueueSize int
+	// DropEventsWhenFull - drop the events arriving while the queue is full instead of waiting for the listeners, the
+	// drops are logged and counted by Metrics.EventDropped
+	DropEventsWhenFull bool
+	// Metrics - receives the measures of the client, nil discards them
+	Metrics Metrics
+	// Tracer - receives the spans of the commands and of the listener notifications of the client, nil disables the
+	// tracing
+	Tracer Tracer
+	// TraceRedactor - redacts the arguments of the traced commands, RedactCommandArgs when nil
+	TraceRedactor func(command, args string) string
 }
 
-var options = Options{
-	AutoReconnection:         true,
-	ReconnectIntervalSeconds: 5,
-	MaxReconnectAttempts:     0,
-	Level:                    logger.LevelInfo,
+var (
+	// optionsMtx - guards options, NewClient replaces them while the connections of other clients read them
+	optionsMtx sync.RWMutex
+	options    = Options{
+		AutoReconnection:         true,
+		ReconnectIntervalSeconds: 5,
+		MaxReconnectAttempts:     0,
+		Level:                    logger.LevelInfo,
+		EventQueueSize:           1024,
+	}
+)
+
+// getOptions - The options of the package, the last ones given to NewClient.
+func getOptions() Options {
+	optionsMtx.RLock()
+	defer optionsMtx.RUnlock()
+	return options
 }
 
 type ProtocolListener struct {
@@ -40,11 +94,14 @@ type ProtocolListener struct {
 
 func (l ProtocolListener) authResponseReceived(c *Client, response *CommandResponse) {
 	c.authenticatorResponded = true
+	c.connMtx.Lock()
 	c.authenticated = response.IsOk()
+	c.connMtx.Unlock()
 	c.authenticationResponse = response
 	if isDebugEnabled() {
 		logger.Debug("Auth response success=" + strconv.FormatBool(c.authenticated) + ", message=[" + response.GetReplyText() + "]")
 	}
+	c.authenticationDone()
 }
 
 func (l ProtocolListener) eventReceived(c *Client, event *EslEvent) {
@@ -52,7 +109,17 @@ func (l ProtocolListener) eventReceived(c *Client, event *EslEvent) {
 	if isDebugEnabled() {
 		logger.Debugf("Event received %s\n", event.ToString())
 	}
-	if c.eventListeners == nil || len(c.eventListeners) == 0 {
+	c.instruments.getMetrics().EventReceived(event.GetEventName())
+	c.coreEventReceived(event)
+	switch event.GetEventName() {
+	case "BACKGROUND_JOB":
+		c.jobCompleted(event)
+	case "CHANNEL_EXECUTE_COMPLETE", "CHANNEL_HANGUP":
+		c.executions.eventReceived(event)
+	case "DTMF":
+		c.dtmfReceived(event)
+	}
+	if len(c.getEventListeners()) == 0 {
 		return
 	}
 
@@ -60,26 +127,29 @@ func (l ProtocolListener) eventReceived(c *Client, event *EslEvent) {
 	 *  Notify listeners in a different thread in order to:
 	 *    - not to block the IO threads with potentially long-running listeners
 	 *    - generally be defensive running other people's code
-	 *  Use a different worker thread pool for async job results than for event driven
-	 *  events to keep the latency as low as possible.
+	 *  Use a different worker queue for async job results than for event driven
+	 *  events to keep the latency as low as possible. Each queue is consumed by a
+	 *  single goroutine so listeners see the events in the order they were received.
 	 */
-	go func() {
-		if event.GetEventName() == "BACKGROUND_JOB" {
-			for i, listener := range c.eventListeners {
-				err := listener.BackgroundJobResultReceived(event)
-				if err != nil {
-					logger.Errorf("%d Error caught notifying listener of job result %s\n", i, event.ToString(), err)
-				}
-			}
-		} else {
-			for i, listener := range c.eventListeners {
-				err := listener.EventReceived(event)
-				if err != nil {
-					logger.Errorf("%d Error caught notifying listener of event %s\n", i, event.ToString(), err)
-				}
-			}
+	queue, queueName := c.events, "events"
+	if event.GetEventName() == "BACKGROUND_JOB" {
+		queue, queueName = c.jobs, "jobs"
+	}
+	if c.dropEvents {
+		select {
+		case queue <- event:
+			c.instruments.getMetrics().EventQueueDepth(queueName, len(queue))
+		default:
+			c.instruments.getMetrics().EventDropped(event.GetEventName())
+			logger.Warnf("Event queue is full, dropping %s\n", event.ToString())
 		}
-	}()
+		return
+	}
+	select {
+	case queue <- event:
+		c.instruments.getMetrics().EventQueueDepth(queueName, len(queue))
+	case <-c.done:
+	}
 }
 
 func (l ProtocolListener) disconnected(c *Client) {
@@ -91,10 +161,17 @@ func (l ProtocolListener) disconnected(c *Client) {
 // NewClient - Will initiate new client that will establish connection and attempt to authenticate
 // @Param host
 func NewClient(host string, port uint, password string, timeoutSeconds int, newOptions *Options) *Client {
+	optionsMtx.Lock()
 	if newOptions != nil {
 		options = *newOptions
 	}
-	return &Client{
+	clientOptions := options
+	optionsMtx.Unlock()
+	queueSize := clientOptions.EventQueueSize
+	if queueSize <= 0 {
+		queueSize = 1024
+	}
+	client := &Client{
 		Network:             "tcp",
 		Address:             net.JoinHostPort(host, strconv.Itoa(int(port))),
 		Password:            password,
@@ -102,24 +179,84 @@ func NewClient(host string, port uint, password string, timeoutSeconds int, newO
 		reconnectAttempts:   0,
 		eventListeners:      nil,
 		connectionListeners: nil,
+		events:              make(chan *EslEvent, queueSize),
+		jobs:                make(chan *EslEvent, queueSize),
+		dropEvents:          clientOptions.DropEventsWhenFull,
+		done:                make(chan struct{}),
+		instruments:         newInstruments(clientOptions.Metrics, clientOptions.Tracer, clientOptions.TraceRedactor),
 	}
+	client.dispatching.Add(2)
+	go client.dispatchEvents(client.events)
+	go client.dispatchEvents(client.jobs)
+	return client
 }
 
 func (client *Client) AddEventListener(listener IEslEventListener) {
-	if client.eventListeners == nil {
-		client.eventListeners = *new([]IEslEventListener)
-	}
+	client.listenerMtx.Lock()
+	defer client.listenerMtx.Unlock()
 	client.eventListeners = append(client.eventListeners, listener)
 }
 
 func (client *Client) AddConnectionListener(listener IEslConnectionListener) {
-	if client.connectionListeners == nil {
-		client.connectionListeners = *new([]IEslConnectionListener)
-	}
+	client.listenerMtx.Lock()
+	defer client.listenerMtx.Unlock()
 	client.connectionListeners = append(client.connectionListeners, listener)
 }
 
+func (client *Client) getEventListeners() []IEslEventListener {
+	client.listenerMtx.RLock()
+	defer client.listenerMtx.RUnlock()
+	return client.eventListeners
+}
+
+func (client *Client) getConnectionListeners() []IEslConnectionListener {
+	client.listenerMtx.RLock()
+	defer client.listenerMtx.RUnlock()
+	return client.connectionListeners
+}
+
+// dispatchEvents - Notify the event listeners of each queued event, one event at a time, until Shutdown.
+//   - The events already queued at Shutdown are notified before it returns.
+func (client *Client) dispatchEvents(queue chan *EslEvent) {
+	defer client.dispatching.Done()
+	queueName := "events"
+	if queue == client.jobs {
+		queueName = "jobs"
+	}
+	for {
+		select {
+		case event := <-queue:
+			client.dispatchEvent(queueName, queue, event)
+		case <-client.done:
+			for {
+				select {
+				case event := <-queue:
+					client.dispatchEvent(queueName, queue, event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (client *Client) dispatchEvent(queueName string, queue chan *EslEvent, event *EslEvent) {
+	client.instruments.getMetrics().EventQueueDepth(queueName, len(queue))
+	client.interceptors.eventHandler(client.notifyListeners)(event)
+}
+
+// notifyListeners - The end of the event interceptor chain, the job results go to BackgroundJobResultReceived.
+func (client *Client) notifyListeners(event *EslEvent) {
+	for i, listener := range client.getEventListeners() {
+		if err := client.instruments.notifyListener(listener, event); err != nil {
+			logger.Errorf("%d Error caught notifying listener of event %s, cause %v\n", i, event.ToString(), err)
+		}
+	}
+}
+
 func (client *Client) Connect() error {
+	client.connectMtx.Lock()
+	defer client.connectMtx.Unlock()
 	if client.CanSend() {
 		if isInfoEnabled() {
 			logger.Info("Client is connected, will close first.")
@@ -133,9 +270,9 @@ func (client *Client) Connect() error {
 	// use default
 	connection, err := netpoll.DialConnection(client.Network, client.Address, time.Duration(client.TimeoutSeconds)*time.Second)
 	if err != nil {
-		if client.connectionListeners != nil && len(client.connectionListeners) > 0 {
+		if listeners := client.getConnectionListeners(); len(listeners) > 0 {
 			go func() {
-				for _, listener := range client.connectionListeners {
+				for _, listener := range listeners {
 					listener.ConnectFailure(client)
 				}
 			}()
@@ -143,18 +280,26 @@ func (client *Client) Connect() error {
 		client.canReconnect()
 		return err
 	}
-	client.SocketConnection = SocketConnection{
-		Connection:             connection,
-		msg:                    make(chan *EslMessage),
-		authenticationResponse: nil,
-		authenticatorResponded: false,
-		authenticated:          false,
-		rudeRejection:          false,
-		listener:               ProtocolListener{},
-	}
-	if client.connectionListeners != nil && len(client.connectionListeners) > 0 {
+	client.touch()
+	// the fields are reset under the mutex, a command of the previous connection may still hold it
+	socket := &client.SocketConnection
+	socket.mtx.Lock()
+	socket.connMtx.Lock()
+	socket.Connection = connection
+	socket.authenticated = false
+	socket.connMtx.Unlock()
+	socket.msg = make(chan *EslMessage)
+	socket.authenticationResponse = nil
+	socket.authenticatorResponded = false
+	socket.rudeRejection = false
+	socket.responded = make(chan struct{})
+	socket.listener = ProtocolListener{}
+	socket.interceptors = &client.interceptors
+	socket.instruments = client.instruments
+	socket.mtx.Unlock()
+	if listeners := client.getConnectionListeners(); len(listeners) > 0 {
 		go func() {
-			for _, listener := range client.connectionListeners {
+			for _, listener := range listeners {
 				listener.Connected(client)
 			}
 		}()
@@ -184,9 +329,9 @@ func (client *Client) Connect() error {
 		logger.Infof("[%v] connection closed\n", connection.RemoteAddr())
 		close(client.msg)
 		// Notify connection is disconnect
-		if client.connectionListeners != nil && len(client.connectionListeners) > 0 {
+		if listeners := client.getConnectionListeners(); len(listeners) > 0 {
 			go func() {
-				for _, listener := range client.connectionListeners {
+				for _, listener := range listeners {
 					listener.Disconnected(client)
 				}
 			}()
@@ -196,17 +341,22 @@ func (client *Client) Connect() error {
 		return nil
 	})
 
-	for !client.rudeRejection && !client.authenticatorResponded {
-		time.Sleep(250 * time.Millisecond)
-	}
+	<-client.responded
 
-	if client.connectionListeners != nil && len(client.connectionListeners) > 0 {
+	// a restart is detected before the listeners resynchronize with the switch
+	if client.authenticated {
+		client.refreshCoreIdentity()
+	}
+	if listeners := client.getConnectionListeners(); len(listeners) > 0 {
 		go func() {
-			for _, listener := range client.connectionListeners {
+			for _, listener := range listeners {
 				listener.Authenticated(client.authenticated, client)
 			}
 		}()
 	}
+	if client.rudeRejection || !client.authenticated {
+		client.instruments.getMetrics().AuthFailed()
+	}
 	if client.rudeRejection {
 		return errors.New("client is rejected by acl")
 	} else if !client.authenticated {
@@ -215,10 +365,58 @@ func (client *Client) Connect() error {
 	return err
 }
 
+// Shutdown - Close the client for good: the connection is closed, no reconnection follows, and the goroutines
+// notifying the listeners exit once the events already queued are notified.
+//   - The connection listeners implementing IEslShutdownListener are notified after the last event.
+//   - It waits for the event listeners, so it must not be called from one of them.
+func (client *Client) Shutdown() error {
+	var err error
+	client.shutdownOnce.Do(func() {
+		close(client.done)
+		if connection := client.connection(); connection != nil && connection.IsActive() {
+			err = connection.Close()
+		}
+		client.dispatching.Wait()
+		for _, listener := range client.getConnectionListeners() {
+			if shutdownListener, ok := listener.(IEslShutdownListener); ok {
+				shutdownListener.Shutdown(client)
+			}
+		}
+	})
+	return err
+}
+
+// isShutdown - Whether Shutdown was called.
+func (client *Client) isShutdown() bool {
+	select {
+	case <-client.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// LastActivity - When the last message was received, or the connection was established.
+func (client *Client) LastActivity() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&client.lastActivity))
+}
+
+func (client *Client) touch() {
+	atomic.StoreInt64(&client.lastActivity, time.Now().UnixNano())
+}
+
 func (client *Client) canReconnect() {
-	if options.AutoReconnection && options.ReconnectIntervalSeconds > 0 {
-		time.AfterFunc(time.Duration(options.ReconnectIntervalSeconds)*time.Second, func() {
+	if client.isShutdown() {
+		return
+	}
+	reconnectOptions := getOptions()
+	if reconnectOptions.AutoReconnection && reconnectOptions.ReconnectIntervalSeconds > 0 {
+		time.AfterFunc(time.Duration(reconnectOptions.ReconnectIntervalSeconds)*time.Second, func() {
+			if client.isShutdown() {
+				return
+			}
 			logger.Info("Reconnecting ...")
+			client.instruments.getMetrics().Reconnecting()
 			err := client.Connect()
 			if err != nil {
 				logger.Error("Reconnection failure, cause ", err)
@@ -0,0 +1,92 @@
+package esl_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/zhouhailin/freeswitch-esl-go/esl"
+	"github.com/zhouhailin/freeswitch-esl-go/esl/esltest"
+)
+
+// blockingListener - An event listener waiting for release before it records the first event.
+type blockingListener struct {
+	recorder
+	release chan struct{}
+}
+
+func (l *blockingListener) EventReceived(event *esl.EslEvent) error {
+	<-l.release
+	return l.recorder.EventReceived(event)
+}
+
+// sendHeartbeats - Subscribe the client to HEARTBEAT and send it count numbered heartbeats.
+func sendHeartbeats(t *testing.T, server *esltest.Server, client *esl.Client, count int) {
+	t.Helper()
+	if _, err := client.SetEventSubscriptions("plain", "HEARTBEAT"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < count; i++ {
+		server.SendEvent(esltest.NewEvent("HEARTBEAT").Set("Event-Sequence", strconv.Itoa(i)))
+	}
+}
+
+func TestEventQueueWaitsForTheListeners(t *testing.T) {
+	server, client := newTestClient(t, &esl.Options{EventQueueSize: 1})
+	listener := &blockingListener{release: make(chan struct{})}
+	client.AddEventListener(listener)
+	connect(t, server, client)
+	sendHeartbeats(t, server, client, 5)
+	close(listener.release)
+	eventually(t, "the heartbeats", func() bool {
+		return len(listener.names()) == 5
+	})
+	listener.mtx.Lock()
+	defer listener.mtx.Unlock()
+	for i, event := range listener.events {
+		if sequence := (*event.GetEventHeaders())["Event-Sequence"]; sequence != strconv.Itoa(i) {
+			t.Fatalf("event %d has the sequence %s", i, sequence)
+		}
+	}
+}
+
+func TestDropEventsWhenFull(t *testing.T) {
+	server, client := newTestClient(t, &esl.Options{EventQueueSize: 1, DropEventsWhenFull: true})
+	server.SetApiResponse("status", "UP\n")
+	listener := &blockingListener{release: make(chan struct{})}
+	client.AddEventListener(listener)
+	connect(t, server, client)
+	sendHeartbeats(t, server, client, 5)
+	// the reply follows the events, they were all queued or dropped once it is received
+	if _, err := client.SendApi("status", ""); err != nil {
+		t.Fatal(err)
+	}
+	close(listener.release)
+	eventually(t, "the queued heartbeats", func() bool {
+		return len(listener.names()) > 0
+	})
+	time.Sleep(50 * time.Millisecond)
+	if count := len(listener.names()); count == 0 || count >= 5 {
+		t.Fatalf("%d heartbeats notified, expected some to be dropped", count)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	server, client := newTestClient(t, &esl.Options{AutoReconnection: true, ReconnectIntervalSeconds: 1})
+	listener := &recorder{}
+	client.AddEventListener(listener)
+	connect(t, server, client)
+	sendHeartbeats(t, server, client, 1)
+	eventually(t, "the heartbeat", func() bool {
+		return len(listener.names()) == 1
+	})
+	if err := client.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if client.CanSend() {
+		t.Error("the connection is still open")
+	}
+	if _, err := server.WaitConn(1500 * time.Millisecond); err == nil {
+		t.Fatal("the client reconnected after Shutdown")
+	}
+}
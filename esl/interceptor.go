@@ -0,0 +1,136 @@
+package esl
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// Command - An outgoing command, as seen by the command interceptors.
+type Command struct {
+	// Lines - the lines of the command, for example "api status", or "sendmsg <uuid>" followed by its headers
+	Lines []string
+	// Body - the body following the lines of a sendmsg or a sendevent, the lines hold its content-length
+	Body string
+	// Context - the context of the call sending the command, nil when it was sent without one
+	Context context.Context
+}
+
+// Name - The first word of the command, for example api, bgapi or sendmsg.
+func (command *Command) Name() string {
+	if len(command.Lines) == 0 {
+		return ""
+	}
+	return commandName(command.Lines[0])
+}
+
+// Args - The rest of the first line, for example "status" for "api status".
+func (command *Command) Args() string {
+	if len(command.Lines) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(command.Lines[0], command.Name()))
+}
+
+// CommandHandler - Sends a command and returns its reply.
+type CommandHandler func(command *Command) (*EslMessage, error)
+
+// CommandInterceptor - Wraps the sending of the commands, for example to audit them, reject them or rewrite them.
+//   - An interceptor may change the command before calling next, or return without calling next to reject it.
+type CommandInterceptor func(next CommandHandler) CommandHandler
+
+// EventHandler - Notifies the event listeners of an event.
+type EventHandler func(event *EslEvent)
+
+// EventInterceptor - Wraps the notification of the event listeners, for example to audit, filter or enrich the events.
+//   - An interceptor may change the event before calling next, or return without calling next to drop it.
+type EventInterceptor func(next EventHandler) EventHandler
+
+// interceptors - The interceptor chains of a client or of an outbound session, they outlive the reconnections.
+type interceptors struct {
+	mtx      sync.RWMutex
+	commands []CommandInterceptor
+	events   []EventInterceptor
+	policy   *CommandPolicy
+}
+
+func (i *interceptors) addCommandInterceptors(interceptors []CommandInterceptor) {
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
+	i.commands = append(i.commands, interceptors...)
+}
+
+func (i *interceptors) addEventInterceptors(interceptors []EventInterceptor) {
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
+	i.events = append(i.events, interceptors...)
+}
+
+func (i *interceptors) setPolicy(policy *CommandPolicy) {
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
+	i.policy = policy
+}
+
+func (i *interceptors) getPolicy() *CommandPolicy {
+	i.mtx.RLock()
+	defer i.mtx.RUnlock()
+	return i.policy
+}
+
+// commandHandler - Wrap handler in the command policy, then in the command interceptors, the first interceptor is
+// the outermost one.
+func (i *interceptors) commandHandler(handler CommandHandler) CommandHandler {
+	if i == nil {
+		return handler
+	}
+	i.mtx.RLock()
+	defer i.mtx.RUnlock()
+	if policy := i.policy; policy != nil {
+		send := handler
+		handler = func(command *Command) (*EslMessage, error) {
+			if err := policy.Check(command); err != nil {
+				return nil, err
+			}
+			return send(command)
+		}
+	}
+	for index := len(i.commands) - 1; index >= 0; index-- {
+		handler = i.commands[index](handler)
+	}
+	return handler
+}
+
+// eventHandler - Wrap handler in the event interceptors, the first interceptor is the outermost one.
+func (i *interceptors) eventHandler(handler EventHandler) EventHandler {
+	if i == nil {
+		return handler
+	}
+	i.mtx.RLock()
+	defer i.mtx.RUnlock()
+	for index := len(i.events) - 1; index >= 0; index-- {
+		handler = i.events[index](handler)
+	}
+	return handler
+}
+
+// InterceptCommands - Add interceptors run around every command of the client, in the order given.
+//   - The auth command is not intercepted, its password never reaches the interceptors.
+func (client *Client) InterceptCommands(interceptors ...CommandInterceptor) {
+	client.interceptors.addCommandInterceptors(interceptors)
+}
+
+// InterceptEvents - Add interceptors run before the event listeners of the client are notified, in the order given.
+func (client *Client) InterceptEvents(interceptors ...EventInterceptor) {
+	client.interceptors.addEventInterceptors(interceptors)
+}
+
+// InterceptCommands - Add interceptors run around every command of the session, in the order given.
+func (session *OutboundSession) InterceptCommands(interceptors ...CommandInterceptor) {
+	session.interceptors.addCommandInterceptors(interceptors)
+}
+
+// InterceptEvents - Add interceptors run before the event listeners of the session are notified, in the order given.
+func (session *OutboundSession) InterceptEvents(interceptors ...EventInterceptor) {
+	session.interceptors.addEventInterceptors(interceptors)
+}
@@ -0,0 +1,118 @@
+package esl_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/zhouhailin/freeswitch-esl-go/esl"
+	"github.com/zhouhailin/freeswitch-esl-go/esl/esltest"
+)
+
+func TestCommandInterceptors(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	server.SetApiResponse("status", "UP")
+	var order []string
+	trace := func(name string) esl.CommandInterceptor {
+		return func(next esl.CommandHandler) esl.CommandHandler {
+			return func(command *esl.Command) (*esl.EslMessage, error) {
+				if command.Lines[0] == "api version" {
+					order = append(order, name+" "+command.Args())
+				}
+				return next(command)
+			}
+		}
+	}
+	rewrite := func(next esl.CommandHandler) esl.CommandHandler {
+		return func(command *esl.Command) (*esl.EslMessage, error) {
+			if command.Lines[0] == "api version" {
+				command.Lines[0] = "api status"
+			}
+			return next(command)
+		}
+	}
+	rejected := errors.New("rejected")
+	reject := func(next esl.CommandHandler) esl.CommandHandler {
+		return func(command *esl.Command) (*esl.EslMessage, error) {
+			if command.Args() == "hupall" {
+				return nil, rejected
+			}
+			return next(command)
+		}
+	}
+	client.InterceptCommands(trace("first"), trace("second"), rewrite, reject)
+	connect(t, server, client)
+
+	if body, err := client.SendApi("version", ""); err != nil || body != "UP" {
+		t.Fatalf("rewritten command: %q %v", body, err)
+	}
+	if !reflect.DeepEqual(order, []string{"first version", "second version"}) {
+		t.Errorf("order %v", order)
+	}
+	if _, err := client.SendApi("hupall", ""); err != rejected {
+		t.Errorf("rejected command: %v", err)
+	}
+	for _, cmd := range server.Commands() {
+		if cmd.Line == "api version" || cmd.Line == "api hupall" {
+			t.Errorf("%q reached the switch", cmd.Line)
+		}
+	}
+}
+
+func TestAuthIsNotIntercepted(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	server.SetApiResponse("status", "UP")
+	var mtx sync.Mutex
+	var lines []string
+	client.InterceptCommands(func(next esl.CommandHandler) esl.CommandHandler {
+		return func(command *esl.Command) (*esl.EslMessage, error) {
+			mtx.Lock()
+			lines = append(lines, command.Lines...)
+			mtx.Unlock()
+			return next(command)
+		}
+	})
+	connect(t, server, client)
+	if _, err := client.SendApi("status", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	for _, line := range lines {
+		if strings.HasPrefix(line, "auth") || strings.Contains(line, testPassword) {
+			t.Errorf("the interceptor saw %q", line)
+		}
+	}
+	if len(lines) == 0 || lines[len(lines)-1] != "api status" {
+		t.Errorf("intercepted %q", lines)
+	}
+}
+
+func TestEventInterceptors(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	events := &recorder{}
+	client.AddEventListener(events)
+	client.InterceptEvents(func(next esl.EventHandler) esl.EventHandler {
+		return func(event *esl.EslEvent) {
+			if event.GetEventName() != "HEARTBEAT" {
+				next(event)
+			}
+		}
+	})
+	conn := connect(t, server, client)
+	if _, err := client.SetEventSubscriptions("plain", "HEARTBEAT CHANNEL_CREATE"); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SendEvent(esltest.NewEvent("HEARTBEAT"))
+	conn.SendEvent(esltest.NewEvent("CHANNEL_CREATE").Set("Unique-ID", "a"))
+	eventually(t, "CHANNEL_CREATE", func() bool {
+		return len(events.names()) > 0
+	})
+	if names := events.names(); !reflect.DeepEqual(names, []string{"CHANNEL_CREATE"}) {
+		t.Errorf("events %v", names)
+	}
+}
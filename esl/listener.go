@@ -33,3 +33,29 @@ type IEslConnectionListener interface {
 	// Disconnected - connection is closed
 	Disconnected(c *Client)
 }
+
+// IEslShutdownListener - A connection listener notified by Client.Shutdown, after the last event was notified.
+type IEslShutdownListener interface {
+	// Shutdown - the client is closed for good
+	Shutdown(c *Client)
+}
+
+// listenerBase - The no-op listener methods, embedded by the trackers and monitors which do not need them.
+type listenerBase struct{}
+
+// BackgroundJobResultReceived - Implements IEslEventListener.
+func (listenerBase) BackgroundJobResultReceived(event *EslEvent) error {
+	return nil
+}
+
+// ConnectFailure - Implements IEslConnectionListener.
+func (listenerBase) ConnectFailure(c *Client) {
+}
+
+// Connected - Implements IEslConnectionListener.
+func (listenerBase) Connected(c *Client) {
+}
+
+// Disconnected - Implements IEslConnectionListener.
+func (listenerBase) Disconnected(c *Client) {
+}
@@ -3,13 +3,13 @@ package esl
 import "github.com/bytedance/gopkg/util/logger"
 
 func isTraceEnabled() bool {
-	return logger.LevelTrace >= options.Level
+	return logger.LevelTrace >= getOptions().Level
 }
 
 func isDebugEnabled() bool {
-	return logger.LevelDebug >= options.Level
+	return logger.LevelDebug >= getOptions().Level
 }
 
 func isInfoEnabled() bool {
-	return logger.LevelInfo >= options.Level
+	return logger.LevelInfo >= getOptions().Level
 }
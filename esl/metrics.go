@@ -0,0 +1,98 @@
+package esl
+
+import (
+	"strings"
+	"time"
+)
+
+// Metrics - Receives the measures of the clients and of the outbound sessions, set it in Options.Metrics or in
+// OutboundServer.Metrics.
+//   - The methods are called from the IO goroutines and must not block.
+type Metrics interface {
+	// CommandSent - A command is about to be sent, command is its first word, for example api or sendmsg.
+	CommandSent(command string)
+	// CommandCompleted - The reply of a command was received, or err stopped waiting for it.
+	CommandCompleted(command string, latency time.Duration, err error)
+	// MessageReceived - A message was received, for example api/response or text/event-plain.
+	MessageReceived(contentType string)
+	// EventReceived - An event was received.
+	EventReceived(name string)
+	// EventDropped - An event was dropped because the queue of the listeners was full.
+	EventDropped(name string)
+	// EventQueueDepth - The events waiting in a queue of the listeners, queue is events or jobs.
+	EventQueueDepth(queue string, depth int)
+	// Reconnecting - The client tries to reconnect.
+	Reconnecting()
+	// AuthFailed - The switch rejected the password or the address of the client.
+	AuthFailed()
+}
+
+// NoopMetrics - Metrics discarding every measure, the default.
+type NoopMetrics struct {
+}
+
+// CommandSent - Implements Metrics.
+func (NoopMetrics) CommandSent(command string) {
+}
+
+// CommandCompleted - Implements Metrics.
+func (NoopMetrics) CommandCompleted(command string, latency time.Duration, err error) {
+}
+
+// MessageReceived - Implements Metrics.
+func (NoopMetrics) MessageReceived(contentType string) {
+}
+
+// EventReceived - Implements Metrics.
+func (NoopMetrics) EventReceived(name string) {
+}
+
+// EventDropped - Implements Metrics.
+func (NoopMetrics) EventDropped(name string) {
+}
+
+// EventQueueDepth - Implements Metrics.
+func (NoopMetrics) EventQueueDepth(queue string, depth int) {
+}
+
+// Reconnecting - Implements Metrics.
+func (NoopMetrics) Reconnecting() {
+}
+
+// AuthFailed - Implements Metrics.
+func (NoopMetrics) AuthFailed() {
+}
+
+// instruments - The Metrics, Tracer and TraceRedactor of a client or of an outbound server, captured when it is
+// created so that a later NewClient does not change them.
+type instruments struct {
+	metrics  Metrics
+	tracer   Tracer
+	redactor func(command, args string) string
+}
+
+func newInstruments(metrics Metrics, tracer Tracer, redactor func(command, args string) string) *instruments {
+	if metrics == nil {
+		metrics = NoopMetrics{}
+	}
+	if redactor == nil {
+		redactor = RedactCommandArgs
+	}
+	return &instruments{metrics: metrics, tracer: tracer, redactor: redactor}
+}
+
+// getMetrics - The Metrics, NoopMetrics for a connection without instruments.
+func (i *instruments) getMetrics() Metrics {
+	if i == nil {
+		return NoopMetrics{}
+	}
+	return i.metrics
+}
+
+// commandName - The first word of a command, the label of its measures.
+func commandName(command string) string {
+	if index := strings.IndexAny(command, " \n"); index >= 0 {
+		return command[:index]
+	}
+	return command
+}
@@ -0,0 +1,57 @@
+package esl_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zhouhailin/freeswitch-esl-go/esl"
+)
+
+// commandCounter - Metrics counting the commands sent.
+type commandCounter struct {
+	esl.NoopMetrics
+	mtx      sync.Mutex
+	commands map[string]int
+}
+
+func (c *commandCounter) CommandSent(command string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.commands[command]++
+}
+
+func (c *commandCounter) count(command string) int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.commands[command]
+}
+
+func TestMetricsArePerClient(t *testing.T) {
+	first := &commandCounter{commands: make(map[string]int)}
+	server, client := newTestClient(t, &esl.Options{Metrics: first})
+	server.SetApiResponse("status", "UP")
+	connect(t, server, client)
+	// a later client with other options must not change the metrics of the first one
+	otherServer, other := newTestClient(t, nil)
+	otherServer.SetApiResponse("status", "UP")
+	connect(t, otherServer, other)
+	// both clients read core_uuid once authenticated, the counts are stable once the switches received it
+	waitCommand(t, server, "api global_getvar core_uuid")
+	waitCommand(t, otherServer, "api global_getvar core_uuid")
+
+	before := first.count("api")
+	if _, err := client.SendApi("status", ""); err != nil {
+		t.Fatal(err)
+	}
+	if first.count("api") != before+1 {
+		t.Errorf("api commands %d, want %d", first.count("api"), before+1)
+	}
+	if _, err := other.SendApi("status", ""); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if first.count("api") != before+1 {
+		t.Error("the commands of the other client were measured")
+	}
+}
@@ -0,0 +1,221 @@
+package esl
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OriginateRequest - The arguments of an originate api command.
+//   - <pre>
+//   - originate <dial string> &app(arg)
+//   - originate <dial string> extension dialplan context
+//   - </pre>
+type OriginateRequest struct {
+	uuid           string
+	dialString     *DialString
+	timeoutSeconds int
+	callerIdName   string
+	callerIdNumber string
+	application    string
+	applicationArg string
+	extension      string
+	dialplan       string
+	context        string
+}
+
+// NewOriginateRequest - Constructor, the request gets a random origination_uuid unless SetUuid is called.
+func NewOriginateRequest() *OriginateRequest {
+	return &OriginateRequest{uuid: newUuid(), dialString: NewDialString()}
+}
+
+// AddEndpoint - Add an endpoint called simultaneously with the others, for example sofia/gateway/gw/1000 or user/1000.
+func (r *OriginateRequest) AddEndpoint(endpoint string) {
+	r.dialString.Add(NewDialEndpoint(endpoint))
+}
+
+// AddEndpointWithVariables - Add an endpoint with its own [leg] variables.
+func (r *OriginateRequest) AddEndpointWithVariables(endpoint string, variables map[string]string) {
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	dialEndpoint := NewDialEndpoint(endpoint)
+	for _, name := range names {
+		dialEndpoint.SetVariable(name, variables[name])
+	}
+	r.dialString.Add(dialEndpoint)
+}
+
+// SetDialString - Replace the endpoints and variables with a dial string built with NewDialString.
+func (r *OriginateRequest) SetDialString(dialString *DialString) {
+	r.dialString = dialString
+}
+
+// SetVariable - Set a {global} variable applied to every leg.
+func (r *OriginateRequest) SetVariable(name, value string) {
+	r.dialString.SetVariable(name, value)
+}
+
+// SetTimeout - Seconds to wait for an answer, sent as originate_timeout.
+func (r *OriginateRequest) SetTimeout(seconds int) {
+	r.timeoutSeconds = seconds
+}
+
+// SetCallerId - Caller ID presented to the endpoints, sent as origination_caller_id_name/number.
+func (r *OriginateRequest) SetCallerId(name, number string) {
+	r.callerIdName = name
+	r.callerIdNumber = number
+}
+
+// SetUuid - The uuid of the created channel, sent as origination_uuid.
+func (r *OriginateRequest) SetUuid(uuid string) {
+	r.uuid = uuid
+}
+
+// GetUuid - The uuid the created channel will have.
+func (r *OriginateRequest) GetUuid() string {
+	return r.uuid
+}
+
+// SetApplication - Run an application once the call is answered, for example park or playback.
+func (r *OriginateRequest) SetApplication(application, arg string) {
+	r.application = application
+	r.applicationArg = arg
+	r.extension = ""
+}
+
+// SetExtension - Route the answered call to an extension of the dialplan, dialplan defaults to XML and context to
+// default.
+func (r *OriginateRequest) SetExtension(extension, dialplan, dialplanContext string) {
+	r.extension = extension
+	r.dialplan = dialplan
+	r.context = dialplanContext
+	r.application = ""
+}
+
+// Validate - Check the request has an endpoint to call, and no endpoint without address.
+func (r *OriginateRequest) Validate() error {
+	endpoints := r.dialString.Endpoints()
+	if len(endpoints) == 0 {
+		return errors.New("originate requires an endpoint")
+	}
+	for _, endpoint := range endpoints {
+		if strings.TrimSpace(endpoint.Address) == "" {
+			return errors.New("originate endpoint without address")
+		}
+	}
+	return nil
+}
+
+// ToString - The arguments of the originate command, call Validate first.
+func (r *OriginateRequest) ToString() string {
+	var variables []DialVariable
+	if r.uuid != "" {
+		variables = append(variables, DialVariable{"origination_uuid", r.uuid})
+	}
+	if r.timeoutSeconds > 0 {
+		variables = append(variables, DialVariable{"originate_timeout", strconv.Itoa(r.timeoutSeconds)})
+	}
+	if r.callerIdName != "" {
+		variables = append(variables, DialVariable{"origination_caller_id_name", r.callerIdName})
+	}
+	if r.callerIdNumber != "" {
+		variables = append(variables, DialVariable{"origination_caller_id_number", r.callerIdNumber})
+	}
+	// the request variables go first, the ones set on the dial string keep their own scope
+	dialString := *r.dialString
+	if len(dialString.Threads) > 1 {
+		dialString.EnterpriseVariables = append(variables, dialString.EnterpriseVariables...)
+	} else if len(dialString.Threads) == 1 {
+		thread := *dialString.Threads[0]
+		thread.Variables = append(variables, thread.Variables...)
+		dialString.Threads = []*DialThread{&thread}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(dialString.ToString())
+	sb.WriteString(" ")
+	if r.extension != "" {
+		dialplan, dialplanContext := r.dialplan, r.context
+		if dialplan == "" {
+			dialplan = "XML"
+		}
+		if dialplanContext == "" {
+			dialplanContext = "default"
+		}
+		sb.WriteString(quoteArg(r.extension))
+		sb.WriteString(" ")
+		sb.WriteString(dialplan)
+		sb.WriteString(" ")
+		sb.WriteString(dialplanContext)
+	} else {
+		application := r.application
+		if application == "" {
+			application = "park"
+		}
+		sb.WriteString(quoteArg("&" + application + "(" + r.applicationArg + ")"))
+	}
+	return sb.String()
+}
+
+// OriginateError - The originate command failed, Cause is the hangup cause, for example USER_BUSY or NO_ANSWER.
+type OriginateError struct {
+	Cause string
+}
+
+func (e *OriginateError) Error() string {
+	return "originate failed: " + e.Cause
+}
+
+// CallHandle - The channel created by Originate.
+type CallHandle struct {
+	Uuid    string
+	JobUuid string
+	client  *Client
+}
+
+// Originate - Issue the originate request with bgapi and wait for its result.
+//   - @return the handle of the created channel, an *OriginateError holding the hangup cause when the call failed
+func (client *Client) Originate(ctx context.Context, request *OriginateRequest) (*CallHandle, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+	event, err := client.SendAsyncApiCommandAndWait(ctx, "originate", request.ToString())
+	if err != nil {
+		return nil, err
+	}
+	uuid, err := parseOriginateResult(event.getBody())
+	if err != nil {
+		return nil, err
+	}
+	return &CallHandle{
+		Uuid:    uuid,
+		JobUuid: (*event.GetEventHeaders())["Job-UUID"],
+		client:  client,
+	}, nil
+}
+
+// parseOriginateResult - "+OK <uuid>" or "-ERR <cause>".
+func parseOriginateResult(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if strings.HasPrefix(body, OK) {
+		return strings.TrimSpace(strings.TrimPrefix(body, OK)), nil
+	}
+	cause := strings.TrimSpace(strings.TrimPrefix(body, "-ERR"))
+	if cause == "" {
+		cause = "UNKNOWN"
+	}
+	return "", &OriginateError{Cause: cause}
+}
+
+// quoteArg - Quote an api argument holding spaces or quotes so it is not split, the quotes it holds are escaped as \'.
+func quoteArg(arg string) string {
+	if strings.ContainsAny(arg, " '") {
+		return "'" + strings.Replace(arg, "'", "\\'", -1) + "'"
+	}
+	return arg
+}
@@ -0,0 +1,110 @@
+package esl_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zhouhailin/freeswitch-esl-go/esl"
+)
+
+func TestOriginateRequestToString(t *testing.T) {
+	request := esl.NewOriginateRequest()
+	request.SetUuid("u1")
+	request.SetTimeout(30)
+	request.SetCallerId("Alice", "1000")
+	request.AddEndpoint("user/1001")
+	request.SetApplication("playback", "/tmp/hello.wav")
+	want := "{origination_uuid=u1,originate_timeout=30,origination_caller_id_name=Alice," +
+		"origination_caller_id_number=1000}user/1001 &playback(/tmp/hello.wav)"
+	if got := request.ToString(); got != want {
+		t.Fatalf("got %s\nwant %s", got, want)
+	}
+	request.SetApplication("playback", "say it's me")
+	if got := request.ToString(); !strings.HasSuffix(got, `user/1001 '&playback(say it\'s me)'`) {
+		t.Fatalf("quoted application: %s", got)
+	}
+	request.SetExtension("1002", "", "")
+	if got := request.ToString(); !strings.HasSuffix(got, "user/1001 1002 XML default") {
+		t.Fatalf("extension: %s", got)
+	}
+}
+
+func TestSendAsyncApiCommandAndWaitSubscribesToBackgroundJob(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	server.SetApiResponse("status", "UP 0 years")
+	connect(t, server, client)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	event, err := client.SendAsyncApiCommandAndWait(ctx, "status", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitCommand(t, server, "event plain "+esl.JOB_EVENTS)
+	if body := strings.TrimSpace(strings.Join(*event.GetEventBodyLines(), "\n")); body != "UP 0 years" {
+		t.Fatalf("body %q", body)
+	}
+}
+
+func TestOriginate(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	var mtx sync.Mutex
+	var received string
+	server.HandleApi("originate", func(args string) string {
+		mtx.Lock()
+		received = args
+		mtx.Unlock()
+		if strings.Contains(args, "user/busy") {
+			return "-ERR USER_BUSY\n"
+		}
+		return "+OK 5f3a\n"
+	})
+	connect(t, server, client)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	request := esl.NewOriginateRequest()
+	request.AddEndpoint("user/1000")
+	call, err := client.Originate(ctx, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if call.Uuid != "5f3a" || call.JobUuid == "" {
+		t.Fatalf("unexpected handle %+v", call)
+	}
+	mtx.Lock()
+	sent := received
+	mtx.Unlock()
+	if !strings.Contains(sent, "origination_uuid="+request.GetUuid()) {
+		t.Fatalf("origination_uuid not sent: %s", sent)
+	}
+
+	busy := esl.NewOriginateRequest()
+	busy.AddEndpoint("user/busy")
+	_, err = client.Originate(ctx, busy)
+	if originateErr, ok := err.(*esl.OriginateError); !ok || originateErr.Cause != "USER_BUSY" {
+		t.Fatalf("expected an OriginateError USER_BUSY, got %v", err)
+	}
+}
+
+func TestOriginateWithoutEndpoint(t *testing.T) {
+	request := esl.NewOriginateRequest()
+	request.SetApplication("park", "")
+	if request.Validate() == nil {
+		t.Fatal("a request without endpoint must be rejected")
+	}
+	server, client := newTestClient(t, nil)
+	connect(t, server, client)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := client.Originate(ctx, request); err == nil {
+		t.Fatal("originate without endpoint must fail")
+	}
+	for _, cmd := range server.Commands() {
+		if strings.HasPrefix(cmd.Line, "bgapi originate") {
+			t.Fatalf("sent %s", cmd.Line)
+		}
+	}
+}
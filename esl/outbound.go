@@ -0,0 +1,464 @@
+package esl
+
+import (
+	"context"
+	"errors"
+	"github.com/bytedance/gopkg/util/logger"
+	"github.com/cloudwego/netpoll"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type outboundSessionKey struct{}
+
+// OutboundHandler - Controls the call of an outbound session, the session is closed when it returns.
+type OutboundHandler func(session *OutboundSession)
+
+// OutboundServer - Accepts the connections FreeSWITCH opens when a call reaches the socket application.
+//   - <pre>
+//   - <action application="socket" data="127.0.0.1:8084 async full"/>
+//   - </pre>
+type OutboundServer struct {
+	Network string
+	Address string
+	// Metrics, Tracer and TraceRedactor - as in Options, nil discards the measures and disables the tracing, they
+	// must be set before Start
+	Metrics       Metrics
+	Tracer        Tracer
+	TraceRedactor func(command, args string) string
+	handler       OutboundHandler
+	listener      netpoll.Listener
+	eventLoop     netpoll.EventLoop
+}
+
+// NewOutboundServer - Constructor, address is for example 127.0.0.1:8084 or :0 for any free port.
+func NewOutboundServer(address string, handler OutboundHandler) *OutboundServer {
+	return &OutboundServer{
+		Network: "tcp",
+		Address: address,
+		handler: handler,
+	}
+}
+
+// Start - Listen and serve the connections in the background.
+func (s *OutboundServer) Start() error {
+	if s.handler == nil {
+		return errors.New("OutboundServer requires a handler")
+	}
+	listener, err := netpoll.CreateListener(s.Network, s.Address)
+	if err != nil {
+		return err
+	}
+	eventLoop, err := netpoll.NewEventLoop(s.onRequest, netpoll.WithOnConnect(s.onConnect))
+	if err != nil {
+		_ = listener.Close()
+		return err
+	}
+	s.listener = listener
+	s.eventLoop = eventLoop
+	if isInfoEnabled() {
+		logger.Infof("Outbound server listening on %s\n", listener.Addr())
+	}
+	go func() {
+		err := eventLoop.Serve(listener)
+		if err != nil {
+			logger.Error("Outbound server stopped, cause ", err)
+		}
+	}()
+	return nil
+}
+
+// Addr - The address the server listens on, nil before Start.
+func (s *OutboundServer) Addr() net.Addr {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Shutdown - Stop listening and wait for the open connections to close, or for ctx to be done.
+func (s *OutboundServer) Shutdown(ctx context.Context) error {
+	if s.eventLoop == nil {
+		return nil
+	}
+	return s.eventLoop.Shutdown(ctx)
+}
+
+func (s *OutboundServer) onConnect(ctx context.Context, connection netpoll.Connection) context.Context {
+	session := &OutboundSession{
+		SocketConnection: SocketConnection{
+			Connection: connection,
+			msg:        make(chan *EslMessage),
+		},
+		events:       make(chan *EslEvent, 1024),
+		closed:       make(chan struct{}),
+		disconnected: make(chan struct{}),
+	}
+	session.SocketConnection.interceptors = &session.interceptors
+	session.SocketConnection.instruments = newInstruments(s.Metrics, s.Tracer, s.TraceRedactor)
+	_ = connection.AddCloseCallback(func(connection netpoll.Connection) error {
+		if isDebugEnabled() {
+			logger.Debugf("[%v] outbound connection closed\n", connection.RemoteAddr())
+		}
+		session.closeOnce.Do(func() {
+			close(session.msg)
+			close(session.closed)
+		})
+		// a connection closed without notice ends the channel for the session too
+		session.disconnectOnce.Do(func() {
+			close(session.disconnected)
+		})
+		return nil
+	})
+	go session.dispatchEvents()
+	go s.serveSession(session)
+	return context.WithValue(ctx, outboundSessionKey{}, session)
+}
+
+func (s *OutboundServer) onRequest(ctx context.Context, connection netpoll.Connection) error {
+	session, _ := ctx.Value(outboundSessionKey{}).(*OutboundSession)
+	m := EslMessage{
+		headers:       make(map[Name]string),
+		body:          *new([]string),
+		contentLength: 0,
+	}
+	err := decode(connection.Reader(), &m)
+	if err != nil {
+		return err
+	}
+	if session == nil {
+		return errors.New("outbound message received without session")
+	}
+	return session.messageReceived(&m)
+}
+
+// serveSession - Send connect, hand the session to the handler and close it once the handler returns.
+func (s *OutboundServer) serveSession(session *OutboundSession) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Errorf("Outbound handler of %s panicked: %v\n", session.uuid, r)
+		}
+		if session.Connection.IsActive() {
+			_ = session.Connection.Close()
+		}
+	}()
+	err := session.connect()
+	if err != nil {
+		logger.Errorf("Outbound connect failure, cause %v\n", err)
+		return
+	}
+	s.handler(session)
+}
+
+// OutboundSession - The call controlled through an outbound socket connection.
+//   - Applications run synchronously, waiting for their CHANNEL_EXECUTE_COMPLETE, or asynchronously with the
+//   - *Async methods, returning as soon as FreeSWITCH accepted them.
+type OutboundSession struct {
+	SocketConnection
+	uuid           string
+	channelData    map[string]string
+	listenerMtx    sync.RWMutex
+	eventListeners []IEslEventListener
+	events         chan *EslEvent
+	executions     executions
+	myEventsOnce   sync.Once
+	myEventsErr    error
+	closeOnce      sync.Once
+	closed         chan struct{}
+	disconnectOnce sync.Once
+	disconnected   chan struct{}
+	interceptors   interceptors
+}
+
+// GetUuid - The Unique-ID of the channel.
+func (session *OutboundSession) GetUuid() string {
+	return session.uuid
+}
+
+// GetChannelData - The channel headers and variables received in the reply of connect, variables are named
+// variable_<name>.
+func (session *OutboundSession) GetChannelData() map[string]string {
+	return session.channelData
+}
+
+// GetVariable - A channel variable as it was when the call reached the socket application.
+func (session *OutboundSession) GetVariable(name string) string {
+	return session.channelData["variable_"+name]
+}
+
+// AddEventListener - Receive the events of the session, see MyEvents.
+func (session *OutboundSession) AddEventListener(listener IEslEventListener) {
+	session.listenerMtx.Lock()
+	defer session.listenerMtx.Unlock()
+	session.eventListeners = append(session.eventListeners, listener)
+}
+
+// Closed - Closed once the connection is closed.
+func (session *OutboundSession) Closed() <-chan struct{} {
+	return session.closed
+}
+
+// Disconnected - Closed once FreeSWITCH sent its disconnect notice or the connection is closed, the channel is gone.
+func (session *OutboundSession) Disconnected() <-chan struct{} {
+	return session.disconnected
+}
+
+// Execute - Execute an application and wait for its CHANNEL_EXECUTE_COMPLETE, MyEvents is sent first if needed.
+//   - @return a *ChannelHangupError when the channel hangs up first
+func (session *OutboundSession) Execute(ctx context.Context, application, arg string) (*ExecuteResult, error) {
+	err := session.ensureMyEvents()
+	if err != nil {
+		return nil, err
+	}
+	return session.executions.executeAndWait(ctx, &session.SocketConnection, session.uuid, application, arg,
+		session.disconnected)
+}
+
+// ExecuteAsync - Execute an application without waiting for it to complete.
+func (session *OutboundSession) ExecuteAsync(application, arg string) error {
+	return session.sendMsg(NewExecuteMsg(session.uuid, application, arg))
+}
+
+// Answer - Answer the call.
+func (session *OutboundSession) Answer(ctx context.Context) error {
+	_, err := session.Execute(ctx, "answer", "")
+	return err
+}
+
+// AnswerAsync - Answer the call without waiting.
+func (session *OutboundSession) AnswerAsync() error {
+	return session.ExecuteAsync("answer", "")
+}
+
+// Playback - Play a file and wait for the end of the playback.
+//   - @return the Application-Response, for example FILE PLAYED
+func (session *OutboundSession) Playback(ctx context.Context, path string) (string, error) {
+	result, err := session.Execute(ctx, "playback", path)
+	if err != nil {
+		return "", err
+	}
+	return result.Response, nil
+}
+
+// PlaybackAsync - Start playing a file.
+func (session *OutboundSession) PlaybackAsync(path string) error {
+	return session.ExecuteAsync("playback", path)
+}
+
+// Speak - Speak the text with a text to speech engine, for example flite, and wait for the end of the speech.
+func (session *OutboundSession) Speak(ctx context.Context, engine, voice, text string) error {
+	_, err := session.Execute(ctx, "speak", engine+"|"+voice+"|"+text)
+	return err
+}
+
+// SpeakAsync - Start speaking the text.
+func (session *OutboundSession) SpeakAsync(engine, voice, text string) error {
+	return session.ExecuteAsync("speak", engine+"|"+voice+"|"+text)
+}
+
+// Bridge - Bridge the call to the dial string and wait for the bridge to end.
+//   - @return the Application-Response
+func (session *OutboundSession) Bridge(ctx context.Context, dialString *DialString) (string, error) {
+	result, err := session.Execute(ctx, "bridge", dialString.ToString())
+	if err != nil {
+		return "", err
+	}
+	return result.Response, nil
+}
+
+// BridgeAsync - Start bridging the call to the dial string.
+func (session *OutboundSession) BridgeAsync(dialString *DialString) error {
+	return session.ExecuteAsync("bridge", dialString.ToString())
+}
+
+// SetVar - Set a channel variable with the set application and wait for it.
+func (session *OutboundSession) SetVar(ctx context.Context, name, value string) error {
+	_, err := session.Execute(ctx, "set", name+"="+value)
+	return err
+}
+
+// SetVarAsync - Set a channel variable without waiting.
+func (session *OutboundSession) SetVarAsync(name, value string) error {
+	return session.ExecuteAsync("set", name+"="+value)
+}
+
+// Hangup - Hang up the call, the cause defaults to NORMAL_CLEARING.
+func (session *OutboundSession) Hangup(cause string) error {
+	return session.sendMsg(NewHangupMsg(session.uuid, cause))
+}
+
+// Linger - Keep the connection open after the hangup to receive the last events, 0 seconds means until closed.
+func (session *OutboundSession) Linger(seconds int) error {
+	command := "linger"
+	if seconds > 0 {
+		command += " " + strconv.Itoa(seconds)
+	}
+	return session.command(command)
+}
+
+// NoLinger - Close the connection as soon as the channel hangs up.
+func (session *OutboundSession) NoLinger() error {
+	return session.command("nolinger")
+}
+
+// MyEvents - Receive every event of the channel.
+func (session *OutboundSession) MyEvents() error {
+	return session.command("myevents")
+}
+
+// DivertEvents - Receive the events of the applications, for example the DETECTED_SPEECH events of detect_speech.
+func (session *OutboundSession) DivertEvents(on bool) error {
+	if on {
+		return session.command("divert_events on")
+	}
+	return session.command("divert_events off")
+}
+
+// Resume - Let the channel continue in the dialplan once the connection is closed.
+func (session *OutboundSession) Resume() error {
+	return session.command("resume")
+}
+
+// Filter - Only receive the events whose header has the value.
+func (session *OutboundSession) Filter(header, value string) error {
+	response, err := session.AddEventFilter(header, value)
+	if err != nil {
+		return err
+	}
+	if !response.IsOk() {
+		return errors.New("filter " + header + " " + value + ": " + response.GetReplyText())
+	}
+	return nil
+}
+
+func (session *OutboundSession) ensureMyEvents() error {
+	session.myEventsOnce.Do(func() {
+		session.myEventsErr = session.MyEvents()
+	})
+	return session.myEventsErr
+}
+
+func (session *OutboundSession) command(command string) error {
+	err := session.CheckConnected()
+	if err != nil {
+		return err
+	}
+	response, err := session.sendSyncSingleLineCommand(command)
+	if err != nil {
+		return err
+	}
+	replyText := response.GetHeaderValue(REPLY_TEXT)
+	if !strings.HasPrefix(replyText, OK) {
+		return errors.New(command + ": " + replyText)
+	}
+	return nil
+}
+
+func (session *OutboundSession) sendMsg(sendMsg *SendMsg) error {
+	response, err := session.SendMessage(*sendMsg)
+	if err != nil {
+		return err
+	}
+	if !response.IsOk() {
+		return errors.New(sendMsg.ToString() + ": " + response.GetReplyText())
+	}
+	return nil
+}
+
+// connect - Send connect, the reply holds the channel data.
+func (session *OutboundSession) connect() error {
+	response, err := session.sendSyncSingleLineCommand("connect")
+	if err != nil {
+		return err
+	}
+	session.channelData = make(map[string]string, len(response.headers))
+	for name, value := range response.headers {
+		if decoded, err := url.QueryUnescape(value); err == nil {
+			value = decoded
+		}
+		session.channelData[string(name)] = value
+	}
+	session.uuid = session.channelData["Unique-ID"]
+	if session.uuid == "" {
+		return errors.New("connect reply without Unique-ID: " + response.GetHeaderValue(REPLY_TEXT))
+	}
+	// an outbound connection needs no authentication, it is ready once connected
+	session.authenticatorResponded = true
+	session.authenticated = true
+	return nil
+}
+
+func (session *OutboundSession) messageReceived(m *EslMessage) error {
+	contentType := m.GetContentType()
+	session.instruments.getMetrics().MessageReceived(contentType)
+	switch contentType {
+	case TEXT_EVENT_PLAIN:
+		event, err := NewEslEvent(m, true)
+		if err != nil {
+			return err
+		}
+		session.eventReceived(event)
+	case API_RESPONSE, COMMAND_REPLY:
+		session.msg <- m
+	case TEXT_DISCONNECT_NOTICE:
+		if isDebugEnabled() {
+			logger.Debugf("Outbound disconnect notice received for %s, %s\n", session.uuid,
+				m.GetHeaderValue("Content-Disposition"))
+		}
+		session.disconnectOnce.Do(func() {
+			close(session.disconnected)
+		})
+	default:
+		logger.Warnf("Unexpected outbound message content type %s", contentType)
+	}
+	return nil
+}
+
+func (session *OutboundSession) eventReceived(event *EslEvent) {
+	session.instruments.getMetrics().EventReceived(event.GetEventName())
+	switch event.GetEventName() {
+	case "CHANNEL_EXECUTE_COMPLETE", "CHANNEL_HANGUP":
+		session.executions.eventReceived(event)
+	}
+	session.listenerMtx.RLock()
+	listeners := len(session.eventListeners)
+	session.listenerMtx.RUnlock()
+	if listeners == 0 {
+		return
+	}
+	select {
+	case <-session.closed:
+	case session.events <- event:
+	default:
+		session.instruments.getMetrics().EventDropped(event.GetEventName())
+		logger.Warnf("Outbound event queue is full, dropping %s\n", event.ToString())
+	}
+}
+
+// dispatchEvents - Notify the event listeners of each queued event, one event at a time.
+func (session *OutboundSession) dispatchEvents() {
+	for {
+		var event *EslEvent
+		select {
+		case event = <-session.events:
+		case <-session.closed:
+			return
+		}
+		session.interceptors.eventHandler(session.notifyListeners)(event)
+	}
+}
+
+// notifyListeners - The end of the event interceptor chain.
+func (session *OutboundSession) notifyListeners(event *EslEvent) {
+	session.listenerMtx.RLock()
+	listeners := session.eventListeners
+	session.listenerMtx.RUnlock()
+	for i, listener := range listeners {
+		if err := session.instruments.notifyListener(listener, event); err != nil {
+			logger.Errorf("%d Error caught notifying listener of event %s, cause %v\n", i, event.ToString(), err)
+		}
+	}
+}
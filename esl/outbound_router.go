@@ -0,0 +1,270 @@
+package esl
+
+import (
+	"github.com/bytedance/gopkg/util/logger"
+	"regexp"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+const (
+	// NO_ROUTE_CAUSE - The hangup cause of the sessions no route matches when there is no default handler.
+	NO_ROUTE_CAUSE = "NO_ROUTE_DESTINATION"
+	// CONGESTION_CAUSE - The hangup cause of the sessions rejected by a concurrency limit.
+	CONGESTION_CAUSE = "NORMAL_CIRCUIT_CONGESTION"
+	// FAILURE_CAUSE - The hangup cause of the sessions whose handler panicked.
+	FAILURE_CAUSE = "NORMAL_TEMPORARY_FAILURE"
+)
+
+// OutboundMiddleware - Wraps an outbound handler, for example to log, recover or limit the sessions.
+type OutboundMiddleware func(next OutboundHandler) OutboundHandler
+
+// OutboundRoute - Sessions whose channel data match every rule of the route are served by its handler.
+type OutboundRoute struct {
+	Name        string
+	router      *OutboundRouter
+	rules       []outboundRule
+	handler     OutboundHandler
+	middlewares []OutboundMiddleware
+}
+
+type outboundRule struct {
+	header  string
+	pattern *regexp.Regexp
+}
+
+// Header - Match a channel data header against a regular expression, a missing header matches as empty.
+//   - The pattern is compiled with regexp.MustCompile, so it panics when invalid.
+func (route *OutboundRoute) Header(header, pattern string) *OutboundRoute {
+	rule := outboundRule{header: header, pattern: regexp.MustCompile(pattern)}
+	route.router.change(func() {
+		route.rules = append(route.rules, rule)
+	})
+	return route
+}
+
+// DestinationNumber - Match Caller-Destination-Number, for example ^1\d{3}$.
+func (route *OutboundRoute) DestinationNumber(pattern string) *OutboundRoute {
+	return route.Header("Caller-Destination-Number", pattern)
+}
+
+// Context - Match Caller-Context, the dialplan context of the call.
+func (route *OutboundRoute) Context(pattern string) *OutboundRoute {
+	return route.Header("Caller-Context", pattern)
+}
+
+// Profile - Match the sofia profile of the call.
+func (route *OutboundRoute) Profile(pattern string) *OutboundRoute {
+	return route.Header("variable_sofia_profile_name", pattern)
+}
+
+// Variable - Match a channel variable, for example sip_h_X-Tenant.
+func (route *OutboundRoute) Variable(name, pattern string) *OutboundRoute {
+	return route.Header("variable_"+name, pattern)
+}
+
+// Use - Add middlewares run for the sessions of this route only, after the ones of the router.
+func (route *OutboundRoute) Use(middlewares ...OutboundMiddleware) *OutboundRoute {
+	route.router.change(func() {
+		route.middlewares = append(route.middlewares, middlewares...)
+	})
+	return route
+}
+
+// MaxConcurrent - Hang up the sessions of the route with CONGESTION_CAUSE while limit sessions are served.
+func (route *OutboundRoute) MaxConcurrent(limit int) *OutboundRoute {
+	return route.Use(ConcurrencyLimitMiddleware(limit, CONGESTION_CAUSE))
+}
+
+// Matches - Whether the channel data match every rule.
+func (route *OutboundRoute) Matches(channelData map[string]string) bool {
+	if route.router != nil {
+		route.router.mtx.Lock()
+		defer route.router.mtx.Unlock()
+	}
+	return route.matches(channelData)
+}
+
+// matches - Must be called with the lock of the router held.
+func (route *OutboundRoute) matches(channelData map[string]string) bool {
+	for _, rule := range route.rules {
+		if !rule.pattern.MatchString(channelData[rule.header]) {
+			return false
+		}
+	}
+	return true
+}
+
+// OutboundRouter - Serves the sessions of an OutboundServer with the first matching route.
+//   - <pre>
+//   - router := esl.NewOutboundRouter()
+//   - router.Use(esl.LoggingMiddleware(), esl.RecoveryMiddleware())
+//   - router.Route("support", supportHandler).DestinationNumber("^5000$").MaxConcurrent(10)
+//   - server := esl.NewOutboundServer(":8084", router.Serve)
+//   - </pre>
+type OutboundRouter struct {
+	routes         []*OutboundRoute
+	middlewares    []OutboundMiddleware
+	defaultHandler OutboundHandler
+	mtx            sync.Mutex
+	handlers       map[*OutboundRoute]OutboundHandler
+}
+
+// NewOutboundRouter - Constructor, sessions no route matches are hung up with NO_ROUTE_CAUSE until SetDefault is
+// called.
+func NewOutboundRouter() *OutboundRouter {
+	return &OutboundRouter{}
+}
+
+// Use - Add middlewares run for every session, default handler included.
+func (r *OutboundRouter) Use(middlewares ...OutboundMiddleware) {
+	r.change(func() {
+		r.middlewares = append(r.middlewares, middlewares...)
+	})
+}
+
+// Route - Add a route, the routes are tried in the order they were added.
+//   - @return the route, its rules are added with its methods
+func (r *OutboundRouter) Route(name string, handler OutboundHandler) *OutboundRoute {
+	route := &OutboundRoute{Name: name, router: r, handler: handler}
+	r.change(func() {
+		r.routes = append(r.routes, route)
+	})
+	return route
+}
+
+// SetDefault - The handler of the sessions no route matches.
+func (r *OutboundRouter) SetDefault(handler OutboundHandler) {
+	r.change(func() {
+		r.defaultHandler = handler
+	})
+}
+
+// change - Apply a change of the routes or of the middlewares, the handlers are built again for the next session.
+func (r *OutboundRouter) change(apply func()) {
+	if r == nil {
+		apply()
+		return
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	apply()
+	r.handlers = nil
+}
+
+// Match - The first route matching the channel data, nil when none does.
+func (r *OutboundRouter) Match(channelData map[string]string) *OutboundRoute {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	for _, route := range r.routes {
+		if route.matches(channelData) {
+			return route
+		}
+	}
+	return nil
+}
+
+// Serve - The OutboundHandler to give to NewOutboundServer.
+//   - Routes, rules and middlewares may be added while the server runs, the sessions starting afterwards use them.
+func (r *OutboundRouter) Serve(session *OutboundSession) {
+	route := r.Match(session.GetChannelData())
+	if isDebugEnabled() {
+		name := "default"
+		if route != nil {
+			name = route.Name
+		}
+		logger.Debugf("Outbound session %s routed to %s\n", session.GetUuid(), name)
+	}
+	r.handler(route)(session)
+}
+
+// handler - The handler of the route wrapped by its middlewares, built once per route until the next change.
+func (r *OutboundRouter) handler(route *OutboundRoute) OutboundHandler {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if r.handlers == nil {
+		r.handlers = make(map[*OutboundRoute]OutboundHandler, len(r.routes)+1)
+		for _, candidate := range r.routes {
+			middlewares := append(append([]OutboundMiddleware{}, r.middlewares...), candidate.middlewares...)
+			r.handlers[candidate] = chainMiddlewares(candidate.handler, middlewares)
+		}
+		defaultHandler := r.defaultHandler
+		if defaultHandler == nil {
+			defaultHandler = hangupHandler(NO_ROUTE_CAUSE)
+		}
+		r.handlers[nil] = chainMiddlewares(defaultHandler, r.middlewares)
+	}
+	return r.handlers[route]
+}
+
+// chainMiddlewares - The first middleware is the outermost one.
+func chainMiddlewares(handler OutboundHandler, middlewares []OutboundMiddleware) OutboundHandler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+func hangupHandler(cause string) OutboundHandler {
+	return func(session *OutboundSession) {
+		err := session.Hangup(cause)
+		if err != nil {
+			logger.Warnf("Hangup of outbound session %s failed, cause %v\n", session.GetUuid(), err)
+		}
+	}
+}
+
+// LoggingMiddleware - Log the start and the end of every session with its duration.
+func LoggingMiddleware() OutboundMiddleware {
+	return func(next OutboundHandler) OutboundHandler {
+		return func(session *OutboundSession) {
+			start := time.Now()
+			if isInfoEnabled() {
+				logger.Infof("Outbound session %s started, destination %s\n", session.GetUuid(),
+					session.GetChannelData()["Caller-Destination-Number"])
+			}
+			defer func() {
+				if isInfoEnabled() {
+					logger.Infof("Outbound session %s ended after %s\n", session.GetUuid(), time.Since(start))
+				}
+			}()
+			next(session)
+		}
+	}
+}
+
+// RecoveryMiddleware - Recover the panics of the handler, log them and hang up with FAILURE_CAUSE.
+func RecoveryMiddleware() OutboundMiddleware {
+	return func(next OutboundHandler) OutboundHandler {
+		return func(session *OutboundSession) {
+			defer func() {
+				if r := recover(); r != nil {
+					logger.Errorf("Outbound session %s panicked: %v\n%s", session.GetUuid(), r, debug.Stack())
+					hangupHandler(FAILURE_CAUSE)(session)
+				}
+			}()
+			next(session)
+		}
+	}
+}
+
+// ConcurrencyLimitMiddleware - Serve at most limit sessions at once, the others are hung up with rejectCause.
+func ConcurrencyLimitMiddleware(limit int, rejectCause string) OutboundMiddleware {
+	slots := make(chan struct{}, limit)
+	return func(next OutboundHandler) OutboundHandler {
+		return func(session *OutboundSession) {
+			select {
+			case slots <- struct{}{}:
+			default:
+				logger.Warnf("Outbound session %s rejected, %d sessions are served\n", session.GetUuid(), limit)
+				hangupHandler(rejectCause)(session)
+				return
+			}
+			defer func() {
+				<-slots
+			}()
+			next(session)
+		}
+	}
+}
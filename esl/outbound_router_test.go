@@ -0,0 +1,127 @@
+package esl_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/zhouhailin/freeswitch-esl-go/esl"
+	"github.com/zhouhailin/freeswitch-esl-go/esl/esltest"
+)
+
+func TestOutboundRouter(t *testing.T) {
+	routed := make(chan string, 4)
+	router := esl.NewOutboundRouter()
+	var mtx sync.Mutex
+	var order []string
+	router.Use(func(next esl.OutboundHandler) esl.OutboundHandler {
+		return func(session *esl.OutboundSession) {
+			mtx.Lock()
+			order = append(order, "router")
+			mtx.Unlock()
+			next(session)
+		}
+	})
+	router.Route("support", func(session *esl.OutboundSession) {
+		routed <- "support"
+	}).DestinationNumber(`^1\d{3}$`).Use(func(next esl.OutboundHandler) esl.OutboundHandler {
+		return func(session *esl.OutboundSession) {
+			mtx.Lock()
+			order = append(order, "route")
+			mtx.Unlock()
+			next(session)
+		}
+	})
+	router.Route("tenant", func(session *esl.OutboundSession) {
+		routed <- "tenant"
+	}).Variable("sip_h_X-Tenant", "^acme$")
+	server, outbound := newOutboundServer(t, router.Serve)
+
+	dial := func(channel *esltest.Event) {
+		if _, err := server.DialOutbound(outbound.Addr().String(), channel); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dial(esltest.NewEvent("CHANNEL_DATA").Set("Caller-Destination-Number", "1234"))
+	if got := <-routed; got != "support" {
+		t.Fatalf("routed to %s", got)
+	}
+	mtx.Lock()
+	seen := append([]string(nil), order...)
+	mtx.Unlock()
+	if len(seen) != 2 || seen[0] != "router" || seen[1] != "route" {
+		t.Fatalf("middleware order %v", seen)
+	}
+	dial(esltest.NewEvent("CHANNEL_DATA").Set("Caller-Destination-Number", "5").
+		Set("variable_sip_h_X-Tenant", "acme"))
+	if got := <-routed; got != "tenant" {
+		t.Fatalf("routed to %s", got)
+	}
+	dial(esltest.NewEvent("CHANNEL_DATA").Set("Unique-ID", "nomatch").Set("Caller-Destination-Number", "5"))
+	if hangup := waitSendMsg(t, server, "nomatch", "hangup"); hangup.Headers["hangup-cause"] != esl.NO_ROUTE_CAUSE {
+		t.Fatalf("unmatched session not hung up: %+v", hangup.Headers)
+	}
+}
+
+func TestConcurrencyLimitMiddleware(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	router := esl.NewOutboundRouter()
+	router.Route("limited", func(session *esl.OutboundSession) {
+		started <- struct{}{}
+		<-release
+	}).MaxConcurrent(1)
+	server, outbound := newOutboundServer(t, router.Serve)
+	defer close(release)
+	if _, err := server.DialOutbound(outbound.Addr().String(), nil); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	if _, err := server.DialOutbound(outbound.Addr().String(),
+		esltest.NewEvent("CHANNEL_DATA").Set("Unique-ID", "second")); err != nil {
+		t.Fatal(err)
+	}
+	if rejected := waitSendMsg(t, server, "second", "hangup"); rejected.Headers["hangup-cause"] != esl.CONGESTION_CAUSE {
+		t.Fatalf("second session not rejected: %+v", rejected.Headers)
+	}
+}
+
+func TestOutboundRouterChangesAfterServe(t *testing.T) {
+	routed := make(chan string, 4)
+	router := esl.NewOutboundRouter()
+	support := router.Route("support", func(session *esl.OutboundSession) {
+		routed <- "support"
+	}).DestinationNumber(`^1\d{3}$`)
+	server, outbound := newOutboundServer(t, router.Serve)
+
+	dial := func(number string) {
+		if _, err := server.DialOutbound(outbound.Addr().String(),
+			esltest.NewEvent("CHANNEL_DATA").Set("Caller-Destination-Number", number)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dial("1234")
+	if got := <-routed; got != "support" {
+		t.Fatalf("routed to %s", got)
+	}
+
+	support.Use(func(next esl.OutboundHandler) esl.OutboundHandler {
+		return func(session *esl.OutboundSession) {
+			routed <- "middleware"
+			next(session)
+		}
+	})
+	router.Route("sales", func(session *esl.OutboundSession) {
+		routed <- "sales"
+	}).DestinationNumber(`^2\d{3}$`)
+	dial("1234")
+	if got := <-routed; got != "middleware" {
+		t.Fatalf("middleware added after Serve not run, got %s", got)
+	}
+	if got := <-routed; got != "support" {
+		t.Fatalf("routed to %s", got)
+	}
+	dial("2345")
+	if got := <-routed; got != "sales" {
+		t.Fatalf("route added after Serve not matched, got %s", got)
+	}
+}
@@ -0,0 +1,88 @@
+package esl_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bytedance/gopkg/util/logger"
+	"github.com/zhouhailin/freeswitch-esl-go/esl"
+	"github.com/zhouhailin/freeswitch-esl-go/esl/esltest"
+)
+
+// newOutboundServer - A fake switch and an outbound server serving handler, both closed at the end of the test.
+func newOutboundServer(t *testing.T, handler esl.OutboundHandler) (*esltest.Server, *esl.OutboundServer) {
+	t.Helper()
+	// NewClient sets the options of the package, the outbound sessions use them too
+	esl.NewClient("127.0.0.1", 0, "", 1, &esl.Options{Level: logger.LevelWarn})
+	server := esltest.NewServer(testPassword)
+	t.Cleanup(server.Close)
+	outbound := esl.NewOutboundServer("127.0.0.1:0", handler)
+	if err := outbound.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = outbound.Shutdown(ctx)
+	})
+	return server, outbound
+}
+
+func TestOutboundSession(t *testing.T) {
+	type result struct {
+		uuid     string
+		number   string
+		response string
+		err      error
+	}
+	results := make(chan result, 1)
+	server, outbound := newOutboundServer(t, func(session *esl.OutboundSession) {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		response, err := session.Playback(ctx, "/tmp/welcome.wav")
+		results <- result{session.GetUuid(), session.GetChannelData()["Caller-Destination-Number"], response, err}
+		_ = session.Hangup("NORMAL_CLEARING")
+	})
+	completeExecutions(server, nil)
+	channel := esltest.NewEvent("CHANNEL_DATA").Set("Unique-ID", "c1").Set("Caller-Destination-Number", "1000")
+	if _, err := server.DialOutbound(outbound.Addr().String(), channel); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case r := <-results:
+		if r.err != nil || r.uuid != "c1" || r.number != "1000" || r.response != "_none_" {
+			t.Fatalf("unexpected result %+v", r)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler not called")
+	}
+	waitCommand(t, server, "myevents")
+	if hangup := waitSendMsg(t, server, "c1", "hangup"); hangup.Headers["hangup-cause"] != "NORMAL_CLEARING" {
+		t.Fatalf("hangup not sent: %+v", hangup.Headers)
+	}
+}
+
+func TestOutboundSessionDisconnectNotice(t *testing.T) {
+	disconnected := make(chan struct{})
+	server, outbound := newOutboundServer(t, func(session *esl.OutboundSession) {
+		select {
+		case <-session.Disconnected():
+			close(disconnected)
+		case <-time.After(2 * time.Second):
+		}
+	})
+	conn, err := server.DialOutbound(outbound.Addr().String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitCommand(t, server, "connect")
+	if err := conn.SendOutboundDisconnectNotice(false); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-disconnected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("disconnect notice not seen")
+	}
+}
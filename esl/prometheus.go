@@ -0,0 +1,218 @@
+package esl
+
+import (
+	"bytes"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PROMETHEUS_LATENCY_BUCKETS - The upper bounds in seconds of the command latency histogram.
+var PROMETHEUS_LATENCY_BUCKETS = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// PrometheusMetrics - Metrics kept in memory and exposed in the Prometheus text format, it is an http.Handler.
+//   - <pre>
+//   - metrics := esl.NewPrometheusMetrics("esl")
+//   - options := esl.Options{AutoReconnection: true, ReconnectIntervalSeconds: 5, Metrics: metrics}
+//   - client := esl.NewClient(host, port, password, 10, &options)
+//   - http.Handle("/metrics", metrics)
+//   - </pre>
+type PrometheusMetrics struct {
+	namespace        string
+	mtx              sync.Mutex
+	commands         map[string]float64
+	commandErrors    map[string]float64
+	commandLatencies map[string]*latencyHistogram
+	inFlight         float64
+	messages         map[string]float64
+	events           map[string]float64
+	droppedEvents    map[string]float64
+	queueDepths      map[string]float64
+	reconnects       float64
+	authFailures     float64
+}
+
+type latencyHistogram struct {
+	buckets []float64
+	count   float64
+	sum     float64
+}
+
+// NewPrometheusMetrics - Constructor.
+//   - @param namespace the prefix of the metric names, esl when empty
+func NewPrometheusMetrics(namespace string) *PrometheusMetrics {
+	if namespace == "" {
+		namespace = "esl"
+	}
+	return &PrometheusMetrics{
+		namespace:        namespace,
+		commands:         make(map[string]float64),
+		commandErrors:    make(map[string]float64),
+		commandLatencies: make(map[string]*latencyHistogram),
+		messages:         make(map[string]float64),
+		events:           make(map[string]float64),
+		droppedEvents:    make(map[string]float64),
+		queueDepths:      make(map[string]float64),
+	}
+}
+
+// CommandSent - Implements Metrics.
+func (p *PrometheusMetrics) CommandSent(command string) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.commands[command]++
+	p.inFlight++
+}
+
+// CommandCompleted - Implements Metrics.
+func (p *PrometheusMetrics) CommandCompleted(command string, latency time.Duration, err error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.inFlight--
+	if err != nil {
+		p.commandErrors[command]++
+	}
+	histogram, ok := p.commandLatencies[command]
+	if !ok {
+		histogram = &latencyHistogram{buckets: make([]float64, len(PROMETHEUS_LATENCY_BUCKETS))}
+		p.commandLatencies[command] = histogram
+	}
+	seconds := latency.Seconds()
+	for i, bound := range PROMETHEUS_LATENCY_BUCKETS {
+		if seconds <= bound {
+			histogram.buckets[i]++
+		}
+	}
+	histogram.count++
+	histogram.sum += seconds
+}
+
+// MessageReceived - Implements Metrics.
+func (p *PrometheusMetrics) MessageReceived(contentType string) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.messages[contentType]++
+}
+
+// EventReceived - Implements Metrics.
+func (p *PrometheusMetrics) EventReceived(name string) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.events[name]++
+}
+
+// EventDropped - Implements Metrics.
+func (p *PrometheusMetrics) EventDropped(name string) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.droppedEvents[name]++
+}
+
+// EventQueueDepth - Implements Metrics.
+func (p *PrometheusMetrics) EventQueueDepth(queue string, depth int) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.queueDepths[queue] = float64(depth)
+}
+
+// Reconnecting - Implements Metrics.
+func (p *PrometheusMetrics) Reconnecting() {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.reconnects++
+}
+
+// AuthFailed - Implements Metrics.
+func (p *PrometheusMetrics) AuthFailed() {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.authFailures++
+}
+
+// ServeHTTP - Implements http.Handler, writes the metrics in the Prometheus text format.
+func (p *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write(p.Export())
+}
+
+// Export - The metrics in the Prometheus text format.
+func (p *PrometheusMetrics) Export() []byte {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	var buf bytes.Buffer
+	p.writeFamily(&buf, "commands_total", "counter", "Commands sent.", "command", p.commands)
+	p.writeFamily(&buf, "command_errors_total", "counter", "Commands whose reply was not received.", "command",
+		p.commandErrors)
+	p.writeHistograms(&buf)
+	p.writeFamily(&buf, "commands_in_flight", "gauge", "Commands waiting for their reply.", "",
+		map[string]float64{"": p.inFlight})
+	p.writeFamily(&buf, "messages_received_total", "counter", "Messages received by content type.", "content_type",
+		p.messages)
+	p.writeFamily(&buf, "events_received_total", "counter", "Events received by name.", "event", p.events)
+	p.writeFamily(&buf, "events_dropped_total", "counter", "Events dropped because the queue was full.", "event",
+		p.droppedEvents)
+	p.writeFamily(&buf, "event_queue_depth", "gauge", "Events waiting for the listeners.", "queue", p.queueDepths)
+	p.writeFamily(&buf, "reconnects_total", "counter", "Reconnection attempts.", "",
+		map[string]float64{"": p.reconnects})
+	p.writeFamily(&buf, "auth_failures_total", "counter", "Rejected authentications.", "",
+		map[string]float64{"": p.authFailures})
+	return buf.Bytes()
+}
+
+// writeFamily - Write a metric family, the samples are sorted by label value, label is empty for a single sample.
+func (p *PrometheusMetrics) writeFamily(buf *bytes.Buffer, name, metricType, help, label string,
+	samples map[string]float64) {
+	name = p.namespace + "_" + name
+	buf.WriteString("# HELP " + name + " " + help + "\n")
+	buf.WriteString("# TYPE " + name + " " + metricType + "\n")
+	for _, value := range sortedKeys(samples) {
+		buf.WriteString(name)
+		if label != "" {
+			buf.WriteString("{" + label + "=\"" + escapeLabelValue(value) + "\"}")
+		}
+		buf.WriteString(" " + formatSample(samples[value]) + "\n")
+	}
+}
+
+func (p *PrometheusMetrics) writeHistograms(buf *bytes.Buffer) {
+	name := p.namespace + "_command_duration_seconds"
+	buf.WriteString("# HELP " + name + " Latency of the command replies.\n")
+	buf.WriteString("# TYPE " + name + " histogram\n")
+	commands := make([]string, 0, len(p.commandLatencies))
+	for command := range p.commandLatencies {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+	for _, command := range commands {
+		histogram := p.commandLatencies[command]
+		label := "command=\"" + escapeLabelValue(command) + "\""
+		for i, bound := range PROMETHEUS_LATENCY_BUCKETS {
+			buf.WriteString(name + "_bucket{" + label + ",le=\"" + formatSample(bound) + "\"} " +
+				formatSample(histogram.buckets[i]) + "\n")
+		}
+		buf.WriteString(name + "_bucket{" + label + ",le=\"+Inf\"} " + formatSample(histogram.count) + "\n")
+		buf.WriteString(name + "_sum{" + label + "} " + formatSample(histogram.sum) + "\n")
+		buf.WriteString(name + "_count{" + label + "} " + formatSample(histogram.count) + "\n")
+	}
+}
+
+func sortedKeys(samples map[string]float64) []string {
+	keys := make([]string, 0, len(samples))
+	for key := range samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatSample(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// escapeLabelValue - Escape the backslashes, double quotes and line feeds of a label value.
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n").Replace(value)
+}
@@ -0,0 +1,146 @@
+package esl_test
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zhouhailin/freeswitch-esl-go/esl"
+)
+
+const prometheusExport = `# HELP esl_commands_total Commands sent.
+# TYPE esl_commands_total counter
+esl_commands_total{command="api"} 2
+esl_commands_total{command="bgapi"} 2
+# HELP esl_command_errors_total Commands whose reply was not received.
+# TYPE esl_command_errors_total counter
+esl_command_errors_total{command="api"} 1
+# HELP esl_command_duration_seconds Latency of the command replies.
+# TYPE esl_command_duration_seconds histogram
+esl_command_duration_seconds_bucket{command="api",le="0.001"} 0
+esl_command_duration_seconds_bucket{command="api",le="0.005"} 1
+esl_command_duration_seconds_bucket{command="api",le="0.01"} 1
+esl_command_duration_seconds_bucket{command="api",le="0.025"} 1
+esl_command_duration_seconds_bucket{command="api",le="0.05"} 1
+esl_command_duration_seconds_bucket{command="api",le="0.1"} 1
+esl_command_duration_seconds_bucket{command="api",le="0.25"} 1
+esl_command_duration_seconds_bucket{command="api",le="0.5"} 1
+esl_command_duration_seconds_bucket{command="api",le="1"} 1
+esl_command_duration_seconds_bucket{command="api",le="2.5"} 2
+esl_command_duration_seconds_bucket{command="api",le="5"} 2
+esl_command_duration_seconds_bucket{command="api",le="10"} 2
+esl_command_duration_seconds_bucket{command="api",le="+Inf"} 2
+esl_command_duration_seconds_sum{command="api"} 2.003
+esl_command_duration_seconds_count{command="api"} 2
+esl_command_duration_seconds_bucket{command="bgapi",le="0.001"} 0
+esl_command_duration_seconds_bucket{command="bgapi",le="0.005"} 0
+esl_command_duration_seconds_bucket{command="bgapi",le="0.01"} 0
+esl_command_duration_seconds_bucket{command="bgapi",le="0.025"} 0
+esl_command_duration_seconds_bucket{command="bgapi",le="0.05"} 0
+esl_command_duration_seconds_bucket{command="bgapi",le="0.1"} 0
+esl_command_duration_seconds_bucket{command="bgapi",le="0.25"} 0
+esl_command_duration_seconds_bucket{command="bgapi",le="0.5"} 0
+esl_command_duration_seconds_bucket{command="bgapi",le="1"} 0
+esl_command_duration_seconds_bucket{command="bgapi",le="2.5"} 0
+esl_command_duration_seconds_bucket{command="bgapi",le="5"} 0
+esl_command_duration_seconds_bucket{command="bgapi",le="10"} 0
+esl_command_duration_seconds_bucket{command="bgapi",le="+Inf"} 1
+esl_command_duration_seconds_sum{command="bgapi"} 30
+esl_command_duration_seconds_count{command="bgapi"} 1
+# HELP esl_commands_in_flight Commands waiting for their reply.
+# TYPE esl_commands_in_flight gauge
+esl_commands_in_flight 1
+# HELP esl_messages_received_total Messages received by content type.
+# TYPE esl_messages_received_total counter
+esl_messages_received_total{content_type="api/response"} 1
+esl_messages_received_total{content_type="text/event-plain"} 2
+# HELP esl_events_received_total Events received by name.
+# TYPE esl_events_received_total counter
+esl_events_received_total{event="CHANNEL_CREATE"} 1
+esl_events_received_total{event="my \"quoted\" C:\\path\nnext"} 1
+# HELP esl_events_dropped_total Events dropped because the queue was full.
+# TYPE esl_events_dropped_total counter
+esl_events_dropped_total{event="HEARTBEAT"} 1
+# HELP esl_event_queue_depth Events waiting for the listeners.
+# TYPE esl_event_queue_depth gauge
+esl_event_queue_depth{queue="events"} 3
+esl_event_queue_depth{queue="jobs"} 0
+# HELP esl_reconnects_total Reconnection attempts.
+# TYPE esl_reconnects_total counter
+esl_reconnects_total 1
+# HELP esl_auth_failures_total Rejected authentications.
+# TYPE esl_auth_failures_total counter
+esl_auth_failures_total 2
+`
+
+func newPrometheusMetrics() *esl.PrometheusMetrics {
+	metrics := esl.NewPrometheusMetrics("")
+	metrics.CommandSent("api")
+	metrics.CommandCompleted("api", 3*time.Millisecond, nil)
+	metrics.CommandSent("api")
+	metrics.CommandCompleted("api", 2*time.Second, errors.New("timeout"))
+	metrics.CommandSent("bgapi")
+	metrics.CommandCompleted("bgapi", 30*time.Second, nil)
+	metrics.CommandSent("bgapi")
+	metrics.MessageReceived("text/event-plain")
+	metrics.MessageReceived("text/event-plain")
+	metrics.MessageReceived("api/response")
+	metrics.EventReceived("CHANNEL_CREATE")
+	metrics.EventReceived("my \"quoted\" C:\\path\nnext")
+	metrics.EventDropped("HEARTBEAT")
+	metrics.EventQueueDepth("events", 5)
+	metrics.EventQueueDepth("events", 3)
+	metrics.EventQueueDepth("jobs", 0)
+	metrics.Reconnecting()
+	metrics.AuthFailed()
+	metrics.AuthFailed()
+	return metrics
+}
+
+func TestPrometheusExport(t *testing.T) {
+	export := string(newPrometheusMetrics().Export())
+	if export != prometheusExport {
+		want, got := strings.Split(prometheusExport, "\n"), strings.Split(export, "\n")
+		for i := 0; i < len(want) && i < len(got); i++ {
+			if want[i] != got[i] {
+				t.Fatalf("line %d:\n got %s\nwant %s", i+1, got[i], want[i])
+			}
+		}
+		t.Fatalf("%d lines, want %d:\n%s", len(got), len(want), export)
+	}
+}
+
+func TestPrometheusNamespace(t *testing.T) {
+	metrics := esl.NewPrometheusMetrics("pbx")
+	metrics.Reconnecting()
+	if export := string(metrics.Export()); !strings.Contains(export, "\npbx_reconnects_total 1\n") ||
+		strings.Contains(export, "esl_") {
+		t.Fatalf("export %s", export)
+	}
+}
+
+func TestPrometheusServeHTTP(t *testing.T) {
+	metrics := newPrometheusMetrics()
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if recorder.Code != 200 {
+		t.Fatalf("status %d", recorder.Code)
+	}
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("content type %q", contentType)
+	}
+	if body := recorder.Body.String(); body != prometheusExport {
+		t.Errorf("body %s", body)
+	}
+}
+
+func TestOutboundServerInstruments(t *testing.T) {
+	// the metrics of a client are not shared with the outbound servers created afterwards
+	newTestClient(t, &esl.Options{Metrics: esl.NewPrometheusMetrics("")})
+	outbound := esl.NewOutboundServer("127.0.0.1:0", func(session *esl.OutboundSession) {})
+	if outbound.Metrics != nil || outbound.Tracer != nil || outbound.TraceRedactor != nil {
+		t.Fatalf("instruments %+v", outbound)
+	}
+}
@@ -0,0 +1,251 @@
+package esl
+
+import (
+	"github.com/bytedance/gopkg/util/logger"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// QUALITY_EVENTS - the events a QualityMonitor subscribes to
+const QUALITY_EVENTS = "CHANNEL_HANGUP_COMPLETE"
+
+// QualityReport - The RTP statistics of a leg, read from the rtp_audio_* variables of its CHANNEL_HANGUP_COMPLETE.
+type QualityReport struct {
+	Uuid              string
+	CallUuid          string
+	OtherLegUuid      string
+	Direction         string
+	CallerIdNumber    string
+	DestinationNumber string
+	HangupCause       string
+	ReadCodec         string
+	WriteCodec        string
+	// HasMedia - false when the leg exchanged no RTP, the other figures are then 0
+	HasMedia bool
+	// Mos - rtp_audio_in_mos, the estimated mean opinion score of the received audio, from 1 to 5
+	Mos float64
+	// QualityPercentage - rtp_audio_in_quality_percentage
+	QualityPercentage float64
+	// FlawTotal - rtp_audio_in_flaw_total, the number of lost or late packets accounted by the quality estimation
+	FlawTotal int64
+	// JitterMinVariance, JitterMaxVariance - rtp_audio_in_jitter_min_variance and max_variance, in milliseconds
+	JitterMinVariance float64
+	JitterMaxVariance float64
+	// JitterLossRate, JitterBurstRate - rtp_audio_in_jitter_loss_rate and burst_rate
+	JitterLossRate  float64
+	JitterBurstRate float64
+	// MeanInterval - rtp_audio_in_mean_interval, the mean interval between received packets in milliseconds
+	MeanInterval float64
+	InBytes      int64
+	InPackets    int64
+	// InMediaPackets - the received packets carrying audio, without DTMF and comfort noise
+	InMediaPackets int64
+	// SkipPackets - rtp_audio_in_skip_packet_count, the packets missing from the received sequence
+	SkipPackets int64
+	// PacketLoss - SkipPackets in percent of the expected media packets
+	PacketLoss float64
+	OutBytes   int64
+	OutPackets int64
+	// Variables - every rtp_audio_* variable, without the variable_ prefix
+	Variables map[string]string
+}
+
+// NewQualityReport - Build the report of a CHANNEL_HANGUP_COMPLETE event.
+func NewQualityReport(event *EslEvent) *QualityReport {
+	headers := *event.GetEventHeaders()
+	report := &QualityReport{
+		Uuid:              headers["Unique-ID"],
+		CallUuid:          headers["variable_call_uuid"],
+		OtherLegUuid:      headers["Other-Leg-Unique-ID"],
+		Direction:         headers["Call-Direction"],
+		CallerIdNumber:    headers["Caller-Caller-ID-Number"],
+		DestinationNumber: headers["Caller-Destination-Number"],
+		HangupCause:       headers["Hangup-Cause"],
+		ReadCodec:         headers["variable_read_codec"],
+		WriteCodec:        headers["variable_write_codec"],
+		Variables:         make(map[string]string),
+	}
+	for name, value := range headers {
+		if strings.HasPrefix(name, "variable_rtp_audio_") {
+			report.Variables[strings.TrimPrefix(name, "variable_")] = value
+		}
+	}
+	variables := report.Variables
+	report.Mos = parseQualityFloat(variables["rtp_audio_in_mos"])
+	report.QualityPercentage = parseQualityFloat(variables["rtp_audio_in_quality_percentage"])
+	report.FlawTotal = parseQualityInt(variables["rtp_audio_in_flaw_total"])
+	report.JitterMinVariance = parseQualityFloat(variables["rtp_audio_in_jitter_min_variance"])
+	report.JitterMaxVariance = parseQualityFloat(variables["rtp_audio_in_jitter_max_variance"])
+	report.JitterLossRate = parseQualityFloat(variables["rtp_audio_in_jitter_loss_rate"])
+	report.JitterBurstRate = parseQualityFloat(variables["rtp_audio_in_jitter_burst_rate"])
+	report.MeanInterval = parseQualityFloat(variables["rtp_audio_in_mean_interval"])
+	report.InBytes = parseQualityInt(variables["rtp_audio_in_raw_bytes"])
+	report.InPackets = parseQualityInt(variables["rtp_audio_in_packet_count"])
+	report.InMediaPackets = parseQualityInt(variables["rtp_audio_in_media_packet_count"])
+	report.SkipPackets = parseQualityInt(variables["rtp_audio_in_skip_packet_count"])
+	report.OutBytes = parseQualityInt(variables["rtp_audio_out_raw_bytes"])
+	report.OutPackets = parseQualityInt(variables["rtp_audio_out_packet_count"])
+	if expected := report.InMediaPackets + report.SkipPackets; expected > 0 {
+		report.PacketLoss = float64(report.SkipPackets) * 100 / float64(expected)
+	}
+	report.HasMedia = report.InPackets > 0 || report.OutPackets > 0
+	return report
+}
+
+func parseQualityFloat(value string) float64 {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
+func parseQualityInt(value string) int64 {
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return i
+}
+
+// QualityRule - A threshold checked against the reports of the legs with media.
+type QualityRule struct {
+	Name string
+	// Violated - true when the report is poor according to the rule
+	Violated func(report *QualityReport) bool
+}
+
+// MosBelow - Violated when the MOS is below min, legs without a MOS are ignored.
+func MosBelow(min float64) QualityRule {
+	return QualityRule{Name: "mos<" + strconv.FormatFloat(min, 'f', -1, 64), Violated: func(report *QualityReport) bool {
+		return report.Mos > 0 && report.Mos < min
+	}}
+}
+
+// PacketLossAbove - Violated when the packet loss exceeds max percent.
+func PacketLossAbove(max float64) QualityRule {
+	return QualityRule{Name: "loss>" + strconv.FormatFloat(max, 'f', -1, 64) + "%", Violated: func(report *QualityReport) bool {
+		return report.PacketLoss > max
+	}}
+}
+
+// JitterAbove - Violated when the max jitter variance exceeds max milliseconds.
+func JitterAbove(max float64) QualityRule {
+	return QualityRule{Name: "jitter>" + strconv.FormatFloat(max, 'f', -1, 64) + "ms", Violated: func(report *QualityReport) bool {
+		return report.JitterMaxVariance > max
+	}}
+}
+
+// FlawsAbove - Violated when rtp_audio_in_flaw_total exceeds max.
+func FlawsAbove(max int64) QualityRule {
+	return QualityRule{Name: "flaws>" + strconv.FormatInt(max, 10), Violated: func(report *QualityReport) bool {
+		return report.FlawTotal > max
+	}}
+}
+
+// DefaultQualityRules - MOS below 3.5, more than 3% packet loss or more than 50ms of jitter.
+func DefaultQualityRules() []QualityRule {
+	return []QualityRule{MosBelow(3.5), PacketLossAbove(3), JitterAbove(50)}
+}
+
+// QualityAlert - A report violating at least one rule.
+type QualityAlert struct {
+	Report *QualityReport
+	// Rules - the names of the violated rules
+	Rules []string
+}
+
+// CheckQuality - Check the report against the rules, legs without media are never poor.
+//   - @return the alert, nil when no rule is violated
+func CheckQuality(report *QualityReport, rules []QualityRule) *QualityAlert {
+	if !report.HasMedia {
+		return nil
+	}
+	var violated []string
+	for _, rule := range rules {
+		if rule.Violated(report) {
+			violated = append(violated, rule.Name)
+		}
+	}
+	if len(violated) == 0 {
+		return nil
+	}
+	return &QualityAlert{Report: report, Rules: violated}
+}
+
+// QualityMonitor - Builds a QualityReport for every CHANNEL_HANGUP_COMPLETE event of a Client and raises alerts for
+// the poor legs.
+//   - The callbacks are called from the event dispatch goroutine.
+type QualityMonitor struct {
+	listenerBase
+	client          *Client
+	mtx             sync.RWMutex
+	rules           []QualityRule
+	reportCallbacks []func(report *QualityReport)
+	alertCallbacks  []func(alert *QualityAlert)
+}
+
+// NewQualityMonitor - Constructor, registers the monitor as event and connection listener of client.
+//   - @param rules the thresholds, DefaultQualityRules() when none is given
+func NewQualityMonitor(client *Client, rules ...QualityRule) *QualityMonitor {
+	if len(rules) == 0 {
+		rules = DefaultQualityRules()
+	}
+	m := &QualityMonitor{client: client, rules: rules}
+	client.AddEventListener(m)
+	client.AddConnectionListener(m)
+	return m
+}
+
+// OnReport - Register a callback receiving the report of every leg.
+func (m *QualityMonitor) OnReport(callback func(report *QualityReport)) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.reportCallbacks = append(m.reportCallbacks, callback)
+}
+
+// OnAlert - Register a callback receiving the alerts of the poor legs.
+func (m *QualityMonitor) OnAlert(callback func(alert *QualityAlert)) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.alertCallbacks = append(m.alertCallbacks, callback)
+}
+
+// Sync - Subscribe to QUALITY_EVENTS.
+func (m *QualityMonitor) Sync() error {
+	return subscribe(m.client, QUALITY_EVENTS, "Quality")
+}
+
+// EventReceived - Implements IEslEventListener.
+func (m *QualityMonitor) EventReceived(event *EslEvent) error {
+	if event.GetEventName() != "CHANNEL_HANGUP_COMPLETE" {
+		return nil
+	}
+	report := NewQualityReport(event)
+	m.mtx.RLock()
+	alert := CheckQuality(report, m.rules)
+	reportCallbacks, alertCallbacks := m.reportCallbacks, m.alertCallbacks
+	m.mtx.RUnlock()
+	for _, callback := range reportCallbacks {
+		callback(report)
+	}
+	if alert == nil {
+		return nil
+	}
+	logger.Warnf("Poor quality on channel %s: %s\n", report.Uuid, strings.Join(alert.Rules, ", "))
+	for _, callback := range alertCallbacks {
+		callback(alert)
+	}
+	return nil
+}
+
+// Authenticated - Implements IEslConnectionListener, subscribes to QUALITY_EVENTS.
+func (m *QualityMonitor) Authenticated(authenticated bool, c *Client) {
+	if !authenticated {
+		return
+	}
+	if err := m.Sync(); err != nil {
+		logger.Errorf("Quality monitor subscription failure, cause %v\n", err)
+	}
+}
@@ -0,0 +1,142 @@
+package esl_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zhouhailin/freeswitch-esl-go/esl"
+	"github.com/zhouhailin/freeswitch-esl-go/esl/esltest"
+)
+
+// hangupComplete - A CHANNEL_HANGUP_COMPLETE of an answered G.711 leg, with the rtp_audio_* variables FreeSWITCH sets.
+func hangupComplete(uuid string) *esltest.Event {
+	return esltest.NewEvent("CHANNEL_HANGUP_COMPLETE").Set("Unique-ID", uuid).
+		Set("Other-Leg-Unique-ID", "b-"+uuid).Set("Call-Direction", "inbound").
+		Set("Caller-Caller-ID-Number", "1000").Set("Caller-Destination-Number", "2000").
+		Set("Hangup-Cause", "NORMAL_CLEARING").Set("variable_call_uuid", uuid).
+		Set("variable_read_codec", "PCMU").Set("variable_write_codec", "PCMU").
+		Set("variable_rtp_audio_in_raw_bytes", "1538368").
+		Set("variable_rtp_audio_in_media_bytes", "1538368").
+		Set("variable_rtp_audio_in_packet_count", "8944").
+		Set("variable_rtp_audio_in_media_packet_count", "8944").
+		Set("variable_rtp_audio_in_skip_packet_count", "56").
+		Set("variable_rtp_audio_in_jitter_packet_count", "0").
+		Set("variable_rtp_audio_in_dtmf_packet_count", "0").
+		Set("variable_rtp_audio_in_cng_packet_count", "0").
+		Set("variable_rtp_audio_in_flush_packet_count", "0").
+		Set("variable_rtp_audio_in_largest_jb_size", "0").
+		Set("variable_rtp_audio_in_jitter_min_variance", "0.02").
+		Set("variable_rtp_audio_in_jitter_max_variance", "12.35").
+		Set("variable_rtp_audio_in_jitter_loss_rate", "0.00").
+		Set("variable_rtp_audio_in_jitter_burst_rate", "0.00").
+		Set("variable_rtp_audio_in_mean_interval", "20.01").
+		Set("variable_rtp_audio_in_flaw_total", "3").
+		Set("variable_rtp_audio_in_quality_percentage", "97.00").
+		Set("variable_rtp_audio_in_mos", "4.45").
+		Set("variable_rtp_audio_out_raw_bytes", "1547920").
+		Set("variable_rtp_audio_out_media_bytes", "1547920").
+		Set("variable_rtp_audio_out_packet_count", "9000").
+		Set("variable_rtp_audio_out_media_packet_count", "9000")
+}
+
+// newQualityMonitor - A monitor of a connected client, its reports and alerts are sent to the returned channels.
+func newQualityMonitor(t *testing.T, rules ...esl.QualityRule) (*esltest.Conn, chan *esl.QualityReport,
+	chan *esl.QualityAlert) {
+	t.Helper()
+	server, client := newTestClient(t, nil)
+	monitor := esl.NewQualityMonitor(client, rules...)
+	reports, alerts := make(chan *esl.QualityReport, 4), make(chan *esl.QualityAlert, 4)
+	monitor.OnReport(func(report *esl.QualityReport) {
+		reports <- report
+	})
+	monitor.OnAlert(func(alert *esl.QualityAlert) {
+		alerts <- alert
+	})
+	conn := connect(t, server, client)
+	waitCommand(t, server, "event plain "+esl.QUALITY_EVENTS)
+	return conn, reports, alerts
+}
+
+func TestQualityReport(t *testing.T) {
+	conn, reports, alerts := newQualityMonitor(t)
+	conn.SendEvent(hangupComplete("a"))
+
+	report := <-reports
+	if report.Uuid != "a" || report.OtherLegUuid != "b-a" || report.CallUuid != "a" ||
+		report.Direction != "inbound" || report.CallerIdNumber != "1000" || report.DestinationNumber != "2000" ||
+		report.HangupCause != "NORMAL_CLEARING" || report.ReadCodec != "PCMU" || report.WriteCodec != "PCMU" {
+		t.Errorf("leg %+v", report)
+	}
+	if !report.HasMedia || report.Mos != 4.45 || report.QualityPercentage != 97 || report.FlawTotal != 3 ||
+		report.JitterMinVariance != 0.02 || report.JitterMaxVariance != 12.35 || report.MeanInterval != 20.01 ||
+		report.InBytes != 1538368 || report.InPackets != 8944 || report.InMediaPackets != 8944 ||
+		report.SkipPackets != 56 || report.OutBytes != 1547920 || report.OutPackets != 9000 {
+		t.Errorf("statistics %+v", report)
+	}
+	// 56 skipped of the 9000 expected packets
+	if math.Abs(report.PacketLoss-0.6222) > 0.0001 {
+		t.Errorf("packet loss %v", report.PacketLoss)
+	}
+	if len(report.Variables) != 22 || report.Variables["rtp_audio_in_mos"] != "4.45" {
+		t.Errorf("variables %v", report.Variables)
+	}
+	select {
+	case alert := <-alerts:
+		t.Fatalf("alert for a good leg: %v", alert.Rules)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestQualityAlert(t *testing.T) {
+	conn, reports, alerts := newQualityMonitor(t, append(esl.DefaultQualityRules(), esl.FlawsAbove(10))...)
+	conn.SendEvent(hangupComplete("poor").Set("variable_rtp_audio_in_mos", "3.1").
+		Set("variable_rtp_audio_in_media_packet_count", "8400").
+		Set("variable_rtp_audio_in_skip_packet_count", "600").
+		Set("variable_rtp_audio_in_jitter_max_variance", "80.5").
+		Set("variable_rtp_audio_in_flaw_total", "10"))
+
+	report := <-reports
+	// 600 skipped of the 9000 expected packets
+	if math.Abs(report.PacketLoss-6.6667) > 0.0001 {
+		t.Errorf("packet loss %v", report.PacketLoss)
+	}
+	alert := <-alerts
+	if alert.Report != report {
+		t.Errorf("alert of another report")
+	}
+	// FlawsAbove is strict, 10 flaws do not exceed it
+	if want := []string{"mos<3.5", "loss>3%", "jitter>50ms"}; !reflect.DeepEqual(alert.Rules, want) {
+		t.Errorf("rules %v, want %v", alert.Rules, want)
+	}
+}
+
+func TestQualityWithoutMedia(t *testing.T) {
+	conn, reports, alerts := newQualityMonitor(t, esl.MosBelow(3.5), esl.PacketLossAbove(0))
+	conn.SendEvent(esltest.NewEvent("CHANNEL_HANGUP_COMPLETE").Set("Unique-ID", "unanswered").
+		Set("Hangup-Cause", "NO_ANSWER"))
+
+	report := <-reports
+	if report.HasMedia || report.Mos != 0 || report.PacketLoss != 0 || len(report.Variables) != 0 {
+		t.Errorf("report %+v", report)
+	}
+	select {
+	case alert := <-alerts:
+		t.Fatalf("alert for a leg without media: %v", alert.Rules)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestCheckQuality(t *testing.T) {
+	report := &esl.QualityReport{HasMedia: true, Mos: 0, PacketLoss: 3, JitterMaxVariance: 50, FlawTotal: 5}
+	// a missing MOS and values equal to the thresholds are not violations
+	if alert := esl.CheckQuality(report, append(esl.DefaultQualityRules(), esl.FlawsAbove(5))); alert != nil {
+		t.Fatalf("alert %v", alert.Rules)
+	}
+	report.PacketLoss, report.FlawTotal = 3.01, 6
+	alert := esl.CheckQuality(report, append(esl.DefaultQualityRules(), esl.FlawsAbove(5)))
+	if alert == nil || !reflect.DeepEqual(alert.Rules, []string{"loss>3%", "flaws>5"}) {
+		t.Fatalf("alert %+v", alert)
+	}
+}
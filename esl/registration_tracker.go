@@ -0,0 +1,276 @@
+package esl
+
+import (
+	"github.com/bytedance/gopkg/util/logger"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// REGISTRATION_TRACKER_EVENTS - the events a RegistrationTracker subscribes to
+const REGISTRATION_TRACKER_EVENTS = "CUSTOM sofia::register sofia::unregister sofia::expire"
+
+// RegistrationChangeType - What happened to a registered contact.
+type RegistrationChangeType int
+
+const (
+	REGISTRATION_ADDED RegistrationChangeType = iota
+	REGISTRATION_UPDATED
+	REGISTRATION_REMOVED
+)
+
+// RegistrationChange - A change of a registered contact, Event is nil when the change comes from a resynchronization
+// or from the expiry of the contact.
+type RegistrationChange struct {
+	Type         RegistrationChangeType
+	Registration *Registration
+	Event        *EslEvent
+}
+
+// Registration - One registered contact of a user, identified by the Call-ID of its REGISTER.
+type Registration struct {
+	CallId      string
+	User        string
+	Domain      string
+	Profile     string
+	Contact     string
+	Status      string
+	UserAgent   string
+	NetworkIp   string
+	NetworkPort string
+	ExpiresAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// IsExpired - Convenience method.
+//   - @return true if the registration expired at the given time
+func (r *Registration) IsExpired(now time.Time) bool {
+	return !r.ExpiresAt.IsZero() && !now.Before(r.ExpiresAt)
+}
+
+// applyRow - Merge a row of "show registrations as json".
+func (r *Registration) applyRow(row map[string]string, now time.Time) {
+	setIfPresent(&r.User, row["reg_user"])
+	setIfPresent(&r.Domain, row["realm"])
+	setIfPresent(&r.Contact, row["url"])
+	// url is sofia/<profile>/<contact>
+	if parts := strings.SplitN(row["url"], "/", 3); len(parts) == 3 && parts[0] == "sofia" {
+		r.Profile = parts[1]
+	}
+	setIfPresent(&r.NetworkIp, row["network_ip"])
+	setIfPresent(&r.NetworkPort, row["network_port"])
+	if expires, err := strconv.ParseInt(row["expires"], 10, 64); err == nil && expires > 0 {
+		r.ExpiresAt = time.Unix(expires, 0)
+	}
+	r.UpdatedAt = now
+}
+
+func (r *Registration) clone() *Registration {
+	clone := *r
+	return &clone
+}
+
+// RegistrationTracker - Maintains the registered contacts of a switch from the sofia CUSTOM events.
+//   - Contacts past their expiry are left out of the queries and removed on the next event or Sync.
+type RegistrationTracker struct {
+	listenerBase
+	client        *Client
+	mtx           sync.RWMutex
+	registrations map[string]*Registration
+	callbacks     []func(change *RegistrationChange)
+}
+
+// NewRegistrationTracker - Constructor, registers the tracker as event and connection listener of client.
+func NewRegistrationTracker(client *Client) *RegistrationTracker {
+	t := &RegistrationTracker{
+		client:        client,
+		registrations: make(map[string]*Registration),
+	}
+	client.AddEventListener(t)
+	client.AddConnectionListener(t)
+	return t
+}
+
+// Get - The registered contacts of user@domain.
+func (t *RegistrationTracker) Get(user, domain string) []*Registration {
+	return t.filter(func(r *Registration) bool {
+		return r.User == user && r.Domain == domain
+	})
+}
+
+// IsRegistered - Convenience method.
+//   - @return true if user@domain has at least one registered contact
+func (t *RegistrationTracker) IsRegistered(user, domain string) bool {
+	return len(t.Get(user, domain)) > 0
+}
+
+// ListByDomain - The registered contacts of a domain.
+func (t *RegistrationTracker) ListByDomain(domain string) []*Registration {
+	return t.filter(func(r *Registration) bool {
+		return r.Domain == domain
+	})
+}
+
+// ListByProfile - The registered contacts of a sofia profile.
+func (t *RegistrationTracker) ListByProfile(profile string) []*Registration {
+	return t.filter(func(r *Registration) bool {
+		return r.Profile == profile
+	})
+}
+
+// List - Every registered contact.
+func (t *RegistrationTracker) List() []*Registration {
+	return t.filter(func(r *Registration) bool {
+		return true
+	})
+}
+
+// Count - The number of registered contacts.
+func (t *RegistrationTracker) Count() int {
+	return len(t.List())
+}
+
+func (t *RegistrationTracker) filter(accept func(r *Registration) bool) []*Registration {
+	now := time.Now()
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	var registrations []*Registration
+	for _, r := range t.registrations {
+		if !r.IsExpired(now) && accept(r) {
+			registrations = append(registrations, r.clone())
+		}
+	}
+	return registrations
+}
+
+// OnChange - Register a callback, it is called from the event dispatch goroutine in the order of the changes.
+func (t *RegistrationTracker) OnChange(callback func(change *RegistrationChange)) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.callbacks = append(t.callbacks, callback)
+}
+
+// Sync - Subscribe to the sofia events and replace the registered contacts with "show registrations as json".
+func (t *RegistrationTracker) Sync() error {
+	if err := subscribe(t.client, REGISTRATION_TRACKER_EVENTS, "Registration"); err != nil {
+		return err
+	}
+	message, err := t.client.SendSyncApiCommand("show", "registrations as json")
+	if err != nil {
+		return err
+	}
+	rows, err := parseShowJson(message.getBody())
+	if err != nil {
+		return err
+	}
+	var changes []*RegistrationChange
+	now := time.Now()
+	t.mtx.Lock()
+	live := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		callId := row["token"]
+		if callId == "" {
+			continue
+		}
+		live[callId] = true
+		r, ok := t.registrations[callId]
+		changeType := REGISTRATION_UPDATED
+		if !ok {
+			r = &Registration{CallId: callId}
+			t.registrations[callId] = r
+			changeType = REGISTRATION_ADDED
+		}
+		r.applyRow(row, now)
+		changes = append(changes, &RegistrationChange{Type: changeType, Registration: r.clone()})
+	}
+	for callId, r := range t.registrations {
+		if !live[callId] {
+			delete(t.registrations, callId)
+			changes = append(changes, &RegistrationChange{Type: REGISTRATION_REMOVED, Registration: r.clone()})
+		}
+	}
+	callbacks := t.callbacks
+	t.mtx.Unlock()
+	runRegistrationCallbacks(callbacks, changes)
+	return nil
+}
+
+// EventReceived - Implements IEslEventListener.
+func (t *RegistrationTracker) EventReceived(event *EslEvent) error {
+	headers := *event.GetEventHeaders()
+	subclass := headers["Event-Subclass"]
+	if event.GetEventName() != "CUSTOM" || !strings.HasPrefix(subclass, "sofia::") {
+		return nil
+	}
+	now := time.Now()
+	var changes []*RegistrationChange
+	t.mtx.Lock()
+	switch subclass {
+	case "sofia::register":
+		callId := headers["call-id"]
+		if callId == "" {
+			break
+		}
+		r, ok := t.registrations[callId]
+		changeType := REGISTRATION_UPDATED
+		if !ok {
+			r = &Registration{CallId: callId}
+			t.registrations[callId] = r
+			changeType = REGISTRATION_ADDED
+		}
+		setIfPresent(&r.User, headers["from-user"])
+		setIfPresent(&r.Domain, headers["from-host"])
+		setIfPresent(&r.Profile, headers["profile-name"])
+		setIfPresent(&r.Contact, headers["contact"])
+		setIfPresent(&r.Status, headers["status"])
+		setIfPresent(&r.UserAgent, headers["user-agent"])
+		setIfPresent(&r.NetworkIp, headers["network-ip"])
+		setIfPresent(&r.NetworkPort, headers["network-port"])
+		if expires, err := strconv.Atoi(headers["expires"]); err == nil && expires > 0 {
+			r.ExpiresAt = now.Add(time.Duration(expires) * time.Second)
+		}
+		r.UpdatedAt = now
+		changes = append(changes, &RegistrationChange{Type: changeType, Registration: r.clone(), Event: event})
+	case "sofia::unregister", "sofia::expire":
+		user, domain := headers["from-user"], headers["from-host"]
+		if subclass == "sofia::expire" {
+			user, domain = headers["user"], headers["host"]
+		}
+		for callId, r := range t.registrations {
+			if callId == headers["call-id"] ||
+				(headers["call-id"] == "" && r.User == user && r.Domain == domain && r.Contact == headers["contact"]) {
+				delete(t.registrations, callId)
+				changes = append(changes, &RegistrationChange{Type: REGISTRATION_REMOVED, Registration: r.clone(), Event: event})
+			}
+		}
+	}
+	for callId, r := range t.registrations {
+		if r.IsExpired(now) {
+			delete(t.registrations, callId)
+			changes = append(changes, &RegistrationChange{Type: REGISTRATION_REMOVED, Registration: r.clone()})
+		}
+	}
+	callbacks := t.callbacks
+	t.mtx.Unlock()
+	runRegistrationCallbacks(callbacks, changes)
+	return nil
+}
+
+func runRegistrationCallbacks(callbacks []func(change *RegistrationChange), changes []*RegistrationChange) {
+	for _, change := range changes {
+		for _, callback := range callbacks {
+			callback(change)
+		}
+	}
+}
+
+// Authenticated - Implements IEslConnectionListener, resynchronizes the registered contacts.
+func (t *RegistrationTracker) Authenticated(authenticated bool, c *Client) {
+	if !authenticated {
+		return
+	}
+	if err := t.Sync(); err != nil {
+		logger.Errorf("Registration tracker synchronization failure, cause %v\n", err)
+	}
+}
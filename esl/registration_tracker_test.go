@@ -0,0 +1,61 @@
+package esl_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/zhouhailin/freeswitch-esl-go/esl"
+	"github.com/zhouhailin/freeswitch-esl-go/esl/esltest"
+)
+
+func TestRegistrationTracker(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	server.SetApiResponse("show", `{"row_count":1,"rows":[{"reg_user":"1000","realm":"example.com",`+
+		`"token":"call-1","url":"sofia/internal/sip:1000@10.0.0.2:5060","expires":"0",`+
+		`"network_ip":"10.0.0.2","network_port":"5060"}]}`+"\n")
+	tracker := esl.NewRegistrationTracker(client)
+	var mtx sync.Mutex
+	var changes []esl.RegistrationChangeType
+	tracker.OnChange(func(change *esl.RegistrationChange) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		changes = append(changes, change.Type)
+	})
+	conn := connect(t, server, client)
+	eventually(t, "the initial synchronization", func() bool {
+		return tracker.IsRegistered("1000", "example.com")
+	})
+	if r := tracker.Get("1000", "example.com")[0]; r.Profile != "internal" || r.NetworkIp != "10.0.0.2" {
+		t.Errorf("registration %+v", r)
+	}
+
+	conn.SendEvent(esltest.NewCustomEvent("sofia::register").Set("call-id", "call-2").
+		Set("from-user", "1001").Set("from-host", "example.com").Set("profile-name", "internal").
+		Set("contact", "sip:1001@10.0.0.3").Set("expires", "3600"))
+	eventually(t, "the register event", func() bool {
+		return tracker.IsRegistered("1001", "example.com")
+	})
+	if count := len(tracker.ListByDomain("example.com")); count != 2 {
+		t.Errorf("%d registrations in example.com", count)
+	}
+
+	conn.SendEvent(esltest.NewCustomEvent("sofia::unregister").Set("call-id", "call-1").
+		Set("from-user", "1000").Set("from-host", "example.com"))
+	eventually(t, "the unregister event", func() bool {
+		return !tracker.IsRegistered("1000", "example.com")
+	})
+	if tracker.Count() != 1 {
+		t.Errorf("%d registrations", tracker.Count())
+	}
+	mtx.Lock()
+	defer mtx.Unlock()
+	want := []esl.RegistrationChangeType{esl.REGISTRATION_ADDED, esl.REGISTRATION_ADDED, esl.REGISTRATION_REMOVED}
+	if len(changes) != len(want) {
+		t.Fatalf("changes %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("changes %v, want %v", changes, want)
+		}
+	}
+}
@@ -1,12 +1,26 @@
 package esl
 
 import (
+	"errors"
 	"strconv"
+	"strings"
+)
+
+const (
+	CALL_COMMAND_EXECUTE = "execute"
+	CALL_COMMAND_HANGUP  = "hangup"
+	CALL_COMMAND_UNICAST = "unicast"
+	CALL_COMMAND_NOMEDIA = "nomedia"
+	CALL_COMMAND_XFEREXT = "xferext"
+
+	// SENDMSG_BODY_THRESHOLD - Longer execute-app-arg values are sent as the message body.
+	SENDMSG_BODY_THRESHOLD = 1024
 )
 
 type SendMsg struct {
 	lines   []string
 	hasUuid bool
+	body    string
 }
 
 // NewSendMsg - Constructor for use with the inbound client.
@@ -45,10 +59,30 @@ func (m *SendMsg) AddExecuteAppName(appName string) {
 //   - execute-app-arg: arg
 //   - </pre>
 //   - @param arg the string arg
+//   - Multi-line arguments and arguments longer than SENDMSG_BODY_THRESHOLD are sent as the message body instead.
 func (m *SendMsg) AddExecuteAppArg(arg string) {
+	if strings.ContainsAny(arg, "\r\n") || len(arg) > SENDMSG_BODY_THRESHOLD {
+		m.SetBody(arg)
+		return
+	}
 	m.lines = append(m.lines, "execute-app-arg: "+arg)
 }
 
+// SetBody - The message body, sent with the following lines:
+//   - <pre>
+//   - content-type: text/plain
+//   - content-length: length
+//   - </pre>
+//   - @param body FreeSWITCH uses it as the application argument when there is no execute-app-arg line
+func (m *SendMsg) SetBody(body string) {
+	m.body = body
+}
+
+// GetBody - The message body, empty when there is none.
+func (m *SendMsg) GetBody() string {
+	return m.body
+}
+
 // AddLoops - Adds the following line to the message:
 //   - <pre>
 //   - loops: count
@@ -107,10 +141,151 @@ func (m *SendMsg) HasUuid() bool {
 	return m.hasUuid
 }
 
-// ToString - To String.
+// GetHeader - The value of the first line named name, empty when there is none.
+func (m *SendMsg) GetHeader(name string) string {
+	if len(m.lines) < 2 {
+		return ""
+	}
+	for _, line := range m.lines[1:] {
+		if strings.HasPrefix(strings.ToLower(line), strings.ToLower(name)+": ") {
+			return line[len(name)+2:]
+		}
+	}
+	return ""
+}
+
+// Validate - Check the lines required by the call-command are present.
+func (m *SendMsg) Validate() error {
+	if len(m.lines) == 0 {
+		return errors.New("sendmsg requires a uuid line")
+	}
+	command := m.GetHeader("call-command")
+	switch command {
+	case "":
+		return errors.New("sendmsg requires a call-command")
+	case CALL_COMMAND_EXECUTE:
+		if m.GetHeader("execute-app-name") == "" {
+			return errors.New("sendmsg execute requires an execute-app-name")
+		}
+	case CALL_COMMAND_NOMEDIA:
+		if m.GetHeader("nomedia-uuid") == "" {
+			return errors.New("sendmsg nomedia requires a nomedia-uuid")
+		}
+	case CALL_COMMAND_XFEREXT:
+		if m.GetHeader("application") == "" {
+			return errors.New("sendmsg xferext requires at least one application")
+		}
+	case CALL_COMMAND_HANGUP, CALL_COMMAND_UNICAST:
+	default:
+		return errors.New("sendmsg unknown call-command: " + command)
+	}
+	return nil
+}
+
+// encode - The lines to send, with the content-type and content-length lines when there is a body.
+func (m *SendMsg) encode() *[]string {
+	if m.body == "" {
+		return &m.lines
+	}
+	lines := make([]string, len(m.lines), len(m.lines)+2)
+	copy(lines, m.lines)
+	lines = append(lines, "content-type: text/plain", "content-length: "+strconv.Itoa(len(m.body)))
+	return &lines
+}
+
+// ToString - The sendmsg line followed by the call-command and the application, for example
+// "sendmsg <uuid> execute playback".
 func (m *SendMsg) ToString() string {
-	if len(m.lines) > 1 {
-		return m.lines[1]
+	if len(m.lines) == 0 {
+		return "sendmsg"
+	}
+	var sb strings.Builder
+	sb.WriteString(m.lines[0])
+	if command := m.GetHeader("call-command"); command != "" {
+		sb.WriteString(" ")
+		sb.WriteString(command)
+	}
+	if application := m.GetHeader("execute-app-name"); application != "" {
+		sb.WriteString(" ")
+		sb.WriteString(application)
+	}
+	return sb.String()
+}
+
+// NewExecuteMsg - sendmsg executing an application on the channel.
+func NewExecuteMsg(uuid, application, arg string) *SendMsg {
+	sendMsg := NewSendMsg(uuid)
+	sendMsg.AddCallCommand(CALL_COMMAND_EXECUTE)
+	sendMsg.AddExecuteAppName(application)
+	if arg != "" {
+		sendMsg.AddExecuteAppArg(arg)
+	}
+	return sendMsg
+}
+
+// NewHangupMsg - sendmsg hanging up the channel, the cause may be empty.
+func NewHangupMsg(uuid, cause string) *SendMsg {
+	sendMsg := NewSendMsg(uuid)
+	sendMsg.AddCallCommand(CALL_COMMAND_HANGUP)
+	if cause != "" {
+		sendMsg.AddHangupCause(cause)
+	}
+	return sendMsg
+}
+
+// UnicastParams - Where the media of a channel is sent to and read from by the unicast call-command.
+//   - Empty values keep the FreeSWITCH defaults: 127.0.0.1:8025 local, 127.0.0.1:8026 remote, udp.
+type UnicastParams struct {
+	LocalIp    string
+	LocalPort  int
+	RemoteIp   string
+	RemotePort int
+	// Transport - udp or tcp
+	Transport string
+	// Native - send the audio in the codec of the channel instead of L16
+	Native bool
+}
+
+// NewUnicastMsg - sendmsg hooking the media of the channel to a socket.
+func NewUnicastMsg(uuid string, params UnicastParams) *SendMsg {
+	sendMsg := NewSendMsg(uuid)
+	sendMsg.AddCallCommand(CALL_COMMAND_UNICAST)
+	if params.LocalIp != "" {
+		sendMsg.AddGenericLine("local-ip", params.LocalIp)
+	}
+	if params.LocalPort > 0 {
+		sendMsg.AddGenericLine("local-port", strconv.Itoa(params.LocalPort))
+	}
+	if params.RemoteIp != "" {
+		sendMsg.AddGenericLine("remote-ip", params.RemoteIp)
+	}
+	if params.RemotePort > 0 {
+		sendMsg.AddGenericLine("remote-port", strconv.Itoa(params.RemotePort))
+	}
+	if params.Transport != "" {
+		sendMsg.AddGenericLine("transport", params.Transport)
+	}
+	if params.Native {
+		sendMsg.AddGenericLine("flags", "native")
+	}
+	return sendMsg
+}
+
+// NewNomediaMsg - sendmsg taking the channel off the media path.
+func NewNomediaMsg(uuid, nomediaUuid string) *SendMsg {
+	sendMsg := NewSendMsg(uuid)
+	sendMsg.AddCallCommand(CALL_COMMAND_NOMEDIA)
+	sendMsg.AddNomediaUuid(nomediaUuid)
+	return sendMsg
+}
+
+// NewXferextMsg - sendmsg transferring the channel to an extension built from applications.
+//   - @param applications "<app> <arg>" each, for example "playback /tmp/a.wav"
+func NewXferextMsg(uuid string, applications ...string) *SendMsg {
+	sendMsg := NewSendMsg(uuid)
+	sendMsg.AddCallCommand(CALL_COMMAND_XFEREXT)
+	for _, application := range applications {
+		sendMsg.AddGenericLine("application", application)
 	}
-	return m.lines[0]
+	return sendMsg
 }
@@ -0,0 +1,41 @@
+package esl_test
+
+import (
+	"testing"
+
+	"github.com/zhouhailin/freeswitch-esl-go/esl"
+)
+
+func TestSendMsgZeroValue(t *testing.T) {
+	var msg esl.SendMsg
+	if header := msg.GetHeader("call-command"); header != "" {
+		t.Errorf("header %q", header)
+	}
+	if err := msg.Validate(); err == nil || err.Error() != "sendmsg requires a uuid line" {
+		t.Errorf("validate: %v", err)
+	}
+	if s := msg.ToString(); s != "sendmsg" {
+		t.Errorf("string %q", s)
+	}
+}
+
+func TestSendMsgValidate(t *testing.T) {
+	if err := esl.NewSendMsg("a").Validate(); err == nil || err.Error() != "sendmsg requires a call-command" {
+		t.Errorf("no call-command: %v", err)
+	}
+	execute := esl.NewSendMsg("a")
+	execute.AddCallCommand(esl.CALL_COMMAND_EXECUTE)
+	if err := execute.Validate(); err == nil {
+		t.Error("an execute without application is invalid")
+	}
+	msg := esl.NewExecuteMsg("a", "playback", "ivr/enter.wav")
+	if err := msg.Validate(); err != nil {
+		t.Error(err)
+	}
+	if header := msg.GetHeader("Execute-App-Name"); header != "playback" {
+		t.Errorf("header %q", header)
+	}
+	if s := msg.ToString(); s != "sendmsg a execute playback" {
+		t.Errorf("string %q", s)
+	}
+}
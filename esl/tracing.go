@@ -0,0 +1,139 @@
+package esl
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// REDACTED - What the redacted command arguments are replaced with.
+const REDACTED = "<redacted>"
+
+// Tracer - Receives a span for every command and every listener notification, set it in Options.Tracer or in
+// OutboundServer.Tracer.
+//   - The methods are called from the goroutine sending the command or dispatching the event, the returned function
+//   - is called once the span is complete.
+type Tracer interface {
+	// StartCommand - A command is about to be sent.
+	StartCommand(span *CommandSpan) (finish func())
+	// StartDispatch - A listener is about to be notified of an event.
+	StartDispatch(span *DispatchSpan) (finish func())
+}
+
+// CommandSpan - A command and its reply.
+type CommandSpan struct {
+	// Context - the context given to SendAsyncApiCommandAndWait, ExecuteAndWait or Originate, the parent of the span,
+	// nil for the commands sent without one
+	Context context.Context
+	// Command - the first word of the command, for example api or sendmsg
+	Command string
+	// Args - the rest of the first line, redacted by the TraceRedactor
+	Args  string
+	Start time.Time
+	// The fields below are set when the span finishes
+	Duration time.Duration
+	// ReplyStatus - +OK or -ERR, from the Reply-Text of a command/reply or the body of an api/response
+	ReplyStatus string
+	// ReplyText - the Reply-Text, or the first line of the api/response body when it is an error
+	ReplyText string
+	// JobUuid - the Job-UUID of a bgapi
+	JobUuid string
+	// Err - the reply was not received
+	Err error
+}
+
+// DispatchSpan - The notification of an event listener.
+type DispatchSpan struct {
+	EventName string
+	// Uuid - the Unique-ID of the channel of the event, if any
+	Uuid string
+	// JobUuid - the Job-UUID of a BACKGROUND_JOB
+	JobUuid string
+	// Listener - the type of the listener, for example *esl.ChannelTracker
+	Listener string
+	Start    time.Time
+	// The fields below are set when the span finishes
+	Duration time.Duration
+	// Err - what the listener returned
+	Err error
+}
+
+// RedactCommandArgs - The default Options.TraceRedactor, only the first word of the arguments is kept, for example
+// the api command or the uuid of a sendmsg, and the passwords of auth and userauth are hidden.
+func RedactCommandArgs(command, args string) string {
+	if command == "auth" || command == "userauth" {
+		return REDACTED
+	}
+	if index := strings.Index(args, " "); index >= 0 {
+		return args[:index] + " " + REDACTED
+	}
+	return args
+}
+
+// traceCommand - Start the span of a command, nil without a Tracer.
+//   - @return the function to call with the reply once it is received
+func (i *instruments) traceCommand(ctx context.Context, name, payload string) func(message *EslMessage, err error) {
+	if i == nil || i.tracer == nil {
+		return nil
+	}
+	line := payload
+	if index := strings.Index(line, "\n"); index >= 0 {
+		line = line[:index]
+	}
+	args := strings.TrimSpace(strings.TrimPrefix(line, name))
+	span := &CommandSpan{Context: ctx, Command: name, Args: i.redactor(name, args), Start: time.Now()}
+	finish := i.tracer.StartCommand(span)
+	return func(message *EslMessage, err error) {
+		span.Duration = time.Since(span.Start)
+		span.Err = err
+		if message != nil {
+			span.JobUuid = message.GetHeaderValue("Job-UUID")
+			span.ReplyStatus, span.ReplyText = replyStatus(message)
+		}
+		finish()
+	}
+}
+
+// replyStatus - +OK or -ERR and the text of a command/reply or an api/response.
+func replyStatus(message *EslMessage) (string, string) {
+	if message.HasHeader("Reply-Text") {
+		text := message.GetHeaderValue("Reply-Text")
+		if strings.HasPrefix(text, "-") {
+			return "-ERR", text
+		}
+		return "+OK", text
+	}
+	body := strings.TrimSpace(message.getBody())
+	if strings.HasPrefix(body, "-ERR") || strings.HasPrefix(body, "-USAGE") {
+		if index := strings.Index(body, "\n"); index >= 0 {
+			body = body[:index]
+		}
+		return "-ERR", body
+	}
+	return "+OK", ""
+}
+
+// notifyListener - Hand an event to a listener, inside a DispatchSpan when there is a Tracer.
+func (i *instruments) notifyListener(listener IEslEventListener, event *EslEvent) (err error) {
+	if i != nil && i.tracer != nil {
+		headers := *event.GetEventHeaders()
+		span := &DispatchSpan{
+			EventName: event.GetEventName(),
+			Uuid:      headers["Unique-ID"],
+			JobUuid:   headers["Job-UUID"],
+			Listener:  fmt.Sprintf("%T", listener),
+			Start:     time.Now(),
+		}
+		finish := i.tracer.StartDispatch(span)
+		defer func() {
+			span.Duration = time.Since(span.Start)
+			span.Err = err
+			finish()
+		}()
+	}
+	if event.GetEventName() == "BACKGROUND_JOB" {
+		return listener.BackgroundJobResultReceived(event)
+	}
+	return listener.EventReceived(event)
+}
@@ -0,0 +1,81 @@
+package esl_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zhouhailin/freeswitch-esl-go/esl"
+)
+
+type contextKey struct{}
+
+// spanRecorder - A Tracer keeping the finished command spans.
+type spanRecorder struct {
+	mtx      sync.Mutex
+	commands []esl.CommandSpan
+}
+
+func (r *spanRecorder) StartCommand(span *esl.CommandSpan) func() {
+	return func() {
+		r.mtx.Lock()
+		defer r.mtx.Unlock()
+		r.commands = append(r.commands, *span)
+	}
+}
+
+func (r *spanRecorder) StartDispatch(span *esl.DispatchSpan) func() {
+	return func() {}
+}
+
+// command - The last finished span of the command.
+func (r *spanRecorder) command(name string) (esl.CommandSpan, bool) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	for i := len(r.commands) - 1; i >= 0; i-- {
+		if r.commands[i].Command == name {
+			return r.commands[i], true
+		}
+	}
+	return esl.CommandSpan{}, false
+}
+
+func TestCommandSpans(t *testing.T) {
+	tracer := &spanRecorder{}
+	server, client := newTestClient(t, &esl.Options{Tracer: tracer})
+	server.SetApiResponse("status", "UP")
+	server.SetApiResponse("bogus", "-ERR bogus Command not found!\n")
+	completeExecutions(server, nil)
+	connect(t, server, client)
+
+	auth, ok := tracer.command("auth")
+	if !ok || auth.Args != esl.REDACTED || auth.ReplyStatus != "+OK" {
+		t.Errorf("auth span %+v", auth)
+	}
+	if _, err := client.SendApi("bogus", ""); err == nil {
+		t.Fatal("bogus is an error")
+	}
+	api, _ := tracer.command("api")
+	if api.ReplyStatus != "-ERR" || api.ReplyText != "-ERR bogus Command not found!" || api.Context != nil {
+		t.Errorf("api span %+v", api)
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), contextKey{}, "caller"), 2*time.Second)
+	defer cancel()
+	if _, err := client.SendAsyncApiCommandAndWait(ctx, "status", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ExecuteAndWait(ctx, "u1", "playback", "/tmp/a.wav"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"bgapi", "sendmsg"} {
+		span, ok := tracer.command(name)
+		if !ok || span.Context == nil || span.Context.Value(contextKey{}) != "caller" {
+			t.Errorf("%s span does not carry the context of the caller: %+v", name, span)
+		}
+	}
+	if bgapi, _ := tracer.command("bgapi"); bgapi.Args != "status" || bgapi.JobUuid == "" {
+		t.Errorf("bgapi span %+v", bgapi)
+	}
+}
@@ -0,0 +1,197 @@
+package esl
+
+import (
+	"errors"
+	"github.com/bytedance/gopkg/util/logger"
+	"net"
+	"strconv"
+	"sync"
+)
+
+const (
+	UNICAST_TRANSPORT_UDP = "udp"
+	UNICAST_TRANSPORT_TCP = "tcp"
+)
+
+// Unicast - Fork the media of the channel to a socket with the unicast call-command.
+//   - FreeSWITCH sends the audio read from the channel to RemoteIp:RemotePort, as 16 bit signed linear samples
+//   - unless Native is set, and plays the audio received on LocalIp:LocalPort. The forking ends with the channel.
+func (client *Client) Unicast(uuid string, params UnicastParams) error {
+	if uuid == "" {
+		return errors.New("Unicast requires the uuid of the channel")
+	}
+	if params.Transport != "" && params.Transport != UNICAST_TRANSPORT_UDP && params.Transport != UNICAST_TRANSPORT_TCP {
+		return errors.New("Unicast transport must be udp or tcp: " + params.Transport)
+	}
+	response, err := client.SendMessage(*NewUnicastMsg(uuid, params))
+	if err != nil {
+		return err
+	}
+	if !response.IsOk() {
+		return errors.New("unicast " + uuid + " rejected: " + response.GetReplyText())
+	}
+	return nil
+}
+
+// MediaReceiver - A local socket receiving the media forked by Unicast, mostly for tests and prototypes.
+//   - UDP packets are delivered as they are received, TCP streams in the chunks they are read in.
+type MediaReceiver struct {
+	transport string
+	udp       *net.UDPConn
+	tcp       net.Listener
+	packets   chan []byte
+	mtx       sync.Mutex
+	conns     []net.Conn
+	received  int64
+	dropped   int64
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+// NewMediaReceiver - Listen on address, for example 127.0.0.1:0 for any free port.
+//   - @param transport UNICAST_TRANSPORT_UDP or UNICAST_TRANSPORT_TCP
+//   - @param queueSize packets kept until read, further packets are dropped, 0 means 1024
+func NewMediaReceiver(transport, address string, queueSize int) (*MediaReceiver, error) {
+	if queueSize <= 0 {
+		queueSize = 1024
+	}
+	r := &MediaReceiver{transport: transport, packets: make(chan []byte, queueSize)}
+	switch transport {
+	case UNICAST_TRANSPORT_UDP:
+		udpAddr, err := net.ResolveUDPAddr("udp", address)
+		if err != nil {
+			return nil, err
+		}
+		r.udp, err = net.ListenUDP("udp", udpAddr)
+		if err != nil {
+			return nil, err
+		}
+		r.wg.Add(1)
+		go r.readUdp()
+	case UNICAST_TRANSPORT_TCP:
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			return nil, err
+		}
+		r.tcp = listener
+		r.wg.Add(1)
+		go r.accept()
+	default:
+		return nil, errors.New("MediaReceiver transport must be udp or tcp: " + transport)
+	}
+	return r, nil
+}
+
+// Addr - The address the receiver listens on.
+func (r *MediaReceiver) Addr() net.Addr {
+	if r.udp != nil {
+		return r.udp.LocalAddr()
+	}
+	return r.tcp.Addr()
+}
+
+// UnicastParams - Parameters forking the media of a channel to this receiver, the local side is left to FreeSWITCH.
+func (r *MediaReceiver) UnicastParams() UnicastParams {
+	host, port, _ := net.SplitHostPort(r.Addr().String())
+	remotePort, _ := strconv.Atoi(port)
+	return UnicastParams{RemoteIp: host, RemotePort: remotePort, Transport: r.transport}
+}
+
+// Packets - The received media, the channel is closed by Close.
+func (r *MediaReceiver) Packets() <-chan []byte {
+	return r.packets
+}
+
+// Stats - The bytes received and the packets dropped because Packets was not read fast enough.
+func (r *MediaReceiver) Stats() (received, dropped int64) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.received, r.dropped
+}
+
+// Close - Stop listening, close the accepted connections and the Packets channel.
+func (r *MediaReceiver) Close() error {
+	r.mtx.Lock()
+	if r.closed {
+		r.mtx.Unlock()
+		return nil
+	}
+	r.closed = true
+	conns := r.conns
+	r.mtx.Unlock()
+	var err error
+	if r.udp != nil {
+		err = r.udp.Close()
+	} else {
+		err = r.tcp.Close()
+	}
+	for _, conn := range conns {
+		_ = conn.Close()
+	}
+	r.wg.Wait()
+	close(r.packets)
+	return err
+}
+
+func (r *MediaReceiver) readUdp() {
+	defer r.wg.Done()
+	buffer := make([]byte, 65536)
+	for {
+		n, _, err := r.udp.ReadFromUDP(buffer)
+		if err != nil {
+			return
+		}
+		r.deliver(buffer[:n])
+	}
+}
+
+func (r *MediaReceiver) accept() {
+	defer r.wg.Done()
+	for {
+		conn, err := r.tcp.Accept()
+		if err != nil {
+			return
+		}
+		r.mtx.Lock()
+		if r.closed {
+			r.mtx.Unlock()
+			_ = conn.Close()
+			return
+		}
+		r.conns = append(r.conns, conn)
+		r.wg.Add(1)
+		r.mtx.Unlock()
+		go r.readTcp(conn)
+	}
+}
+
+func (r *MediaReceiver) readTcp(conn net.Conn) {
+	defer r.wg.Done()
+	defer conn.Close()
+	if isDebugEnabled() {
+		logger.Debugf("Media connection from %s\n", conn.RemoteAddr())
+	}
+	buffer := make([]byte, 65536)
+	for {
+		n, err := conn.Read(buffer)
+		if n > 0 {
+			r.deliver(buffer[:n])
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (r *MediaReceiver) deliver(data []byte) {
+	packet := make([]byte, len(data))
+	copy(packet, data)
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.received += int64(len(packet))
+	select {
+	case r.packets <- packet:
+	default:
+		r.dropped++
+	}
+}
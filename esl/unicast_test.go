@@ -0,0 +1,66 @@
+package esl_test
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/zhouhailin/freeswitch-esl-go/esl"
+)
+
+func TestUnicast(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	connect(t, server, client)
+	receiver, err := esl.NewMediaReceiver(esl.UNICAST_TRANSPORT_UDP, "127.0.0.1:0", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer receiver.Close()
+	params := receiver.UnicastParams()
+	if err := client.Unicast("u1", params); err != nil {
+		t.Fatal(err)
+	}
+	cmd := waitCommand(t, server, "sendmsg u1")
+	if cmd.Headers["call-command"] != "unicast" || cmd.Headers["remote-ip"] != "127.0.0.1" ||
+		cmd.Headers["transport"] != "udp" {
+		t.Fatalf("unexpected sendmsg %+v", cmd.Headers)
+	}
+	if err := client.Unicast("u1", esl.UnicastParams{Transport: "sctp"}); err == nil {
+		t.Fatal("an unknown transport must be rejected")
+	}
+}
+
+func TestMediaReceiver(t *testing.T) {
+	for _, transport := range []string{esl.UNICAST_TRANSPORT_UDP, esl.UNICAST_TRANSPORT_TCP} {
+		receiver, err := esl.NewMediaReceiver(transport, "127.0.0.1:0", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn, err := net.Dial(transport, receiver.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Write([]byte("audio")); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case packet := <-receiver.Packets():
+			if !bytes.Equal(packet, []byte("audio")) {
+				t.Fatalf("%s received %q", transport, packet)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s received nothing", transport)
+		}
+		if received, dropped := receiver.Stats(); received != 5 || dropped != 0 {
+			t.Fatalf("%s stats %d %d", transport, received, dropped)
+		}
+		_ = conn.Close()
+		if err := receiver.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, open := <-receiver.Packets(); open {
+			t.Fatalf("%s packets not closed", transport)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package esl
+
+import (
+	"github.com/bytedance/gopkg/util/logger"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// How a Watchdog makes the switch send something
+const (
+	// WATCHDOG_HEARTBEAT - subscribe to HEARTBEAT, sent every 20 seconds by default (event-heartbeat-interval)
+	WATCHDOG_HEARTBEAT = "heartbeat"
+	// WATCHDOG_PROBE - send an "api status" every interval
+	WATCHDOG_PROBE = "probe"
+)
+
+// WatchdogOptions - The liveness rules of a Watchdog.
+type WatchdogOptions struct {
+	// Mode - WATCHDOG_HEARTBEAT or WATCHDOG_PROBE, WATCHDOG_HEARTBEAT when empty
+	Mode string
+	// Interval - the expected period of the heartbeats or of the probes, 0 means 20 seconds
+	Interval time.Duration
+	// MaxMissed - the connection is dead when nothing was received for MaxMissed intervals, 0 means 3
+	MaxMissed int
+}
+
+// Watchdog - Closes the connection of a Client when the switch stays silent, so the reconnect logic takes over.
+//   - A half-open TCP connection, after a NAT timeout or a hang of the switch, is otherwise never closed. Any message
+//   - received proves the connection alive, the heartbeats or the probes make sure messages keep coming.
+type Watchdog struct {
+	listenerBase
+	client    *Client
+	options   WatchdogOptions
+	mtx       sync.Mutex
+	stop      chan struct{}
+	callbacks []func(silence time.Duration)
+	probing   int32
+}
+
+// NewWatchdog - Constructor, registers the watchdog as connection listener of client, call Start to watch.
+func NewWatchdog(client *Client, options WatchdogOptions) *Watchdog {
+	if options.Mode == "" {
+		options.Mode = WATCHDOG_HEARTBEAT
+	}
+	if options.Interval <= 0 {
+		options.Interval = 20 * time.Second
+	}
+	if options.MaxMissed <= 0 {
+		options.MaxMissed = 3
+	}
+	w := &Watchdog{client: client, options: options}
+	client.AddConnectionListener(w)
+	return w
+}
+
+// OnDead - Register a callback, it is called from the watchdog goroutine once the dead connection is closed, not when
+// closing it failed.
+func (w *Watchdog) OnDead(callback func(silence time.Duration)) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	w.callbacks = append(w.callbacks, callback)
+}
+
+// Start - Check the connection every interval until Stop is called.
+func (w *Watchdog) Start() {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if w.stop != nil {
+		return
+	}
+	w.stop = make(chan struct{})
+	go w.run(w.stop)
+}
+
+// Stop - Stop watching.
+func (w *Watchdog) Stop() {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if w.stop != nil {
+		close(w.stop)
+		w.stop = nil
+	}
+}
+
+// Sync - Subscribe to HEARTBEAT in WATCHDOG_HEARTBEAT mode.
+func (w *Watchdog) Sync() error {
+	if w.options.Mode != WATCHDOG_HEARTBEAT {
+		return nil
+	}
+	return subscribe(w.client, "HEARTBEAT", "Heartbeat")
+}
+
+func (w *Watchdog) run(stop chan struct{}) {
+	ticker := time.NewTicker(w.options.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.check()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// check - Close the connection when it is silent for too long, otherwise send a probe in WATCHDOG_PROBE mode.
+func (w *Watchdog) check() {
+	if !w.client.CanSend() {
+		return
+	}
+	silence := time.Since(w.client.LastActivity())
+	if silence >= time.Duration(w.options.MaxMissed)*w.options.Interval {
+		logger.Warnf("No message received from %s for %s, closing the connection\n", w.client.Address, silence)
+		if err := w.client.connection().Close(); err != nil {
+			logger.Errorf("Close of the dead connection failed, cause %v\n", err)
+			return
+		}
+		w.mtx.Lock()
+		callbacks := w.callbacks
+		w.mtx.Unlock()
+		for _, callback := range callbacks {
+			callback(silence)
+		}
+		return
+	}
+	if w.options.Mode == WATCHDOG_PROBE && atomic.CompareAndSwapInt32(&w.probing, 0, 1) {
+		go func() {
+			defer atomic.StoreInt32(&w.probing, 0)
+			if _, err := w.client.SendSyncApiCommand("status", ""); err != nil && isDebugEnabled() {
+				logger.Debugf("Watchdog probe failure, cause %v\n", err)
+			}
+		}()
+	}
+}
+
+// Authenticated - Implements IEslConnectionListener, subscribes to HEARTBEAT in WATCHDOG_HEARTBEAT mode.
+func (w *Watchdog) Authenticated(authenticated bool, c *Client) {
+	if !authenticated {
+		return
+	}
+	if err := w.Sync(); err != nil {
+		logger.Errorf("Watchdog subscription failure, cause %v\n", err)
+	}
+}
@@ -0,0 +1,43 @@
+package esl_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zhouhailin/freeswitch-esl-go/esl"
+)
+
+func TestWatchdogClosesSilentConnection(t *testing.T) {
+	server, client := newTestClient(t, &esl.Options{AutoReconnection: true, ReconnectIntervalSeconds: 1})
+	watchdog := esl.NewWatchdog(client, esl.WatchdogOptions{Interval: 50 * time.Millisecond, MaxMissed: 2})
+	dead := make(chan time.Duration, 4)
+	watchdog.OnDead(func(silence time.Duration) {
+		dead <- silence
+	})
+	first := connect(t, server, client)
+	waitCommand(t, server, "event plain HEARTBEAT")
+	watchdog.Start()
+	defer watchdog.Stop()
+
+	// the fake switch sends no HEARTBEAT
+	select {
+	case silence := <-dead:
+		if silence < 100*time.Millisecond {
+			t.Errorf("closed after %s of silence", silence)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the silent connection was not closed")
+	}
+	watchdog.Stop()
+	if client.CanSend() {
+		t.Error("the connection is still open")
+	}
+	second, err := server.WaitConn(3 * time.Second)
+	if err != nil {
+		t.Fatal("no reconnection after the watchdog closed the connection")
+	}
+	if second == first {
+		t.Fatal("the same connection was returned")
+	}
+	eventually(t, "the reconnection", client.CanSend)
+}
@@ -0,0 +1,22 @@
+module github.com/zhouhailin/freeswitch-esl-go/eslotel
+
+go 1.20
+
+replace github.com/zhouhailin/freeswitch-esl-go => ../
+
+require (
+	github.com/bytedance/gopkg v0.1.2
+	github.com/zhouhailin/freeswitch-esl-go v0.0.0-00010101000000-000000000000
+	go.opentelemetry.io/otel v1.24.0
+	go.opentelemetry.io/otel/sdk v1.24.0
+	go.opentelemetry.io/otel/trace v1.24.0
+)
+
+require (
+	github.com/cloudwego/gopkg v0.1.4 // indirect
+	github.com/cloudwego/netpoll v0.7.0 // indirect
+	github.com/go-logr/logr v1.4.1 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	go.opentelemetry.io/otel/metric v1.24.0 // indirect
+	golang.org/x/sys v0.19.0 // indirect
+)
@@ -0,0 +1,87 @@
+// Package eslotel - OpenTelemetry adapter of the tracing hooks of the esl package.
+//   - <pre>
+//   - options := esl.Options{AutoReconnection: true, ReconnectIntervalSeconds: 5}
+//   - options.Tracer = eslotel.NewTracer(otel.GetTracerProvider())
+//   - client := esl.NewClient(host, port, password, 10, &options)
+//   - </pre>
+package eslotel
+
+import (
+	"context"
+	"github.com/zhouhailin/freeswitch-esl-go/esl"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TRACER_NAME - The instrumentation name of the spans.
+const TRACER_NAME = "github.com/zhouhailin/freeswitch-esl-go"
+
+// Tracer - Implements esl.Tracer, records a client span per command and a consumer span per listener notification.
+//   - The commands of SendAsyncApiCommandAndWait, ExecuteAndWait and Originate are traced as children of the span of
+//   - their context, the other spans are roots.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// NewTracer - Constructor.
+//   - @param provider the provider of the tracer, for example otel.GetTracerProvider()
+func NewTracer(provider trace.TracerProvider) *Tracer {
+	return &Tracer{tracer: provider.Tracer(TRACER_NAME)}
+}
+
+// StartCommand - Implements esl.Tracer, the span is a child of the span of CommandSpan.Context, if any.
+func (t *Tracer) StartCommand(span *esl.CommandSpan) func() {
+	ctx := span.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	_, otelSpan := t.tracer.Start(ctx, "esl "+span.Command,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(span.Start),
+		trace.WithAttributes(
+			attribute.String("esl.command", span.Command),
+			attribute.String("esl.args", span.Args),
+		))
+	return func() {
+		otelSpan.SetAttributes(attribute.String("esl.reply_status", span.ReplyStatus))
+		if span.ReplyText != "" {
+			otelSpan.SetAttributes(attribute.String("esl.reply_text", span.ReplyText))
+		}
+		if span.JobUuid != "" {
+			otelSpan.SetAttributes(attribute.String("esl.job_uuid", span.JobUuid))
+		}
+		if span.Err != nil {
+			otelSpan.RecordError(span.Err)
+			otelSpan.SetStatus(codes.Error, span.Err.Error())
+		} else if span.ReplyStatus == "-ERR" {
+			otelSpan.SetStatus(codes.Error, span.ReplyText)
+		}
+		otelSpan.End(trace.WithTimestamp(span.Start.Add(span.Duration)))
+	}
+}
+
+// StartDispatch - Implements esl.Tracer.
+func (t *Tracer) StartDispatch(span *esl.DispatchSpan) func() {
+	attributes := []attribute.KeyValue{
+		attribute.String("esl.event", span.EventName),
+		attribute.String("esl.listener", span.Listener),
+	}
+	if span.Uuid != "" {
+		attributes = append(attributes, attribute.String("esl.unique_id", span.Uuid))
+	}
+	if span.JobUuid != "" {
+		attributes = append(attributes, attribute.String("esl.job_uuid", span.JobUuid))
+	}
+	_, otelSpan := t.tracer.Start(context.Background(), "esl dispatch "+span.EventName,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithTimestamp(span.Start),
+		trace.WithAttributes(attributes...))
+	return func() {
+		if span.Err != nil {
+			otelSpan.RecordError(span.Err)
+			otelSpan.SetStatus(codes.Error, span.Err.Error())
+		}
+		otelSpan.End(trace.WithTimestamp(span.Start.Add(span.Duration)))
+	}
+}
@@ -0,0 +1,94 @@
+package eslotel_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bytedance/gopkg/util/logger"
+	"github.com/zhouhailin/freeswitch-esl-go/esl"
+	"github.com/zhouhailin/freeswitch-esl-go/esl/esltest"
+	"github.com/zhouhailin/freeswitch-esl-go/eslotel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracer(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	server := esltest.NewServer("ClueCon")
+	defer server.Close()
+	server.SetApiResponse("status", "UP 0 years, 0 days")
+	server.SetApiResponse("bogus", "-ERR bogus Command not found!\n")
+	client := esl.NewClient(server.Host(), server.Port(), "ClueCon", 5, &esl.Options{
+		Level:  logger.LevelWarn,
+		Tracer: eslotel.NewTracer(provider),
+	})
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if _, err := client.SendApi("bogus", ""); err == nil {
+		t.Fatal("bogus is an error")
+	}
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	if _, err := client.SendAsyncApiCommandAndWait(ctx, "status", ""); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	// keyed by name and args, the client also reads core_uuid with an api command when it authenticates
+	spans := make(map[string]tracetest.SpanStub)
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name+" "+attributeValue(span, "esl.args")] = span
+	}
+	auth, ok := spans["esl auth "+esl.REDACTED]
+	if !ok {
+		t.Fatalf("no auth span in %v", spanNames(exporter))
+	}
+	if args := attributeValue(auth, "esl.args"); args != esl.REDACTED {
+		t.Errorf("auth args %q", args)
+	}
+
+	api := spans["esl api bogus"]
+	if api.Status.Code != codes.Error || attributeValue(api, "esl.reply_status") != "-ERR" {
+		t.Errorf("api status %+v, attributes %v", api.Status, api.Attributes)
+	}
+	if text := attributeValue(api, "esl.reply_text"); text != "-ERR bogus Command not found!" {
+		t.Errorf("api reply text %q", text)
+	}
+	if api.Parent.IsValid() {
+		t.Error("the api span has a parent")
+	}
+
+	bgapi, ok := spans["esl bgapi status"]
+	if !ok {
+		t.Fatalf("no bgapi span in %v", spanNames(exporter))
+	}
+	if bgapi.Parent.SpanID() != parent.SpanContext().SpanID() ||
+		bgapi.SpanContext.TraceID() != parent.SpanContext().TraceID() {
+		t.Error("the bgapi span is not a child of the span of the context")
+	}
+	if bgapi.Status.Code == codes.Error || attributeValue(bgapi, "esl.job_uuid") == "" {
+		t.Errorf("bgapi status %+v, attributes %v", bgapi.Status, bgapi.Attributes)
+	}
+}
+
+func attributeValue(span tracetest.SpanStub, key string) string {
+	for _, kv := range span.Attributes {
+		if kv.Key == attribute.Key(key) {
+			return kv.Value.AsString()
+		}
+	}
+	return ""
+}
+
+func spanNames(exporter *tracetest.InMemoryExporter) []string {
+	var names []string
+	for _, span := range exporter.GetSpans() {
+		names = append(names, span.Name)
+	}
+	return names
+}